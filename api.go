@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+type apiViewMeta struct {
+	Dir     string `json:"dir"`
+	RuleSet string `json:"ruleset"`
+	Limit   int    `json:"limit"`
+	Offset  int    `json:"offset"`
+	Scanned int    `json:"scanned"`
+	Matched int    `json:"matched"`
+	More    bool   `json:"more"`
+	TookMs  int64  `json:"took_ms"`
+	CapHit  bool   `json:"cap_hit"`
+	// TruncatedBy lists why matching messages are missing from the
+	// response, see truncation
+	Truncated   bool     `json:"truncated"`
+	TruncatedBy []string `json:"truncated_by,omitempty"`
+	// Files is set with filecounts parameter
+	Files []fileCount `json:"files,omitempty"`
+}
+
+type apiViewResponse struct {
+	Messages []map[string]any `json:"messages"`
+	Meta     apiViewMeta      `json:"meta"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Warn().Err(err).Msg("writing json response")
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, apiError{Error: err.Error()})
+}
+
+// ruleErrorStatus is the response status for effectiveRule errors
+func ruleErrorStatus(err error) int {
+	if errors.Is(err, errRuleSetNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// parseRuleParam parses ad-hoc rule passed as JSON in the "rule" query parameter
+func parseRuleParam(q string) (*rules.Rule, error) {
+	if q == "" {
+		return nil, nil
+	}
+	ret := &rules.Rule{}
+	err := json.Unmarshal([]byte(q), ret)
+	if err != nil {
+		return nil, fmt.Errorf("parsing rule parameter: %w", err)
+	}
+	if ret.Op == "" {
+		return nil, errors.New("parsing rule parameter: Op is empty")
+	}
+	return ret, nil
+}
+
+// andRules combines rules so that all of them have to match, nil rules are skipped
+func andRules(rs ...*rules.Rule) *rules.Rule {
+	data := []any{}
+	var last *rules.Rule
+	for _, r := range rs {
+		if r == nil {
+			continue
+		}
+		last = r
+		data = append(data, map[string]any{"Op": r.Op, "Data": r.Data})
+	}
+	if len(data) <= 1 {
+		return last
+	}
+	return &rules.Rule{Op: "and", Data: data}
+}
+
+func handleAPIView(w http.ResponseWriter, r *http.Request) {
+	saved, err := loadSaved()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	p := parseViewParams(r)
+	meta := apiViewMeta{
+		Dir:     p.Dir,
+		RuleSet: p.RuleSet,
+		Limit:   p.Limit,
+		Offset:  p.Offset,
+	}
+	rule, err := saved.effectiveRule(p)
+	if err != nil {
+		writeJSONError(w, ruleErrorStatus(err), err)
+		return
+	}
+	ctx, cancel := saved.Settings.scanContext(r)
+	defer cancel()
+	res, err := saved.processView(ctx, p, rule)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	meta.Scanned = res.Scanned
+	meta.Matched = res.Matched
+	meta.More = res.hasMore(p.Offset, p.Limit)
+	meta.TookMs = res.Took.Milliseconds()
+	meta.CapHit = res.CapHit
+	meta.Files = res.FileCounts
+	for _, t := range res.truncations(p.Offset, p.Limit) {
+		meta.Truncated = true
+		meta.TruncatedBy = append(meta.TruncatedBy, t.Reason)
+	}
+	writeJSON(w, http.StatusOK, apiViewResponse{Messages: res.Messages, Meta: meta})
+}
+
+type apiCountResponse struct {
+	Matched int  `json:"matched"`
+	Scanned int  `json:"scanned"`
+	CapHit  bool `json:"cap_hit"`
+	// Truncated is set when counts don't cover every line, TruncatedBy
+	// says why like for views: "line-cap" or "timeout"
+	Truncated   bool     `json:"truncated"`
+	TruncatedBy []string `json:"truncated_by,omitempty"`
+}
+
+// handleAPICount only counts matching lines. With "window" (Go duration)
+// only lines with parseable time within that window from now are counted.
+// Scans are bounded by RequestTimeout, counts so far are returned when it
+// runs out.
+func handleAPICount(w http.ResponseWriter, r *http.Request) {
+	saved, err := loadSaved()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	p := parseViewParams(r)
+	dirName := p.Dir
+	rule, err := saved.effectiveRule(p)
+	if err != nil {
+		writeJSONError(w, ruleErrorStatus(err), err)
+		return
+	}
+	var since time.Time
+	if q := r.URL.Query().Get("window"); q != "" {
+		window, err := time.ParseDuration(q)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Errorf("parsing window: %w", err))
+			return
+		}
+		since = time.Now().Add(-window)
+	}
+	ctx, cancel := saved.Settings.scanContext(r)
+	defer cancel()
+	opts := saved.scanOptions(p).withContext(ctx)
+	layouts := opts.TimeLayouts
+	match := ruleMatcher(rule, opts)
+	ret := apiCountResponse{}
+	timedOut := false
+	ret.CapHit, err = scanDir(dirName, opts, func(fp, line string) error {
+		ret.Scanned++
+		if ret.Scanned%1024 == 0 && ctx.Err() != nil {
+			timedOut = true
+			return errScanStopped
+		}
+		if !since.IsZero() {
+			msg := map[string]any{}
+			if json.Unmarshal([]byte(line), &msg) != nil {
+				return nil
+			}
+			t, ok := rules.ParseTime(msg["time"], layouts)
+			if !ok || t.Before(since) {
+				return nil
+			}
+		}
+		if match != nil {
+			ok, err := match(fp, line)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return nil
+			}
+		}
+		ret.Matched++
+		return nil
+	})
+	// reads of remote sources fail rather than stop when ctx is done
+	if errors.Is(err, errScanStopped) || (err != nil && ctx.Err() != nil) {
+		timedOut = true
+		err = nil
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if ret.CapHit {
+		ret.TruncatedBy = append(ret.TruncatedBy, truncatedLineCap)
+	}
+	if timedOut {
+		ret.TruncatedBy = append(ret.TruncatedBy, truncatedTimeout)
+	}
+	ret.Truncated = len(ret.TruncatedBy) > 0
+	writeJSON(w, http.StatusOK, ret)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/maxsupermanhd/json-log-viewer/client"
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+// TestClientContract runs the client against the real API so the two
+// can't drift apart
+func TestClientContract(t *testing.T) {
+	dir := writeLogDir(t, map[string]string{
+		"a.log": `{"level":"info","message":"a1"}` + "\n" + `{"level":"error","message":"a2"}` + "\n",
+		"b.log": `{"level":"error","message":"b1"}` + "\n",
+	})
+	withSaved(t, SavedStuff{
+		RuleSets: map[string]*rules.Rule{
+			"err": {Op: "fieldcontains", Data: map[string]any{"Field": "level", "Value": "error"}},
+		},
+		LogDirs: map[string]map[string]*rules.Rule{dir: {}},
+	})
+	srv := httptest.NewServer(newHandler())
+	defer srv.Close()
+	c := client.New(srv.URL)
+
+	rule := client.Not(client.Contains("b1"))
+	msgs, meta, err := c.View(context.Background(), dir, "err", client.ViewOptions{Rule: &rule, FileCounts: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || msgs[0]["message"] != "a2" {
+		t.Errorf("got messages %v", msgs)
+	}
+	if meta.Scanned != 3 || meta.Matched != 1 || meta.RuleSet != "err" {
+		t.Errorf("got meta %+v", meta)
+	}
+	want := []client.FileCount{{File: filepath.Join(dir, "a.log"), Scanned: 2, Matched: 1}, {File: filepath.Join(dir, "b.log"), Scanned: 1, Matched: 0}}
+	if len(meta.Files) != len(want) || meta.Files[0] != want[0] || meta.Files[1] != want[1] {
+		t.Errorf("got files %v, want %v", meta.Files, want)
+	}
+
+	msgs, meta, err = c.View(context.Background(), dir, "", client.ViewOptions{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || !meta.Truncated || len(meta.TruncatedBy) != 1 || meta.TruncatedBy[0] != truncatedPage {
+		t.Errorf("got %v with meta %+v", msgs, meta)
+	}
+
+	count, err := c.Count(context.Background(), dir, "err", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count.Matched != 2 || count.Scanned != 3 {
+		t.Errorf("got count %+v", count)
+	}
+
+	_, _, err = c.View(context.Background(), dir, "missing", client.ViewOptions{})
+	if err == nil {
+		t.Error("no error for a missing rule set")
+	}
+}
+
+func TestAPICountTimeout(t *testing.T) {
+	dir := writeLogDir(t, map[string]string{"a.log": strings.Repeat(`{"message":"x"}`+"\n", 3000)})
+	withSaved(t, SavedStuff{LogDirs: map[string]map[string]*rules.Rule{dir: {}}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec := httptest.NewRecorder()
+	newHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/count/"+url.PathEscape(dir), nil).WithContext(ctx))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	got := apiCountResponse{}
+	err := json.Unmarshal(rec.Body.Bytes(), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Truncated || len(got.TruncatedBy) != 1 || got.TruncatedBy[0] != truncatedTimeout || got.Scanned >= 3000 {
+		t.Errorf("got %+v, want a count stopped by timeout", got)
+	}
+
+	code, body := get(t, "/api/count/"+url.PathEscape(dir))
+	if code != http.StatusOK || !strings.Contains(body, `"matched":3000`) || strings.Contains(body, "truncated_by") {
+		t.Errorf("got %d %s", code, body)
+	}
+}
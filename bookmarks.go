@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/a-h/templ"
+)
+
+// bookmark is a line flagged during an investigation. ID is the content
+// anchor of the line, so together with the directory it finds the line
+// again after rotation moves it to another file. Line is a copy shown even
+// once the line is gone from the logs.
+type bookmark struct {
+	Dir   string
+	File  string // base name of the file the line was in when bookmarked
+	ID    string
+	Line  string
+	Note  string
+	Added time.Time
+}
+
+func (b bookmark) messageURL() string {
+	q := url.Values{}
+	q.Set("file", b.File)
+	q.Set("id", b.ID)
+	return "/message/" + url.PathEscape(b.Dir) + "?" + q.Encode()
+}
+
+var errBookmarksDisabled = errors.New("bookmarks are disabled, set Settings.BookmarksFile to enable them")
+
+// bookmarksMu serializes read-modify-write of the bookmarks file
+var bookmarksMu sync.Mutex
+
+// loadBookmarks reads bookmarks newest first, missing file has none
+func loadBookmarks(fp string) ([]bookmark, error) {
+	b, err := os.ReadFile(fp)
+	if errors.Is(err, os.ErrNotExist) {
+		return []bookmark{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ret := []bookmark{}
+	err = json.Unmarshal(b, &ret)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// saveBookmarks replaces the file atomically so a crash mid-write can't
+// lose bookmarks collected so far
+func saveBookmarks(fp string, bs []bookmark) error {
+	b, err := json.MarshalIndent(bs, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(fp, b)
+}
+
+// writeFileAtomic writes to a temporary file next to fp and renames it over
+// fp, readers see either the old or the new content
+func writeFileAtomic(fp string, b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(fp), "."+filepath.Base(fp)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fp)
+}
+
+// updateBookmarks applies fn to stored bookmarks and saves the result
+func updateBookmarks(fp string, fn func([]bookmark) ([]bookmark, error)) error {
+	bookmarksMu.Lock()
+	defer bookmarksMu.Unlock()
+	bs, err := loadBookmarks(fp)
+	if err != nil {
+		return err
+	}
+	bs, err = fn(bs)
+	if err != nil {
+		return err
+	}
+	return saveBookmarks(fp, bs)
+}
+
+func handleBookmarks(w http.ResponseWriter, r *http.Request) {
+	saved, err := loadSaved()
+	if err != nil {
+		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
+		return
+	}
+	if saved.Settings.BookmarksFile == "" {
+		templ.Handler(tPage(tMessage(errBookmarksDisabled.Error()))).ServeHTTP(w, r)
+		return
+	}
+	bookmarksMu.Lock()
+	bs, err := loadBookmarks(saved.Settings.BookmarksFile)
+	bookmarksMu.Unlock()
+	if err != nil {
+		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
+		return
+	}
+	templ.Handler(tPage(tBookmarks(bs))).ServeHTTP(w, r)
+}
+
+// handleBookmarkAdd looks the line up the same way the detail page does and
+// stores it, bookmarking it again only updates the note
+func handleBookmarkAdd(w http.ResponseWriter, r *http.Request) {
+	saved, err := loadSaved()
+	if err != nil {
+		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
+		return
+	}
+	if saved.Settings.BookmarksFile == "" {
+		templ.Handler(tPage(tMessage(errBookmarksDisabled.Error()))).ServeHTTP(w, r)
+		return
+	}
+	dir, id := r.PostFormValue("dir"), r.PostFormValue("id")
+	if _, ok := saved.LogDirs[dir]; !ok {
+		templ.Handler(tPage(tMessage("unknown directory"))).ServeHTTP(w, r)
+		return
+	}
+	opts := saved.scanOptions(viewParams{Dir: dir}).withContext(r.Context())
+	d, err := findMessage(dir, opts, r.PostFormValue("file"), id)
+	if err != nil {
+		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
+		return
+	}
+	add := bookmark{
+		Dir:   dir,
+		File:  filepath.Base(d.File),
+		ID:    lineAnchor(d.Line),
+		Line:  d.Line,
+		Note:  r.PostFormValue("note"),
+		Added: time.Now(),
+	}
+	err = updateBookmarks(saved.Settings.BookmarksFile, func(bs []bookmark) ([]bookmark, error) {
+		i := slices.IndexFunc(bs, func(b bookmark) bool { return b.Dir == add.Dir && b.ID == add.ID })
+		if i >= 0 {
+			bs[i].Note = add.Note
+			return bs, nil
+		}
+		return slices.Insert(bs, 0, add), nil
+	})
+	if err != nil {
+		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
+		return
+	}
+	http.Redirect(w, r, "/bookmarks", http.StatusSeeOther)
+}
+
+func handleBookmarkRemove(w http.ResponseWriter, r *http.Request) {
+	saved, err := loadSaved()
+	if err != nil {
+		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
+		return
+	}
+	if saved.Settings.BookmarksFile == "" {
+		templ.Handler(tPage(tMessage(errBookmarksDisabled.Error()))).ServeHTTP(w, r)
+		return
+	}
+	dir, id := r.PostFormValue("dir"), r.PostFormValue("id")
+	err = updateBookmarks(saved.Settings.BookmarksFile, func(bs []bookmark) ([]bookmark, error) {
+		return slices.DeleteFunc(bs, func(b bookmark) bool { return b.Dir == dir && b.ID == id }), nil
+	})
+	if err != nil {
+		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
+		return
+	}
+	http.Redirect(w, r, "/bookmarks", http.StatusSeeOther)
+}
@@ -0,0 +1,195 @@
+// Package client is a small typed client for the log viewer JSON API.
+//
+//	c := client.New("http://localhost:9172")
+//	rule := client.Not(client.Contains("healthcheck"))
+//	msgs, meta, err := c.View(ctx, "/var/log/app", "only errors", client.ViewOptions{
+//		Limit: 100,
+//		Rule:  &rule,
+//	})
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Rule mirrors the {Op, Data} shape rule sets are stored in
+type Rule struct {
+	Op   string
+	Data any
+}
+
+func Contains(s string) Rule {
+	return Rule{Op: "contains", Data: s}
+}
+
+func NotContains(s string) Rule {
+	return Rule{Op: "ncontains", Data: s}
+}
+
+func Not(r Rule) Rule {
+	return Rule{Op: "not", Data: r}
+}
+
+func And(rules ...Rule) Rule {
+	return Rule{Op: "and", Data: rules}
+}
+
+func Or(rules ...Rule) Rule {
+	return Rule{Op: "or", Data: rules}
+}
+
+// Message is a single parsed log line, lines that are not JSON have only "message" set
+type Message map[string]any
+
+type Meta struct {
+	Dir     string `json:"dir"`
+	RuleSet string `json:"ruleset"`
+	Limit   int    `json:"limit"`
+	Offset  int    `json:"offset"`
+	Scanned int    `json:"scanned"`
+	Matched int    `json:"matched"`
+	More    bool   `json:"more"` // there are matched messages past this page
+	TookMs  int64  `json:"took_ms"`
+	CapHit  bool   `json:"cap_hit"` // older lines were not scanned due to the line cap
+	// Truncated is set when not everything matching was returned,
+	// TruncatedBy says why: "line-cap", "page" or "timeout"
+	Truncated   bool     `json:"truncated"`
+	TruncatedBy []string `json:"truncated_by"`
+	// Files breaks counts down by file, only with ViewOptions.FileCounts
+	Files []FileCount `json:"files"`
+}
+
+// FileCount is how many lines of one file were scanned and matched
+type FileCount struct {
+	File    string `json:"file"` // path on the server
+	Scanned int    `json:"scanned"`
+	Matched int    `json:"matched"`
+}
+
+// ViewOptions are optional parameters of View, zero values mean server defaults
+type ViewOptions struct {
+	Limit   int
+	Offset  int
+	Rule    *Rule  // ad-hoc rule applied on top of the rule set
+	Files   string // glob of file names to scan
+	Exclude string // glob of file names to skip, applied after Files
+	MaxScan int    // how many newest lines to scan, capped by the server
+	Newest  bool   // only scan the most recently modified file
+	// FileCounts asks for scanned and matched counts by file in Meta.Files
+	FileCounts bool
+}
+
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func New(baseURL string) *Client {
+	return &Client{BaseURL: baseURL, HTTPClient: http.DefaultClient}
+}
+
+// View fetches messages of the directory filtered by the named rule set
+// (empty for none), newest first
+func (c *Client) View(ctx context.Context, dir, ruleset string, opts ViewOptions) ([]Message, Meta, error) {
+	var resp struct {
+		Messages []Message `json:"messages"`
+		Meta     Meta      `json:"meta"`
+	}
+	p := "/api/view/" + url.PathEscape(dir)
+	if ruleset != "" {
+		p += "/" + url.PathEscape(ruleset)
+	}
+	q := url.Values{}
+	if opts.Limit > 0 {
+		q.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Offset > 0 {
+		q.Set("offset", strconv.Itoa(opts.Offset))
+	}
+	if opts.Files != "" {
+		q.Set("files", opts.Files)
+	}
+	if opts.Exclude != "" {
+		q.Set("exclude", opts.Exclude)
+	}
+	if opts.MaxScan > 0 {
+		q.Set("maxscan", strconv.Itoa(opts.MaxScan))
+	}
+	if opts.Newest {
+		q.Set("newest", "1")
+	}
+	if opts.FileCounts {
+		q.Set("filecounts", "1")
+	}
+	if opts.Rule != nil {
+		b, err := json.Marshal(opts.Rule)
+		if err != nil {
+			return nil, resp.Meta, fmt.Errorf("marshaling rule: %w", err)
+		}
+		q.Set("rule", string(b))
+	}
+	err := c.get(ctx, p, q, &resp)
+	return resp.Messages, resp.Meta, err
+}
+
+type Count struct {
+	Matched int  `json:"matched"`
+	Scanned int  `json:"scanned"`
+	CapHit  bool `json:"cap_hit"`
+	// Truncated is set when not every line was counted, TruncatedBy says
+	// why: "line-cap" or "timeout"
+	Truncated   bool     `json:"truncated"`
+	TruncatedBy []string `json:"truncated_by"`
+}
+
+// Count returns number of lines matching the rule set without fetching them,
+// window limits counting to lines logged within it from now (0 for all)
+func (c *Client) Count(ctx context.Context, dir, ruleset string, window time.Duration) (Count, error) {
+	ret := Count{}
+	p := "/api/count/" + url.PathEscape(dir)
+	if ruleset != "" {
+		p += "/" + url.PathEscape(ruleset)
+	}
+	q := url.Values{}
+	if window > 0 {
+		q.Set("window", window.String())
+	}
+	err := c.get(ctx, p, q, &ret)
+	return ret, err
+}
+
+func (c *Client) get(ctx context.Context, p string, q url.Values, ret any) error {
+	u := c.BaseURL + p
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&e) == nil && e.Error != "" {
+			return fmt.Errorf("%s: %s", resp.Status, e.Error)
+		}
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(ret)
+}
@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestView(t *testing.T) {
+	var got *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Write([]byte(`{"messages":[{"message":"a","level":"error"}],"meta":{"dir":"/logs","ruleset":"err","limit":10,"scanned":5,"matched":1,"truncated":true,"truncated_by":["line-cap","timeout"],"files":[{"file":"a.log","scanned":5,"matched":1}]}}`))
+	}))
+	defer srv.Close()
+
+	rule := And(Contains("x"), Not(NotContains("y")))
+	msgs, meta, err := New(srv.URL).View(context.Background(), "/logs", "err", ViewOptions{
+		Limit:      10,
+		Offset:     20,
+		Rule:       &rule,
+		Files:      "*.log",
+		Exclude:    "*debug*",
+		MaxScan:    1000,
+		Newest:     true,
+		FileCounts: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.URL.EscapedPath() != "/api/view/%2Flogs/err" {
+		t.Errorf("got path %q", got.URL.EscapedPath())
+	}
+	q := got.URL.Query()
+	for k, v := range map[string]string{
+		"limit":      "10",
+		"offset":     "20",
+		"files":      "*.log",
+		"exclude":    "*debug*",
+		"maxscan":    "1000",
+		"newest":     "1",
+		"filecounts": "1",
+		"rule":       `{"Op":"and","Data":[{"Op":"contains","Data":"x"},{"Op":"not","Data":{"Op":"ncontains","Data":"y"}}]}`,
+	} {
+		if q.Get(k) != v {
+			t.Errorf("query %s is %q, want %q", k, q.Get(k), v)
+		}
+	}
+	if len(msgs) != 1 || msgs[0]["level"] != "error" {
+		t.Errorf("got messages %v", msgs)
+	}
+	if !meta.Truncated || strings.Join(meta.TruncatedBy, ",") != "line-cap,timeout" {
+		t.Errorf("got truncation %v %v", meta.Truncated, meta.TruncatedBy)
+	}
+	if len(meta.Files) != 1 || meta.Files[0] != (FileCount{File: "a.log", Scanned: 5, Matched: 1}) {
+		t.Errorf("got files %v", meta.Files)
+	}
+}
+
+func TestViewDefaults(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/view/logs" || r.URL.RawQuery != "" {
+			t.Errorf("got %s", r.URL)
+		}
+		w.Write([]byte(`{"messages":[],"meta":{}}`))
+	}))
+	defer srv.Close()
+	_, _, err := New(srv.URL).View(context.Background(), "logs", "", ViewOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCount(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/count/logs/err" || r.URL.Query().Get("window") != "5m0s" {
+			t.Errorf("got %s", r.URL)
+		}
+		w.Write([]byte(`{"matched":3,"scanned":10,"cap_hit":true,"truncated":true,"truncated_by":["line-cap"]}`))
+	}))
+	defer srv.Close()
+	got, err := New(srv.URL).Count(context.Background(), "logs", "err", 5*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Count{Matched: 3, Scanned: 10, CapHit: true, Truncated: true, TruncatedBy: []string{"line-cap"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/view/plain" {
+			http.Error(w, "nope", http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": `rule set "x" not found`})
+	}))
+	defer srv.Close()
+	c := New(srv.URL)
+	_, _, err := c.View(context.Background(), "logs", "x", ViewOptions{})
+	if err == nil || err.Error() != `404 Not Found: rule set "x" not found` {
+		t.Errorf("got %v", err)
+	}
+	_, _, err = c.View(context.Background(), "plain", "", ViewOptions{})
+	if err == nil || err.Error() != "502 Bad Gateway" {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/maxsupermanhd/json-log-viewer/client"
+)
+
+func ExampleClient_View() {
+	// stands in for a running log viewer
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"messages":[{"level":"error","message":"disk full"}],"meta":{"scanned":120,"matched":1}}`)
+	}))
+	defer srv.Close()
+
+	c := client.New(srv.URL)
+	rule := client.Not(client.Contains("healthcheck"))
+	msgs, meta, err := c.View(context.Background(), "/var/log/app", "only errors", client.ViewOptions{
+		Limit: 100,
+		Rule:  &rule,
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, m := range msgs {
+		fmt.Println(m["level"], m["message"])
+	}
+	fmt.Printf("%d of %d lines matched\n", meta.Matched, meta.Scanned)
+	// Output:
+	// error disk full
+	// 1 of 120 lines matched
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+type apiReloadResponse struct {
+	OK       bool     `json:"ok"`
+	Errors   []string `json:"errors,omitempty"`
+	RuleSets []string `json:"rulesets,omitempty"`
+	Dirs     []string `json:"dirs,omitempty"`
+}
+
+// validate checks that every rule of the config has its placeholders defined
+// and only uses known ops and that directory settings make sense, all
+// problems are reported at once
+func (s SavedStuff) validate() []error {
+	errs := []error{}
+	checkResolved := func(where string, r *rules.Rule, err error) {
+		if err == nil {
+			err = checkRuleOps(r)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", where, err))
+		}
+	}
+	check := func(where string, r *rules.Rule) {
+		r, err := s.resolveRule(r)
+		checkResolved(where, r, err)
+	}
+	check("default rule", s.Default)
+	for _, k := range slices.Sorted(maps.Keys(s.RuleSets)) {
+		r, err := s.resolveRuleSet(k, envResolve)
+		checkResolved(fmt.Sprintf("rule set %q", k), r, err)
+	}
+	for _, d := range slices.Sorted(maps.Keys(s.LogDirs)) {
+		for _, k := range slices.Sorted(maps.Keys(s.LogDirs[d])) {
+			check(fmt.Sprintf("dir %q rule set %q", d, k), s.LogDirs[d][k])
+		}
+	}
+	for _, d := range slices.Sorted(maps.Keys(s.DirSettings)) {
+		check(fmt.Sprintf("dir %q default rule", d), s.dirSettings(d).Default)
+		if f := s.dirSettings(d).Framing; !validFraming(f) {
+			errs = append(errs, fmt.Errorf("dir %q: unknown framing %q", d, f))
+		}
+		if err := validDurationFields(s.dirSettings(d).DurationFields); err != nil {
+			errs = append(errs, fmt.Errorf("dir %q: %w", d, err))
+		}
+		if _, err := parseDerivedFields(s.dirSettings(d).DerivedFields); err != nil {
+			errs = append(errs, fmt.Errorf("dir %q: %w", d, err))
+		}
+		if _, err := s.dirSettings(d).Redact.compile(); err != nil {
+			errs = append(errs, fmt.Errorf("dir %q: %w", d, err))
+		}
+		if err := validStackCollapse(s.dirSettings(d).StackCollapse); err != nil {
+			errs = append(errs, fmt.Errorf("dir %q: %w", d, err))
+		}
+	}
+	for _, k := range slices.Sorted(maps.Keys(s.RuleSetViews)) {
+		if !s.hasRuleSet(k) {
+			errs = append(errs, fmt.Errorf("view of rule set %q: no such rule set", k))
+		}
+	}
+	return errs
+}
+
+// hasRuleSet tells whether a global or any directory's rule set has the name
+func (s SavedStuff) hasRuleSet(name string) bool {
+	if _, ok := s.RuleSets[name]; ok {
+		return true
+	}
+	for _, dirRules := range s.LogDirs {
+		if _, ok := dirRules[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// leafDataOps have an Op key in their Data that is not a nested rule
+var leafDataOps = map[string]bool{
+	"countcontains": true,
+	"semver":        true,
+}
+
+// checkRuleOps walks rule data looking for nested rules and reports ops that
+// are not registered. Data is not otherwise checked since its shape is up
+// to each op.
+func checkRuleOps(r *rules.Rule) error {
+	if r == nil {
+		return nil
+	}
+	if _, ok := ruleOps[r.Op]; !ok {
+		return fmt.Errorf("op %q not found", r.Op)
+	}
+	if leafDataOps[r.Op] {
+		return nil
+	}
+	return checkDataOps(r.Data)
+}
+
+func checkDataOps(data any) error {
+	switch d := data.(type) {
+	case []any:
+		for _, el := range d {
+			if err := checkDataOps(el); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		if op, ok := d["Op"].(string); ok {
+			return checkRuleOps(&rules.Rule{Op: op, Data: d["Data"]})
+		}
+		for _, el := range d {
+			if err := checkDataOps(el); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// handleAPIReload re-reads and validates saved.json right away. Config is
+// read on every request anyway, this is for confirming an edit took effect.
+// Remote config is fetched again without waiting for its refresh interval,
+// though no more often than remoteConfigMinReload.
+func handleAPIReload(w http.ResponseWriter, r *http.Request) {
+	saved, err := loadSaved()
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, apiReloadResponse{Errors: []string{err.Error()}})
+		return
+	}
+	if rc := saved.Settings.RemoteConfig; rc != nil && rc.URL != "" {
+		remote, err := rc.reload()
+		if err != nil {
+			writeJSON(w, http.StatusUnprocessableEntity, apiReloadResponse{Errors: []string{err.Error()}})
+			return
+		}
+		saved = saved.withRemote(remote)
+	}
+	if errs := saved.validate(); len(errs) > 0 {
+		ret := apiReloadResponse{}
+		for _, err := range errs {
+			ret.Errors = append(ret.Errors, err.Error())
+		}
+		writeJSON(w, http.StatusUnprocessableEntity, ret)
+		return
+	}
+	writeJSON(w, http.StatusOK, apiReloadResponse{
+		OK:       true,
+		RuleSets: slices.Sorted(maps.Keys(saved.RuleSets)),
+		Dirs:     slices.Sorted(maps.Keys(saved.LogDirs)),
+	})
+}
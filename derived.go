@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+// derivedField is a display-only field computed from two numeric operands,
+// each either a field path or a number, like "bytes / duration_ms"
+type derivedField struct {
+	Name        string
+	Left, Right string
+	Op          string
+}
+
+func parseDerivedFields(defs map[string]string) ([]derivedField, error) {
+	ret := []derivedField{}
+	for _, name := range slices.Sorted(maps.Keys(defs)) {
+		parts := strings.Fields(defs[name])
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("derived field %q: expression %q is not \"operand op operand\"", name, defs[name])
+		}
+		if !strings.Contains("+-*/", parts[1]) || len(parts[1]) != 1 {
+			return nil, fmt.Errorf("derived field %q: unknown operator %q", name, parts[1])
+		}
+		ret = append(ret, derivedField{Name: name, Left: parts[0], Op: parts[1], Right: parts[2]})
+	}
+	return ret, nil
+}
+
+func derivedOperand(msg map[string]any, operand string) (float64, bool) {
+	if n, err := strconv.ParseFloat(operand, 64); err == nil {
+		return n, true
+	}
+	v, ok := rules.LookupField(msg, operand)
+	if !ok {
+		return 0, false
+	}
+	s, ok := rules.LooseString(v)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	return n, err == nil
+}
+
+// eval computes the field, missing or non-numeric operands and division by
+// zero leave it out
+func (d derivedField) eval(msg map[string]any) (float64, bool) {
+	l, ok := derivedOperand(msg, d.Left)
+	if !ok {
+		return 0, false
+	}
+	r, ok := derivedOperand(msg, d.Right)
+	if !ok {
+		return 0, false
+	}
+	switch d.Op {
+	case "+":
+		return l + r, true
+	case "-":
+		return l - r, true
+	case "*":
+		return l * r, true
+	case "/":
+		if r == 0 {
+			return 0, false
+		}
+		return l / r, true
+	}
+	return 0, false
+}
+
+// addDerived sets derived fields on the message, fields are evaluated in
+// name order against the message as it was before any of them were added
+func addDerived(msg map[string]any, fields []derivedField) {
+	vals := make([]float64, len(fields))
+	oks := make([]bool, len(fields))
+	for i, d := range fields {
+		vals[i], oks[i] = d.eval(msg)
+	}
+	for i, d := range fields {
+		if oks[i] {
+			msg[d.Name] = vals[i]
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestParseDerivedFields(t *testing.T) {
+	got, err := parseDerivedFields(map[string]string{"tput": "bytes / duration_ms", "ms": "elapsed   *  1000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []derivedField{
+		{Name: "ms", Left: "elapsed", Op: "*", Right: "1000"},
+		{Name: "tput", Left: "bytes", Op: "/", Right: "duration_ms"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, expr := range []string{"", "bytes", "bytes /", "a / b / c", "a % b", "a // b", "a +- b", "a/b"} {
+		if _, err := parseDerivedFields(map[string]string{"x": expr}); err == nil {
+			t.Errorf("%q accepted", expr)
+		}
+	}
+}
+
+func TestDerivedEval(t *testing.T) {
+	msg := map[string]any{
+		"bytes":  1000.0,
+		"ms":     4.0,
+		"zero":   0.0,
+		"str":    "2.5",
+		"word":   "fast",
+		"null":   nil,
+		"nested": map[string]any{"n": 3.0},
+	}
+	for _, c := range []struct {
+		expr string
+		want float64
+		ok   bool
+	}{
+		{"bytes / ms", 250, true},
+		{"bytes + ms", 1004, true},
+		{"bytes - ms", 996, true},
+		{"bytes * ms", 4000, true},
+		{"str * 2", 5, true},
+		{"nested.n * ms", 12, true},
+		{"10 / 4", 2.5, true},
+		{"bytes / zero", 0, false},
+		{"bytes / 0", 0, false},
+		{"zero / bytes", 0, true},
+		{"bytes / missing", 0, false},
+		{"missing / bytes", 0, false},
+		{"word + 1", 0, false},
+		{"null + 1", 0, false},
+		{"nested + 1", 0, false},
+	} {
+		d, err := parseDerivedFields(map[string]string{"x": c.expr})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, ok := d[0].eval(msg)
+		if got != c.want || ok != c.ok {
+			t.Errorf("%s: got %v, %v, want %v, %v", c.expr, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestAddDerived(t *testing.T) {
+	fields, err := parseDerivedFields(map[string]string{
+		"a_tput":  "bytes / ms",
+		"b_ratio": "a_tput / 2",
+		"bytes":   "bytes * 8",
+		"c_div0":  "bytes / zero",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := map[string]any{"bytes": 100.0, "ms": 4.0, "zero": 0, "c_div0": "kept"}
+	addDerived(msg, fields)
+	// derived fields see the message as logged, not each other
+	want := map[string]any{"bytes": 800.0, "ms": 4.0, "zero": 0, "a_tput": 25.0, "c_div0": "kept"}
+	if !reflect.DeepEqual(msg, want) {
+		t.Errorf("got %v, want %v", msg, want)
+	}
+}
+
+func TestProcessDirDerived(t *testing.T) {
+	dir := writeLogDir(t, map[string]string{
+		"a.log": "{\"bytes\":1000,\"duration_ms\":0}\n{\"bytes\":1000,\"duration_ms\":250}\n",
+	})
+	res, err := processDir(context.Background(), dir, scanOptions{Derived: map[string]string{"tput": "bytes / duration_ms"}}, nil, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Messages) != 2 {
+		t.Fatalf("got %d messages", len(res.Messages))
+	}
+	for _, m := range res.Messages {
+		tput, ok := m["tput"]
+		switch m["duration_ms"] {
+		case 0.0:
+			if ok {
+				t.Errorf("division by zero gave %v", tput)
+			}
+		default:
+			if tput != 4.0 {
+				t.Errorf("got tput %v, want 4", tput)
+			}
+		}
+	}
+	if _, err := processDir(context.Background(), dir, scanOptions{Derived: map[string]string{"x": "a ^ b"}}, nil, 10, 0); err == nil {
+		t.Error("bad expression accepted")
+	}
+}
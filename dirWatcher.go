@@ -0,0 +1,211 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	// dirWatchInterval is how often watched directories are polled when
+	// fsnotify can't watch them
+	dirWatchInterval = time.Second
+	// dirWatchCoalesce is how long fsnotify events are collected before the
+	// directory is looked at, a burst of writes makes one event per file
+	dirWatchCoalesce = 100 * time.Millisecond
+	// dirWatchPolling turns fsnotify off, every directory is polled
+	dirWatchPolling = false
+)
+
+type DirEventKind int
+
+const (
+	DirEventAppend DirEventKind = iota // file grew
+	DirEventCreate                     // new file appeared
+	DirEventRotate                     // file was replaced or truncated
+	DirEventRemove                     // file is gone
+)
+
+type DirEvent struct {
+	Kind DirEventKind
+	Path string
+}
+
+// DirWatcher notifies subscribers about changes of log files of a directory.
+// Changes are found by comparing stats of the files with the previous look,
+// fsnotify only tells when to look. Directories it can't watch, like ones on
+// network filesystems, are polled instead. Every file gets at most one event
+// per look so bursts of writes are coalesced.
+type DirWatcher struct {
+	dir    string
+	mu     sync.Mutex
+	subs   map[chan DirEvent]struct{}
+	files  map[string]os.FileInfo
+	notify *fsnotify.Watcher // nil when polling
+	stop   chan struct{}
+}
+
+var (
+	dirWatchers   = map[string]*DirWatcher{}
+	dirWatchersMu sync.Mutex
+)
+
+// watchDir subscribes to changes of log files in the directory. Watchers are
+// shared between subscribers and stopped once the last one unsubscribes.
+func watchDir(dir string) (<-chan DirEvent, func()) {
+	dirWatchersMu.Lock()
+	defer dirWatchersMu.Unlock()
+	w, ok := dirWatchers[dir]
+	if !ok {
+		w = newDirWatcher(dir)
+		dirWatchers[dir] = w
+		go w.run()
+	}
+	ch := make(chan DirEvent, 64)
+	w.mu.Lock()
+	w.subs[ch] = struct{}{}
+	w.mu.Unlock()
+	return ch, func() {
+		dirWatchersMu.Lock()
+		defer dirWatchersMu.Unlock()
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if _, ok := w.subs[ch]; !ok {
+			return
+		}
+		delete(w.subs, ch)
+		close(ch)
+		if len(w.subs) == 0 {
+			close(w.stop)
+			delete(dirWatchers, dir)
+		}
+	}
+}
+
+// newDirWatcher starts watching before the first look at the directory, so
+// changes made right after watchDir returns are not missed
+func newDirWatcher(dir string) *DirWatcher {
+	w := &DirWatcher{
+		dir:  dir,
+		subs: map[chan DirEvent]struct{}{},
+		stop: make(chan struct{}),
+	}
+	if !dirWatchPolling {
+		n, err := fsnotify.NewWatcher()
+		if err == nil {
+			err = n.Add(dir)
+			if err != nil {
+				n.Close()
+			}
+		}
+		if err != nil {
+			log.Debug().Err(err).Str("dir", dir).Msg("fsnotify unavailable, polling directory")
+		} else {
+			w.notify = n
+		}
+	}
+	w.files = w.snapshot()
+	return w
+}
+
+func (w *DirWatcher) run() {
+	if w.notify == nil {
+		w.runPolling()
+		return
+	}
+	defer w.notify.Close()
+	var look <-chan time.Time
+	for {
+		select {
+		case <-w.stop:
+			return
+		case _, ok := <-w.notify.Events:
+			if !ok {
+				return
+			}
+			if look == nil {
+				look = time.After(dirWatchCoalesce)
+			}
+		case err, ok := <-w.notify.Errors:
+			if !ok {
+				return
+			}
+			// events were dropped, the next look still finds every change
+			log.Warn().Err(err).Str("dir", w.dir).Msg("watching directory")
+			if look == nil {
+				look = time.After(dirWatchCoalesce)
+			}
+		case <-look:
+			look = nil
+			w.poll()
+		}
+	}
+}
+
+func (w *DirWatcher) runPolling() {
+	t := time.NewTicker(dirWatchInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-t.C:
+			w.poll()
+		}
+	}
+}
+
+func (w *DirWatcher) snapshot() map[string]os.FileInfo {
+	ret := map[string]os.FileInfo{}
+	files, err := logFiles(w.dir, scanOptions{})
+	if err != nil {
+		log.Warn().Err(err).Str("dir", w.dir).Msg("watching directory")
+		return ret
+	}
+	for _, fp := range files {
+		st, err := os.Stat(fp)
+		if err != nil {
+			continue
+		}
+		ret[fp] = st
+	}
+	return ret
+}
+
+func (w *DirWatcher) poll() {
+	cur := w.snapshot()
+	events := []DirEvent{}
+	for fp, st := range cur {
+		prev, ok := w.files[fp]
+		switch {
+		case !ok:
+			events = append(events, DirEvent{Kind: DirEventCreate, Path: fp})
+		case !os.SameFile(prev, st) || st.Size() < prev.Size():
+			// logrotate either moves the file away and creates a new one
+			// in its place or truncates it
+			events = append(events, DirEvent{Kind: DirEventRotate, Path: fp})
+		case st.Size() != prev.Size() || !st.ModTime().Equal(prev.ModTime()):
+			events = append(events, DirEvent{Kind: DirEventAppend, Path: fp})
+		}
+	}
+	for fp := range w.files {
+		if _, ok := cur[fp]; !ok {
+			events = append(events, DirEvent{Kind: DirEventRemove, Path: fp})
+		}
+	}
+	w.files = cur
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, e := range events {
+		for ch := range w.subs {
+			select {
+			case ch <- e:
+			default:
+				// slow subscriber, it will catch up on the next change
+			}
+		}
+	}
+}
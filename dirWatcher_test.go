@@ -0,0 +1,178 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// dirWatchBackends runs the test with fsnotify and with polling
+func dirWatchBackends(t *testing.T, fn func(t *testing.T)) {
+	for _, polling := range []bool{false, true} {
+		name := "fsnotify"
+		if polling {
+			name = "polling"
+		}
+		t.Run(name, func(t *testing.T) {
+			prevPolling, prevInterval := dirWatchPolling, dirWatchInterval
+			dirWatchPolling, dirWatchInterval = polling, 20*time.Millisecond
+			t.Cleanup(func() { dirWatchPolling, dirWatchInterval = prevPolling, prevInterval })
+			fn(t)
+		})
+	}
+}
+
+// waitDirEvent skips events until one of kind for path comes
+func waitDirEvent(t *testing.T, ch <-chan DirEvent, kind DirEventKind, path string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-ch:
+			if e.Kind == kind && e.Path == path {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no event %d for %s", kind, path)
+		}
+	}
+}
+
+func appendFile(t *testing.T, fp, s string) {
+	t.Helper()
+	f, err := os.OpenFile(fp, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDirWatcherEvents(t *testing.T) {
+	dirWatchBackends(t, func(t *testing.T) {
+		dir := writeLogDir(t, map[string]string{"a.log": "{}\n"})
+		a := filepath.Join(dir, "a.log")
+		events, unsubscribe := watchDir(dir)
+		defer unsubscribe()
+		dirWatchersMu.Lock()
+		w := dirWatchers[dir]
+		dirWatchersMu.Unlock()
+		if (w.notify == nil) != dirWatchPolling {
+			t.Fatalf("fsnotify in use %v with polling %v", w.notify != nil, dirWatchPolling)
+		}
+
+		appendFile(t, a, "{}\n")
+		waitDirEvent(t, events, DirEventAppend, a)
+
+		b := filepath.Join(dir, "b.log")
+		if err := os.WriteFile(b, []byte("{}\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		waitDirEvent(t, events, DirEventCreate, b)
+
+		// create-on-rotate, a new file takes the place of the old one
+		tmp := filepath.Join(dir, "a.log.new")
+		if err := os.WriteFile(tmp, []byte("{}\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, a); err != nil {
+			t.Fatal(err)
+		}
+		waitDirEvent(t, events, DirEventRotate, a)
+
+		// copytruncate
+		appendFile(t, b, "{}\n{}\n")
+		waitDirEvent(t, events, DirEventAppend, b)
+		if err := os.Truncate(b, 0); err != nil {
+			t.Fatal(err)
+		}
+		waitDirEvent(t, events, DirEventRotate, b)
+
+		if err := os.Remove(a); err != nil {
+			t.Fatal(err)
+		}
+		waitDirEvent(t, events, DirEventRemove, a)
+	})
+}
+
+func TestDirWatcherIgnoresOtherFiles(t *testing.T) {
+	dirWatchBackends(t, func(t *testing.T) {
+		dir := writeLogDir(t, map[string]string{"a.log": "{}\n"})
+		events, unsubscribe := watchDir(dir)
+		defer unsubscribe()
+		if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		a := filepath.Join(dir, "a.log")
+		appendFile(t, a, "{}\n")
+		select {
+		case e := <-events:
+			if e.Path != a {
+				t.Errorf("got event %d for %s", e.Kind, e.Path)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event")
+		}
+	})
+}
+
+func TestDirWatcherCoalesces(t *testing.T) {
+	dirWatchBackends(t, func(t *testing.T) {
+		dir := writeLogDir(t, map[string]string{"a.log": ""})
+		a := filepath.Join(dir, "a.log")
+		events, unsubscribe := watchDir(dir)
+		defer unsubscribe()
+		for range 100 {
+			appendFile(t, a, "{}\n")
+		}
+		waitDirEvent(t, events, DirEventAppend, a)
+		time.Sleep(5 * max(dirWatchCoalesce, dirWatchInterval))
+		n := 1
+		for len(events) > 0 {
+			<-events
+			n++
+		}
+		if n > 10 {
+			t.Errorf("100 appends made %d events", n)
+		}
+	})
+}
+
+func TestDirWatcherUnsubscribe(t *testing.T) {
+	dirWatchBackends(t, func(t *testing.T) {
+		dir := writeLogDir(t, map[string]string{"a.log": ""})
+		first, unsubscribeFirst := watchDir(dir)
+		second, unsubscribeSecond := watchDir(dir)
+		dirWatchersMu.Lock()
+		n := len(dirWatchers)
+		w := dirWatchers[dir]
+		dirWatchersMu.Unlock()
+		if n != 1 || w == nil {
+			t.Fatalf("got %d watchers, want one shared", n)
+		}
+
+		unsubscribeFirst()
+		unsubscribeFirst()
+		if _, ok := <-first; ok {
+			t.Error("channel of unsubscribed is open")
+		}
+		appendFile(t, filepath.Join(dir, "a.log"), "{}\n")
+		waitDirEvent(t, second, DirEventAppend, filepath.Join(dir, "a.log"))
+
+		unsubscribeSecond()
+		dirWatchersMu.Lock()
+		_, ok := dirWatchers[dir]
+		dirWatchersMu.Unlock()
+		if ok {
+			t.Error("watcher left after last subscriber")
+		}
+		select {
+		case <-w.stop:
+		default:
+			t.Error("watcher not stopped")
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"time"
+)
+
+// durationUnits are units DirSettings.DurationFields can name
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+}
+
+// formatDuration renders a numeric duration in unit like 1.2s or 350ms,
+// values that are not numbers are left as is
+func formatDuration(v any, unit string) (string, bool) {
+	f, ok := v.(float64)
+	if !ok {
+		return "", false
+	}
+	mul, ok := durationUnits[unit]
+	if !ok {
+		return "", false
+	}
+	d := time.Duration(f * float64(mul))
+	switch {
+	case d >= time.Second || d <= -time.Second:
+		d = d.Round(time.Millisecond)
+	case d >= time.Millisecond || d <= -time.Millisecond:
+		d = d.Round(time.Microsecond)
+	}
+	return d.String(), true
+}
+
+// withDurations returns msg for display with duration fields formatted,
+// msg itself is not changed
+func withDurations(msg map[string]any, fields map[string]string) map[string]any {
+	if len(fields) == 0 {
+		return msg
+	}
+	ret := maps.Clone(msg)
+	for k, unit := range fields {
+		if s, ok := formatDuration(msg[k], unit); ok {
+			ret[k] = s
+		}
+	}
+	return ret
+}
+
+func validDurationFields(fields map[string]string) error {
+	for k, unit := range fields {
+		if _, ok := durationUnits[unit]; !ok {
+			return fmt.Errorf("duration field %q: unknown unit %q", k, unit)
+		}
+	}
+	return nil
+}
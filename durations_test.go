@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormatDuration(t *testing.T) {
+	for _, c := range []struct {
+		v    any
+		unit string
+		want string
+		ok   bool
+	}{
+		{1200.0, "ms", "1.2s", true},
+		{350.0, "ms", "350ms", true},
+		{1.2e9, "ns", "1.2s", true},
+		{350e6, "ns", "350ms", true},
+		{1500.0, "ns", "1.5µs", true},
+		{1500.0, "us", "1.5ms", true},
+		{2.5e6, "us", "2.5s", true},
+		{90.0, "s", "1m30s", true},
+		{0.25, "s", "250ms", true},
+		{3725.0, "s", "1h2m5s", true},
+		{0.0, "ms", "0s", true},
+		{-1500.0, "ms", "-1.5s", true},
+		// sub-unit noise is rounded away
+		{1234.5678, "ms", "1.235s", true},
+		{1.2345678, "ms", "1.235ms", true},
+		{"350", "ms", "", false},
+		{nil, "ms", "", false},
+		{true, "ms", "", false},
+		{350.0, "h", "", false},
+		{350.0, "", "", false},
+	} {
+		got, ok := formatDuration(c.v, c.unit)
+		if got != c.want || ok != c.ok {
+			t.Errorf("%v %s: got %q, %v, want %q, %v", c.v, c.unit, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestWithDurations(t *testing.T) {
+	msg := map[string]any{"duration_ms": 1200.0, "elapsed": 350e6, "note": "slow", "bad": "x"}
+	got := withDurations(msg, map[string]string{"duration_ms": "ms", "elapsed": "ns", "bad": "s", "missing": "ms"})
+	want := map[string]any{"duration_ms": "1.2s", "elapsed": "350ms", "note": "slow", "bad": "x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if msg["duration_ms"] != 1200.0 {
+		t.Error("raw value changed")
+	}
+	if got := withDurations(msg, nil); !reflect.DeepEqual(got, msg) {
+		t.Errorf("off by default changed the message to %v", got)
+	}
+	if err := validDurationFields(map[string]string{"a": "ms", "b": "us"}); err != nil {
+		t.Error(err)
+	}
+	if err := validDurationFields(map[string]string{"a": "min"}); err == nil {
+		t.Error("unknown unit accepted")
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+// payloads would run or break out of attributes if rendered unescaped
+var xssPayloads = []string{
+	`<script>alert(1)</script>`,
+	`"><img src=x onerror=alert(2)>`,
+	`' onmouseover='alert(3)`,
+}
+
+func TestViewEscapesLogData(t *testing.T) {
+	lines := []string{
+		`{"level":"<script>alert(1)</script>","message":"<script>alert(1)</script>","\"><img src=x onerror=alert(2)>":"' onmouseover='alert(3)"}`,
+		`{"time":"\"><img src=x onerror=alert(2)>","message":"x","<script>alert(1)</script>":{"' onmouseover='alert(3)":1}}`,
+		`<script>alert(1)</script> not JSON`,
+	}
+	dir := writeLogDir(t, map[string]string{"a.log": strings.Join(lines, "\n") + "\n"})
+	withSaved(t, SavedStuff{LogDirs: map[string]map[string]*rules.Rule{dir: {}}})
+
+	for _, path := range []string{
+		"/view/" + url.PathEscape(dir),
+		"/message/" + url.PathEscape(dir) + "?file=a.log&id=" + lineAnchor(lines[0]),
+		"/message/" + url.PathEscape(dir) + "?file=a.log&view=flat&id=" + lineAnchor(lines[1]),
+		"/search?q=" + url.QueryEscape("alert"),
+	} {
+		code, body := get(t, path)
+		if code != 200 {
+			t.Fatalf("%s: status %d: %s", path, code, body)
+		}
+		for _, p := range xssPayloads {
+			if strings.Contains(body, p) {
+				t.Errorf("%s: payload %q rendered unescaped", path, p)
+			}
+		}
+		if !strings.Contains(body, "&lt;script&gt;alert(1)&lt;/script&gt;") {
+			t.Errorf("%s: payload not rendered at all", path)
+		}
+	}
+}
+
+func TestErrorMessagesEscaped(t *testing.T) {
+	dir := writeLogDir(t, map[string]string{"a.log": "x\n"})
+	withSaved(t, SavedStuff{LogDirs: map[string]map[string]*rules.Rule{dir: {}}})
+
+	// bad patterns are quoted back in the error
+	code, body := get(t, "/view/"+url.PathEscape(dir)+"?files="+url.QueryEscape("[<script>alert(1)</script>"))
+	if code != 200 {
+		t.Fatalf("status %d", code)
+	}
+	if strings.Contains(body, "<script>alert(1)</script>") {
+		t.Error("error message rendered unescaped")
+	}
+	if !strings.Contains(body, "&lt;script&gt;") {
+		t.Errorf("error message missing from page: %s", body)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+)
+
+// handleExportNDJSON streams every message passing the view's rule set and
+// filters as one JSON object per line, shaped like the view shows them.
+// Messages come oldest first as they are scanned, limit and offset are
+// ignored and the line cap still applies.
+func handleExportNDJSON(w http.ResponseWriter, r *http.Request) {
+	saved, err := loadSaved()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	p := parseViewParams(r)
+	rule, err := saved.effectiveRule(p)
+	if err != nil {
+		writeJSONError(w, ruleErrorStatus(err), err)
+		return
+	}
+	opts := saved.scanOptions(p).withContext(r.Context())
+	shape, err := opts.shaper()
+	if err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	match := ruleMatcher(rule, opts)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	wrote := false
+	_, err = scanDir(p.Dir, opts, func(fp, line string) error {
+		if match != nil {
+			ok, err := match(fp, line)
+			if err != nil || !ok {
+				return err
+			}
+		}
+		wrote = true
+		return enc.Encode(shape.message(line))
+	})
+	if err == nil {
+		return
+	}
+	if !wrote {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	// status is long sent, the export just ends early
+	log.Warn().Err(err).Str("dir", p.Dir).Msg("exporting ndjson")
+}
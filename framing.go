@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Framings split log files into records. Newline framing is one JSON object
+// per line, RS framing is RFC 7464 JSON text sequences where every record
+// starts with 0x1e and may span several lines.
+const (
+	framingNewline = "newline"
+	framingRS      = "rs"
+)
+
+const recordSeparator = 0x1e
+
+func validFraming(framing string) bool {
+	return framing == "" || framing == framingNewline || framing == framingRS
+}
+
+// splitRS is a bufio.SplitFunc yielding records between record separators
+func splitRS(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	start := 0
+	for start < len(data) && data[start] == recordSeparator {
+		start++
+	}
+	if i := bytes.IndexByte(data[start:], recordSeparator); i >= 0 {
+		return start + i, data[start : start+i], nil
+	}
+	if atEOF && start < len(data) {
+		return len(data), data[start:], nil
+	}
+	if atEOF {
+		return len(data), nil, nil
+	}
+	// request more data
+	return start, nil, nil
+}
+
+// scanRecords sets scanner up for the framing and returns a function turning
+// scanned tokens into lines the rest of the viewer works with. Multi-line
+// RS records are compacted so they look like any other JSON line.
+func scanRecords(scanner *bufio.Scanner, framing string) (func([]byte) (string, bool), error) {
+	switch framing {
+	case "", framingNewline:
+		return func(b []byte) (string, bool) {
+			return trimLine(string(b)), true
+		}, nil
+	case framingRS:
+		scanner.Split(splitRS)
+		return func(b []byte) (string, bool) {
+			b = bytes.TrimSpace(b)
+			if len(b) == 0 {
+				return "", false
+			}
+			buf := bytes.Buffer{}
+			if json.Compact(&buf, b) == nil {
+				return buf.String(), true
+			}
+			return string(b), true
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown framing %q", framing)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+func TestRSFraming(t *testing.T) {
+	big := strings.Repeat("x", 100*1024)
+	for _, c := range []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"one line records", "\x1e{\"a\":1}\n\x1e{\"a\":2}\n", []string{`{"a":1}`, `{"a":2}`}},
+		{"multi-line records", "\x1e{\n  \"a\": 1,\n  \"b\": [\n    1,\n    2\n  ]\n}\n\x1e{\n\"a\":2}\n",
+			[]string{`{"a":1,"b":[1,2]}`, `{"a":2}`}},
+		{"newlines in strings are kept escaped", "\x1e{\"msg\": \"line1\\nline2\"}\n", []string{`{"msg":"line1\nline2"}`}},
+		{"no trailing newline", "\x1e{\"a\":1}\n\x1e{\"a\":2}", []string{`{"a":1}`, `{"a":2}`}},
+		{"repeated and trailing separators", "\x1e\x1e{\"a\":1}\n\x1e\x1e\x1e{\"a\":2}\n\x1e", []string{`{"a":1}`, `{"a":2}`}},
+		{"blank records", "\x1e \n\x1e{\"a\":1}\n\x1e\n\n", []string{`{"a":1}`}},
+		{"invalid JSON kept as text", "\x1e{\"a\":\n\x1e{\"a\":2}\n", []string{`{"a":`, `{"a":2}`}},
+		{"CRLF", "\x1e{\r\n\"a\": 1\r\n}\r\n", []string{`{"a":1}`}},
+		{"record over the initial buffer", "\x1e{\n\"big\": \"" + big + "\"\n}\n", []string{`{"big":"` + big + `"}`}},
+		{"text before the first separator", "junk\n\x1e{\"a\":1}\n", []string{"junk", `{"a":1}`}},
+		{"empty", "", nil},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			dir := writeLogDir(t, map[string]string{"a.log": c.content})
+			var got []string
+			err := scanFile(filepath.Join(dir, "a.log"), scanOptions{Framing: framingRS}, func(fp, line string) error {
+				got = append(got, line)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestRSFramingScans(t *testing.T) {
+	content := ""
+	for _, level := range []string{"info", "error", "info", "error", "warn"} {
+		content += "\x1e{\n  \"level\": \"" + level + "\",\n  \"message\": \"m\"\n}\n"
+	}
+	dir := writeLogDir(t, map[string]string{"a.log": content})
+	rule := &rules.Rule{Op: "eq", Data: map[string]any{"Field": "level", "Value": "error"}}
+	res, err := processDir(context.Background(), dir, scanOptions{Framing: framingRS}, rule, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Scanned != 5 || res.Matched != 2 {
+		t.Errorf("got %d scanned %d matched, want 5 records and 2 matched", res.Scanned, res.Matched)
+	}
+	// newline framing sees the lines of records
+	res, err = processDir(context.Background(), dir, scanOptions{}, rule, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Scanned != 20 || res.Matched != 0 {
+		t.Errorf("newline framing: got %d scanned %d matched", res.Scanned, res.Matched)
+	}
+
+	got, err := tailFile(filepath.Join(dir, "a.log"), 2, scanOptions{Framing: framingRS})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`{"level":"error","message":"m"}`, `{"level":"warn","message":"m"}`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tail got %q, want %q", got, want)
+	}
+
+	if err := scanFile(filepath.Join(dir, "a.log"), scanOptions{Framing: "csv"}, func(fp, line string) error { return nil }); err == nil {
+		t.Error("unknown framing scanned")
+	}
+}
@@ -1,11 +1,13 @@
-module main
+module github.com/maxsupermanhd/json-log-viewer
 
 go 1.23.5
 
 require (
 	github.com/a-h/templ v0.3.960
-	github.com/davecgh/go-spew v1.1.1
+	github.com/fsnotify/fsnotify v1.9.0
+	github.com/klauspost/compress v1.17.11
 	github.com/rs/zerolog v1.34.0
+	golang.org/x/text v0.28.0
 )
 
 require (
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/a-h/templ"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+const (
+	groupByDefaultTop = 20
+	// groupByMaxDistinct bounds memory of high cardinality fields, values
+	// seen after that many distinct ones are counted as other
+	groupByMaxDistinct = 10000
+
+	groupByMissing = "(missing)"
+)
+
+type groupCount struct {
+	Value string
+	Count int
+}
+
+type groupByResult struct {
+	Field   string
+	Scanned int
+	Matched int
+	CapHit  bool
+	// TimedOut scans were stopped by their context, see scanContext
+	TimedOut bool
+	Top      []groupCount // most frequent values, descending
+	Other    int          // matched lines with values outside of Top
+}
+
+// groupBy tallies values of the field over lines passing the rule. Fields
+// are looked up in messages as views show them. When ctx is done the scan
+// stops and what was tallied so far is returned as TimedOut.
+func groupBy(ctx context.Context, dirPath string, opts scanOptions, rule *rules.Rule, field string, top int) (*groupByResult, error) {
+	opts = opts.withContext(ctx)
+	ret := &groupByResult{Field: field}
+	counts := map[string]int{}
+	// grouping by a redacted field must not reveal its values
+	shape, err := opts.shaper()
+	if err != nil {
+		return nil, err
+	}
+	match := ruleMatcher(rule, opts)
+	ret.CapHit, err = scanDir(dirPath, opts, func(fp, line string) error {
+		ret.Scanned++
+		// checked every so often as it takes a lock
+		if ret.Scanned%1024 == 0 && ctx.Err() != nil {
+			ret.TimedOut = true
+			return errScanStopped
+		}
+		if match != nil {
+			ok, err := match(fp, line)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return nil
+			}
+		}
+		ret.Matched++
+		v := groupByMissing
+		if fv, ok := rules.LookupField(shape.message(line), field); ok {
+			v = groupValue(fv)
+		}
+		if _, ok := counts[v]; ok || len(counts) < groupByMaxDistinct {
+			counts[v]++
+		} else {
+			ret.Other++
+		}
+		return nil
+	})
+	// reads of remote sources fail rather than stop when ctx is done
+	if errors.Is(err, errScanStopped) || (err != nil && ctx.Err() != nil) {
+		ret.TimedOut = true
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for v, c := range counts {
+		ret.Top = append(ret.Top, groupCount{Value: v, Count: c})
+	}
+	slices.SortFunc(ret.Top, func(a, b groupCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Value, b.Value))
+	})
+	if len(ret.Top) > top {
+		for _, g := range ret.Top[top:] {
+			ret.Other += g.Count
+		}
+		ret.Top = ret.Top[:top]
+	}
+	return ret, nil
+}
+
+// groupValue is how a value is shown and grouped, objects and arrays are
+// grouped by their JSON
+func groupValue(v any) string {
+	if s, ok := rules.LooseString(v); ok {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func handleGroupBy(w http.ResponseWriter, r *http.Request) {
+	saved, err := loadSaved()
+	if err != nil {
+		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
+		return
+	}
+	p := parseViewParams(r)
+	field := r.URL.Query().Get("field")
+	if field == "" {
+		templ.Handler(tPage(tMessage("field parameter is required"))).ServeHTTP(w, r)
+		return
+	}
+	top := queryInt(r, "top", groupByDefaultTop)
+	if top <= 0 {
+		top = groupByDefaultTop
+	}
+	rule, err := saved.effectiveRule(p)
+	if err != nil {
+		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
+		return
+	}
+	ctx, cancel := saved.Settings.scanContext(r)
+	defer cancel()
+	res, err := groupBy(ctx, p.Dir, saved.scanOptions(p), rule, field, top)
+	if err != nil {
+		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
+		return
+	}
+	templ.Handler(tPage(tGroupBy(p, res))).ServeHTTP(w, r)
+}
+
+func groupByBarSize(res *groupByResult, count int) templ.SafeCSS {
+	size := 0.0
+	if res.Matched > 0 {
+		size = float64(count) / float64(res.Matched)
+	}
+	return templ.SafeCSS(fmt.Sprintf("--size: %.4f;", size))
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestGroupByProjection(t *testing.T) {
+	dir := writeLogDir(t, map[string]string{
+		"a.log": `{"level":"error","trace":"t1"}` + "\n" + `{"level":"error","trace":"t2"}` + "\n" + `{"level":"info"}` + "\n",
+	})
+	opts := scanOptions{Projection: &Projection{Rename: map[string]string{"level": "severity"}, Omit: []string{"trace"}}}
+	res, err := groupBy(context.Background(), dir, opts, nil, "severity", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Top) != 2 || res.Top[0] != (groupCount{Value: "error", Count: 2}) || res.Top[1] != (groupCount{Value: "info", Count: 1}) {
+		t.Errorf("grouped by renamed field: got %+v", res.Top)
+	}
+	res, err = groupBy(context.Background(), dir, opts, nil, "trace", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Top) != 1 || res.Top[0] != (groupCount{Value: groupByMissing, Count: 3}) {
+		t.Errorf("grouped by omitted field: got %+v", res.Top)
+	}
+}
+
+func TestGroupByTimeout(t *testing.T) {
+	dir := writeLogDir(t, map[string]string{"a.log": strings.Repeat(`{"level":"info"}`+"\n", 3000)})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res, err := groupBy(ctx, dir, scanOptions{}, nil, "level", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.TimedOut || res.Scanned >= 3000 {
+		t.Errorf("got %+v, want grouping stopped by timeout", res)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+// rawGzipClient leaves decoding to the test, the default transport would
+// decompress responses itself and hide how they were framed
+var rawGzipClient = &http.Client{Transport: &http.Transport{DisableCompression: true}}
+
+func getGzip(t *testing.T, u string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := rawGzipClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// TestGzipStreaming streams several megabytes through withGzip, the client
+// has to get every flushed chunk before the handler goes on and a gzip
+// stream that ends properly
+func TestGzipStreaming(t *testing.T) {
+	const chunks = 64
+	rnd := rand.New(rand.NewPCG(1, 2))
+	chunk := func(i int) string {
+		// random enough not to compress into nothing
+		var sb strings.Builder
+		for sb.Len() < 64*1024 {
+			fmt.Fprintf(&sb, "{\"chunk\":%d,\"v\":%d}\n", i, rnd.Uint64())
+		}
+		return sb.String()
+	}
+	var want strings.Builder
+	got := make(chan struct{})
+	srv := httptest.NewServer(withGzip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for i := range chunks {
+			s := chunk(i)
+			want.WriteString(s)
+			io.WriteString(w, s)
+			w.(http.Flusher).Flush()
+			if i == 0 {
+				// the first chunk has to reach the client while the handler
+				// is still running
+				<-got
+			}
+		}
+	})))
+	defer srv.Close()
+
+	resp := getGzip(t, srv.URL+"/api/export")
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Content-Encoding is %q", resp.Header.Get("Content-Encoding"))
+	}
+	if resp.ContentLength != -1 || len(resp.TransferEncoding) != 1 || resp.TransferEncoding[0] != "chunked" {
+		t.Errorf("got length %d transfer encoding %v, want chunked", resp.ContentLength, resp.TransferEncoding)
+	}
+	if !strings.Contains(resp.Header.Get("Vary"), "Accept-Encoding") {
+		t.Error("no Vary: Accept-Encoding")
+	}
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(zr)
+	first, err := br.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(got)
+	rest, err := io.ReadAll(br)
+	if err != nil {
+		t.Fatalf("gzip stream is broken: %v", err)
+	}
+	if err := zr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if body := first + string(rest); body != want.String() {
+		t.Fatalf("got %d bytes, want %d", len(body), want.Len())
+	}
+	if want.Len() < 4*1024*1024 {
+		t.Fatalf("only streamed %d bytes", want.Len())
+	}
+}
+
+func TestGzipAPIResponse(t *testing.T) {
+	var content strings.Builder
+	for i := range 5000 {
+		fmt.Fprintf(&content, "{\"message\":\"line %d %s\"}\n", i, strings.Repeat("x", 400))
+	}
+	dir := writeLogDir(t, map[string]string{"a.log": content.String()})
+	withSaved(t, SavedStuff{LogDirs: map[string]map[string]*rules.Rule{dir: {}}})
+	srv := httptest.NewServer(newHandler())
+	defer srv.Close()
+
+	resp := getGzip(t, srv.URL+"/api/view/"+url.PathEscape(dir)+"?limit=5000")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("got %s with Content-Encoding %q", resp.Status, resp.Header.Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct{ Messages []map[string]any }
+	if err := json.NewDecoder(zr).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(io.Discard, zr); err != nil {
+		t.Fatalf("gzip stream is broken: %v", err)
+	}
+	if len(got.Messages) != 5000 {
+		t.Errorf("got %d messages", len(got.Messages))
+	}
+}
+
+func TestGzipNegotiation(t *testing.T) {
+	h := withGzip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("empty") {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		io.WriteString(w, "body")
+	}))
+	for _, c := range []struct {
+		path, accept string
+		gzip         bool
+	}{
+		{"/api/x", "gzip", true},
+		{"/api/x", "br, gzip;q=0.5", true},
+		{"/api/x", "gzip;q=0", false},
+		{"/api/x", "", false},
+		{"/api/x", "deflate", false},
+		{"/api/x?empty=1", "gzip", false},
+		{"/export.ndjson/x", "gzip", true},
+		{"/view/x", "gzip", false},
+		{"/static/style.css", "gzip", false},
+	} {
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		req.Header.Set("Accept-Encoding", c.accept)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got := rec.Header().Get("Content-Encoding") == "gzip"; got != c.gzip {
+			t.Errorf("%s with %q: gzip %v, want %v", c.path, c.accept, got, c.gzip)
+		}
+		if !c.gzip && rec.Code == http.StatusOK && rec.Body.String() != "body" {
+			t.Errorf("%s with %q: got body %q", c.path, c.accept, rec.Body.String())
+		}
+	}
+}
+
+func TestGzipExport(t *testing.T) {
+	var content strings.Builder
+	for i := range 2000 {
+		fmt.Fprintf(&content, "{\"message\":\"line %d\"}\n", i)
+	}
+	dir := writeLogDir(t, map[string]string{"a.log": content.String()})
+	withSaved(t, SavedStuff{LogDirs: map[string]map[string]*rules.Rule{dir: {}}})
+	srv := httptest.NewServer(newHandler())
+	defer srv.Close()
+
+	_, plain := get(t, "/export.ndjson/"+url.PathEscape(dir))
+	resp := getGzip(t, srv.URL+"/export.ndjson/"+url.PathEscape(dir))
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("got %s with Content-Encoding %q", resp.Status, resp.Header.Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != plain || strings.Count(plain, "\n") != 2000 {
+		t.Errorf("got %d bytes decoded, want the %d bytes of the plain export", len(got), len(plain))
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	defaultHexDumpLength = 512
+	maxHexDumpLength     = 64 * 1024
+)
+
+// debugAuthorized checks the bearer token against Settings.DebugToken,
+// debug endpoints are off while it is empty
+func (s Settings) debugAuthorized(r *http.Request) error {
+	if s.DebugToken == "" {
+		return errors.New("debug endpoints are disabled, set Settings.DebugToken to enable them")
+	}
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(s.DebugToken)) != 1 {
+		return errors.New("bad debug token")
+	}
+	return nil
+}
+
+// handleHexDump writes a hex and ASCII dump of a byte range of a log file
+// for when scanning chokes on something, like stray NULs or a partial write.
+// Only files of configured local directories can be dumped and bytes are as
+// they are on disk, compressed files are not decompressed. Parameters are
+// file (base name), offset and length, at most maxHexDumpLength bytes.
+func handleHexDump(w http.ResponseWriter, r *http.Request) {
+	saved, err := loadSaved()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := saved.Settings.debugAuthorized(r); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	dir := r.PathValue("dirName")
+	if _, ok := saved.LogDirs[dir]; !ok || isRemote(dir) {
+		http.Error(w, "unknown directory", http.StatusNotFound)
+		return
+	}
+	files, err := logFiles(dir, scanOptions{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	i := slices.IndexFunc(files, func(fp string) bool { return filepath.Base(fp) == r.URL.Query().Get("file") })
+	if i < 0 {
+		http.Error(w, "unknown file", http.StatusNotFound)
+		return
+	}
+	offset := max(0, queryInt(r, "offset", 0))
+	length := queryInt(r, "length", defaultHexDumpLength)
+	if length <= 0 || length > maxHexDumpLength {
+		http.Error(w, fmt.Sprintf("length has to be between 1 and %d", maxHexDumpLength), http.StatusBadRequest)
+		return
+	}
+	f, err := os.Open(files[i])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	buf := make([]byte, length)
+	n, err := f.ReadAt(buf, int64(offset))
+	if err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	writeHexDump(w, buf[:n], offset)
+}
+
+// writeHexDump is hexdump -C with offsets starting at offset
+func writeHexDump(w io.Writer, b []byte, offset int) {
+	for i := 0; i < len(b); i += 16 {
+		row := b[i:min(i+16, len(b))]
+		hex := strings.Builder{}
+		ascii := strings.Builder{}
+		for j := range 16 {
+			if j == 8 {
+				hex.WriteByte(' ')
+			}
+			if j >= len(row) {
+				hex.WriteString("   ")
+				continue
+			}
+			fmt.Fprintf(&hex, "%02x ", row[j])
+			if row[j] >= 0x20 && row[j] < 0x7f {
+				ascii.WriteByte(row[j])
+			} else {
+				ascii.WriteByte('.')
+			}
+		}
+		fmt.Fprintf(w, "%08x  %s |%s|\n", offset+i, hex.String(), ascii.String())
+	}
+	fmt.Fprintf(w, "%08x\n", offset+len(b))
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/rs/zerolog/log"
+)
+
+// indexDir is what the index shows about a directory, shared by the HTML
+// page and the API so they list the same things
+type indexDir struct {
+	Dir            string       `json:"dir"`
+	GlobalRuleSets []string     `json:"global_rulesets"`
+	DirRuleSets    []string     `json:"dir_rulesets"`
+	HasDefault     bool         `json:"has_default"` // directory has an inline default rule
+	Levels         *levelCounts `json:"levels,omitempty"`
+}
+
+type apiIndexResponse struct {
+	Dirs          []indexDir `json:"dirs"`
+	GlobalDefault bool       `json:"global_default"`
+}
+
+func buildIndex(saved SavedStuff) []indexDir {
+	global := append([]string{}, slices.Sorted(maps.Keys(saved.RuleSets))...)
+	ret := []indexDir{}
+	for _, k := range slices.Sorted(maps.Keys(saved.LogDirs)) {
+		d := indexDir{
+			Dir:            k,
+			GlobalRuleSets: global,
+			DirRuleSets:    append([]string{}, slices.Sorted(maps.Keys(saved.LogDirs[k]))...),
+			HasDefault:     saved.dirSettings(k).Default != nil,
+		}
+		c, err := getLevelCounts(k, saved.Settings.IndexTailLines, saved.dirScanOptions(k))
+		if err != nil {
+			log.Warn().Err(err).Str("dir", k).Msg("counting levels")
+		} else {
+			d.Levels = c
+		}
+		ret = append(ret, d)
+	}
+	return ret
+}
+
+func handleAPIIndex(w http.ResponseWriter, r *http.Request) {
+	saved, err := loadSaved()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, apiIndexResponse{
+		Dirs:          buildIndex(saved),
+		GlobalDefault: saved.Default != nil,
+	})
+}
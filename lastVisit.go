@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+// lastVisitCookie is per directory, directory paths can't be cookie names
+func lastVisitCookie(dirName string) string {
+	sum := sha1.Sum([]byte(dirName))
+	return "lastvisit-" + hex.EncodeToString(sum[:6])
+}
+
+// lastVisit returns when the directory was viewed before and remembers now
+// as the new last visit. Only the first page counts as a visit so that
+// paging through older messages keeps the marker in place.
+func lastVisit(w http.ResponseWriter, r *http.Request, p viewParams) time.Time {
+	name := lastVisitCookie(p.Dir)
+	var ret time.Time
+	if c, err := r.Cookie(name); err == nil {
+		if sec, err := strconv.ParseInt(c.Value, 10, 64); err == nil {
+			ret = time.Unix(sec, 0)
+		}
+	}
+	if p.Offset == 0 {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    strconv.FormatInt(time.Now().Unix(), 10),
+			Path:     "/",
+			MaxAge:   int((30 * 24 * time.Hour).Seconds()),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	return ret
+}
+
+// lastVisitIndex is the index of the first (newest first) message logged
+// before the last visit, where the marker goes. -1 if there is no such
+// message or no last visit.
+func lastVisitIndex(msgs []map[string]any, layouts []string, at time.Time) int {
+	if at.IsZero() {
+		return -1
+	}
+	for i, msg := range msgs {
+		t, ok := rules.ParseTime(msg["time"], layouts)
+		if ok && t.Before(at) {
+			return i
+		}
+	}
+	return -1
+}
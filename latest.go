@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+// latestTailLines is how many last lines of the newest file are looked at
+// for a timestamp, so a trailing line without one doesn't hide it
+const latestTailLines = 16
+
+type apiLatestResponse struct {
+	File string    `json:"file"`
+	Time time.Time `json:"time"`
+	// Source is "line" when Time comes from the last line with a parseable
+	// time and "modtime" when it is modification time of the file
+	Source     string  `json:"source"`
+	AgeSeconds float64 `json:"age_seconds"`
+}
+
+// handleAPILatest tells when the directory last logged something without
+// scanning it, see latestTime
+func handleAPILatest(w http.ResponseWriter, r *http.Request) {
+	saved, err := loadSaved()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	p := parseViewParams(r)
+	ret, err := saved.latestTime(p)
+	switch {
+	case errors.Is(err, errNoLogFiles):
+		writeJSONError(w, http.StatusNotFound, err)
+	case errors.Is(err, errNoLatestTime):
+		writeJSONError(w, http.StatusUnprocessableEntity, err)
+	case err != nil:
+		writeJSONError(w, http.StatusInternalServerError, err)
+	default:
+		writeJSON(w, http.StatusOK, ret)
+	}
+}
+
+var (
+	errNoLogFiles   = errors.New("no log files")
+	errNoLatestTime = errors.New("no parseable time in last lines")
+)
+
+// latestTime only reads the end of the newest file (after files/exclude),
+// falling back to its modification time when none of the last lines has a
+// time
+func (s SavedStuff) latestTime(p viewParams) (apiLatestResponse, error) {
+	p.Newest = true
+	ds := s.dirSettings(p.Dir)
+	opts := s.scanOptions(p)
+	files, err := logFiles(p.Dir, opts)
+	if err != nil {
+		return apiLatestResponse{}, err
+	}
+	if len(files) == 0 {
+		return apiLatestResponse{}, errNoLogFiles
+	}
+	fp := files[0]
+	lines, err := tailFile(fp, latestTailLines, opts)
+	if err != nil {
+		return apiLatestResponse{}, err
+	}
+	ret := apiLatestResponse{File: fp}
+	for i := len(lines) - 1; i >= 0 && ret.Source == ""; i-- {
+		msg := map[string]any{}
+		if json.Unmarshal([]byte(lines[i]), &msg) != nil {
+			continue
+		}
+		if t, ok := rules.ParseTime(msg["time"], ds.TimeLayouts); ok {
+			ret.Time, ret.Source = t, "line"
+		}
+	}
+	if ret.Source == "" {
+		if isRemote(fp) {
+			return apiLatestResponse{}, errNoLatestTime
+		}
+		st, err := os.Stat(fp)
+		if err != nil {
+			return apiLatestResponse{}, err
+		}
+		ret.Time, ret.Source = st.ModTime(), "modtime"
+	}
+	ret.AgeSeconds = time.Since(ret.Time).Seconds()
+	return ret, nil
+}
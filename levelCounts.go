@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultIndexTailLines = 200
+	levelCountsTTL        = time.Minute
+)
+
+type levelCounts struct {
+	Errors int       `json:"errors"`
+	Warns  int       `json:"warns"`
+	At     time.Time `json:"at"`
+}
+
+// levelCountsKey is what counts depend on, counts of the same directory
+// read differently are kept apart
+type levelCountsKey struct {
+	dirPath   string
+	tailLines int
+	framing   string
+}
+
+// levelCountsEntry is the cached counts of one key, its mutex is held while
+// they are counted
+type levelCountsEntry struct {
+	mu      sync.Mutex
+	counts  *levelCounts
+	created time.Time
+}
+
+var (
+	levelCountsCache   = map[levelCountsKey]*levelCountsEntry{}
+	levelCountsCacheMu sync.Mutex // guards the map only, never held while scanning
+)
+
+// getLevelCounts returns error/warn counts from the tails of directory's log
+// files, rescanning once cached counts are older than levelCountsTTL. Counts
+// of one directory are taken one at a time, other directories are not held
+// up by a slow one. Of opts only Framing and how remote sources are fetched
+// apply, see dirScanOptions.
+func getLevelCounts(dirPath string, tailLines int, opts scanOptions) (*levelCounts, error) {
+	if tailLines <= 0 {
+		tailLines = defaultIndexTailLines
+	}
+	e := levelCountsEntryFor(levelCountsKey{dirPath: dirPath, tailLines: tailLines, framing: opts.Framing})
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.counts != nil && time.Since(e.counts.At) < levelCountsTTL {
+		return e.counts, nil
+	}
+	c, err := countLevels(dirPath, tailLines, opts)
+	if err != nil {
+		return nil, err
+	}
+	e.counts = c
+	return c, nil
+}
+
+// levelCountsEntryFor returns the cache entry of the key, creating it if
+// needed. Stale entries that are not being counted are dropped on the way.
+func levelCountsEntryFor(key levelCountsKey) *levelCountsEntry {
+	levelCountsCacheMu.Lock()
+	defer levelCountsCacheMu.Unlock()
+	e, ok := levelCountsCache[key]
+	if ok {
+		return e
+	}
+	for k, old := range levelCountsCache {
+		if !old.mu.TryLock() {
+			continue
+		}
+		at := old.created
+		if old.counts != nil {
+			at = old.counts.At
+		}
+		if time.Since(at) >= levelCountsTTL {
+			delete(levelCountsCache, k)
+		}
+		old.mu.Unlock()
+	}
+	e = &levelCountsEntry{created: time.Now()}
+	levelCountsCache[key] = e
+	return e
+}
+
+func countLevels(dirPath string, tailLines int, opts scanOptions) (*levelCounts, error) {
+	files, err := logFiles(dirPath, scanOptions{})
+	if err != nil {
+		return nil, err
+	}
+	c := &levelCounts{At: time.Now()}
+	for _, fp := range files {
+		lines, err := tailFile(fp, tailLines, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range lines {
+			msg := map[string]any{}
+			if json.Unmarshal([]byte(l), &msg) != nil {
+				continue
+			}
+			switch messageSeverity(msg) {
+			case severityError:
+				c.Errors++
+			case severityWarn:
+				c.Warns++
+			}
+		}
+	}
+	return c, nil
+}
+
+const (
+	severityError = "error"
+	severityWarn  = "warn"
+)
+
+// messageSeverity sorts the level of the message into severityError,
+// severityWarn or "" for anything else
+func messageSeverity(msg map[string]any) string {
+	switch lvl, _ := msg["level"].(string); strings.ToLower(lvl) {
+	case "error", "fatal", "panic":
+		return severityError
+	case "warn", "warning":
+		return severityWarn
+	}
+	return ""
+}
+
+// errorRows are indices of messages at error level, the view links them
+// from its error list and from one error row to the next
+func errorRows(msgs []map[string]any) []int {
+	ret := []int{}
+	for i, msg := range msgs {
+		if messageSeverity(msg) == severityError {
+			ret = append(ret, i)
+		}
+	}
+	return ret
+}
+
+// errorNeighbours are the error rows before and after error row i, -1 when
+// there is none
+func errorNeighbours(rows []int, i int) (prev, next int) {
+	prev, next = -1, -1
+	j, ok := slices.BinarySearch(rows, i)
+	if !ok {
+		return
+	}
+	if j > 0 {
+		prev = rows[j-1]
+	}
+	if j+1 < len(rows) {
+		next = rows[j+1]
+	}
+	return
+}
+
+// tailFile returns up to n last lines of the file, reading it backwards in
+// chunks so only the end of big files is touched. Compressed files can't be
+// seeked and are read whole, as are remote sources and files with records
+// that are not lines. Of opts only Framing and how remote sources are
+// fetched apply.
+func tailFile(fp string, n int, opts scanOptions) ([]string, error) {
+	if lr, _ := logReaderFor(fp); lr.decompress != nil || isRemote(fp) || (opts.Framing != "" && opts.Framing != framingNewline) {
+		buf := NewLogBuffer(n)
+		err := scanFile(fp, opts, func(fp, line string) error {
+			buf.Push(line)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return buf.Get(0, n)
+	}
+	f, err := os.Open(fp)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	const chunkSize = 64 * 1024
+	pos := st.Size()
+	data := []byte{}
+	for pos > 0 && bytes.Count(data, []byte{'\n'}) <= n {
+		readSize := min(chunkSize, pos)
+		pos -= readSize
+		chunk := make([]byte, readSize)
+		_, err = f.ReadAt(chunk, pos)
+		if err != nil {
+			return nil, err
+		}
+		data = append(chunk, data...)
+	}
+	if len(data) == 0 {
+		return []string{}, nil
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if pos > 0 {
+		// first line is cut somewhere in the middle
+		lines = lines[1:]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	for i := range lines {
+		lines[i] = trimLine(lines[i])
+	}
+	return lines, nil
+}
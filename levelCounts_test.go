@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLevelCountsKeyed(t *testing.T) {
+	dir := writeLogDir(t, map[string]string{
+		"a.log": `{"level":"error"}` + "\n" + `{"level":"warn"}` + "\n" + `{"level":"error"}` + "\n",
+	})
+	for _, c := range []struct {
+		tailLines     int
+		framing       string
+		errors, warns int
+	}{
+		{1, "", 1, 0},
+		{2, "", 1, 1},
+		{10, "", 2, 1},
+		{1, "", 1, 0},
+		// the whole file is one record without record separators
+		{10, framingRS, 0, 0},
+	} {
+		got, err := getLevelCounts(dir, c.tailLines, scanOptions{Framing: c.framing})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Errors != c.errors || got.Warns != c.warns {
+			t.Errorf("%d lines %q: got %d errors and %d warnings, want %d and %d", c.tailLines, c.framing, got.Errors, got.Warns, c.errors, c.warns)
+		}
+	}
+}
+
+func TestLevelCountsOtherDirNotBlocked(t *testing.T) {
+	slow := writeLogDir(t, map[string]string{"a.log": "a\n"})
+	other := writeLogDir(t, map[string]string{"a.log": "a\n"})
+
+	// holding the entry is what counting the slow directory does
+	e := levelCountsEntryFor(levelCountsKey{dirPath: slow, tailLines: defaultIndexTailLines})
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	done := make(chan error)
+	go func() {
+		_, err := getLevelCounts(other, 0, scanOptions{})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("counts of another directory waited for the slow one")
+	}
+}
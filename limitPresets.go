@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultLimit = 500
+	limitCookie  = "limit"
+)
+
+var limitPresets = []int{100, 500, 1000, 5000}
+
+// limitPresets are limit links of the view, those over the scan hard cap
+// could never be filled and those over the render cap would never be shown,
+// so they are left out
+func (s SavedStuff) limitPresets() []int {
+	ret := []int{}
+	for _, l := range limitPresets {
+		if hardCap := s.Settings.MaxScanHardCap; hardCap > 0 && l > hardCap {
+			continue
+		}
+		if l > s.Settings.maxRenderRows() {
+			continue
+		}
+		ret = append(ret, l)
+	}
+	return ret
+}
+
+// preferredLimit is the limit picked last time, used when there is none in the URL
+func preferredLimit(r *http.Request) int {
+	c, err := r.Cookie(limitCookie)
+	if err != nil {
+		return defaultLimit
+	}
+	l, err := strconv.Atoi(c.Value)
+	if err != nil || l <= 0 {
+		return defaultLimit
+	}
+	return l
+}
+
+// rememberLimit persists explicitly chosen limit for views opened without one
+func rememberLimit(w http.ResponseWriter, r *http.Request) {
+	l, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || l <= 0 {
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     limitCookie,
+		Value:    strconv.Itoa(l),
+		Path:     "/",
+		MaxAge:   int((365 * 24 * time.Hour).Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+const (
+	// defaultTailMergeDelay is how long merged tails hold lines back waiting
+	// for lines with earlier timestamps from other files
+	defaultTailMergeDelay = 2 * time.Second
+	// mergedelay is clamped to these, lines are looked at every quarter of
+	// the delay but not more often than tailMergeMinTick
+	tailMergeMinDelay = 100 * time.Millisecond
+	tailMergeMaxDelay = time.Minute
+	tailMergeMinTick  = 25 * time.Millisecond
+	tailKeepAlive     = 15 * time.Second
+)
+
+// tailEvent is the data of every server-sent event of a tail
+type tailEvent struct {
+	File    string         `json:"file"`
+	Message map[string]any `json:"message"`
+}
+
+// pendingLine is a line a merged tail holds back
+type pendingLine struct {
+	at      time.Time // log time, arrival time for lines without one
+	seq     int       // arrival order, keeps lines with equal times in order
+	arrived time.Time
+	event   tailEvent
+}
+
+// fileTail reads lines appended to log files since it last looked at them
+type fileTail struct {
+	offsets map[string]int64
+}
+
+// readNew returns complete lines appended to fp since the last read, a
+// trailing line without newline is left for the next read
+func (t *fileTail) readNew(fp string) ([]string, error) {
+	f, err := os.Open(fp)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	_, err = f.Seek(t.offsets[fp], io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	ret := []string{}
+	br := bufio.NewReader(f)
+	for {
+		line, err := br.ReadString('\n')
+		if err == io.EOF {
+			return ret, nil
+		}
+		if err != nil {
+			return ret, err
+		}
+		t.offsets[fp] += int64(len(line))
+		ret = append(ret, trimLine(strings.TrimSuffix(line, "\n")))
+	}
+}
+
+// handleTail streams lines appended to the directory's log files that pass
+// the view's rule set and filters as server-sent events, starting from the
+// current end of files. Plain newline framed, uncompressed local files are
+// followed.
+//
+// Without merge lines of each file come in file order and files in the
+// order the watcher notices them. With merge=true lines are held back for
+// mergedelay (2s by default, clamped between 100ms and a minute) and emitted
+// in log time order, so lines written around the same time to different
+// files come out interleaved correctly. Emitted times never go backwards for
+// lines arriving within the delay of each other, a line arriving later than
+// that after lines with later times were emitted still comes out, out of
+// order. Lines without a parseable time are ordered by their arrival.
+func handleTail(w http.ResponseWriter, r *http.Request) {
+	saved, err := loadSaved()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	p := parseViewParams(r)
+	ds := saved.dirSettings(p.Dir)
+	if isRemote(p.Dir) {
+		http.Error(w, "remote sources can't be tailed", http.StatusBadRequest)
+		return
+	}
+	if ds.Framing != "" && ds.Framing != framingNewline {
+		http.Error(w, "only newline framed logs can be tailed", http.StatusBadRequest)
+		return
+	}
+	rule, err := saved.effectiveRule(p)
+	if err != nil {
+		http.Error(w, err.Error(), ruleErrorStatus(err))
+		return
+	}
+	opts := saved.scanOptions(p)
+	shape, err := opts.shaper()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	merge := r.URL.Query().Get("merge") == "true"
+	delay := queryDuration(r, "mergedelay", defaultTailMergeDelay)
+	delay = min(max(delay, tailMergeMinDelay), tailMergeMaxDelay)
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// subscribe before looking at sizes so no append falls in between
+	events, unsubscribe := watchDir(p.Dir)
+	defer unsubscribe()
+	files, err := tailFiles(p.Dir, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tail := &fileTail{offsets: map[string]int64{}}
+	for _, fp := range files {
+		if st, err := os.Stat(fp); err == nil {
+			tail.offsets[fp] = st.Size()
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	match := ruleMatcher(rule, opts)
+	send := func(e tailEvent) error {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "data: %s\n\n", b)
+		return err
+	}
+	pending := []pendingLine{}
+	seq := 0
+	// flushPending emits held back lines that waited long enough
+	flushPending := func() error {
+		slices.SortStableFunc(pending, func(a, b pendingLine) int {
+			return cmp.Or(a.at.Compare(b.at), cmp.Compare(a.seq, b.seq))
+		})
+		n := 0
+		for n < len(pending) && time.Since(pending[n].arrived) >= delay {
+			if err := send(pending[n].event); err != nil {
+				return err
+			}
+			n++
+		}
+		pending = pending[n:]
+		if n > 0 {
+			flusher.Flush()
+		}
+		return nil
+	}
+	readFile := func(fp string) error {
+		lines, err := tail.readNew(fp)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		for _, line := range lines {
+			if match != nil {
+				ok, err := match(fp, line)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					continue
+				}
+			}
+			e := tailEvent{File: fp, Message: shape.message(line)}
+			if !merge {
+				if err := send(e); err != nil {
+					return err
+				}
+				continue
+			}
+			now := time.Now()
+			at, ok := rules.ParseTime(e.Message["time"], ds.TimeLayouts)
+			if !ok {
+				at = now
+			}
+			seq++
+			pending = append(pending, pendingLine{at: at, seq: seq, arrived: now, event: e})
+		}
+		if !merge && len(lines) > 0 {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	var tickC <-chan time.Time
+	if merge {
+		tick := time.NewTicker(max(delay/4, tailMergeMinTick))
+		defer tick.Stop()
+		tickC = tick.C
+	}
+	keepAlive := time.NewTicker(tailKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			switch ev.Kind {
+			case DirEventCreate, DirEventRotate:
+				files, err = tailFiles(p.Dir, opts)
+				if err != nil {
+					return
+				}
+				tail.offsets[ev.Path] = 0
+			case DirEventRemove:
+				delete(tail.offsets, ev.Path)
+				continue
+			}
+			if !slices.Contains(files, ev.Path) {
+				continue
+			}
+			if err := readFile(ev.Path); err != nil {
+				fmt.Fprintf(w, "event: error\ndata: %s\n\n", strings.ReplaceAll(err.Error(), "\n", " "))
+				flusher.Flush()
+				return
+			}
+		case <-tickC:
+			if err := flushPending(); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// tailFiles are the files a tail follows, compressed ones are left out
+func tailFiles(dirPath string, opts scanOptions) ([]string, error) {
+	files, err := logFiles(dirPath, opts)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(files, func(fp string) bool {
+		lr, _ := logReaderFor(fp)
+		return lr.decompress != nil
+	}), nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+// startTail opens a tail of dir with query and returns its events, the
+// files are watched by the time it returns
+func startTail(t *testing.T, dir, query string) <-chan tailEvent {
+	t.Helper()
+	withSaved(t, SavedStuff{LogDirs: map[string]map[string]*rules.Rule{dir: {}}})
+	srv := httptest.NewServer(newHandler())
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		srv.Close()
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/tail/"+url.PathEscape(dir)+"?"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got %s", resp.Status)
+	}
+	ret := make(chan tailEvent, 100)
+	go func() {
+		defer resp.Body.Close()
+		defer close(ret)
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			data, ok := strings.CutPrefix(sc.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			var e tailEvent
+			if json.Unmarshal([]byte(data), &e) == nil {
+				ret <- e
+			}
+		}
+	}()
+	return ret
+}
+
+// tailMessages waits for n events and returns their message fields
+func tailMessages(t *testing.T, events <-chan tailEvent, n int) []string {
+	t.Helper()
+	ret := []string{}
+	timeout := time.After(10 * time.Second)
+	for len(ret) < n {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				t.Fatalf("tail ended after %q", ret)
+			}
+			ret = append(ret, e.Message["message"].(string))
+		case <-timeout:
+			t.Fatalf("got %q, want %d messages", ret, n)
+		}
+	}
+	return ret
+}
+
+func tailLine(sec int, msg string) string {
+	return `{"time":"2024-03-01T10:00:0` + string(rune('0'+sec)) + `Z","message":"` + msg + `"}` + "\n"
+}
+
+func TestTailFileOrder(t *testing.T) {
+	dir := writeLogDir(t, map[string]string{"a.log": ""})
+	events := startTail(t, dir, "")
+	appendFile(t, filepath.Join(dir, "a.log"), tailLine(3, "c")+tailLine(1, "a")+tailLine(2, "b"))
+	if got, want := tailMessages(t, events, 3), []string{"c", "a", "b"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want file order %q", got, want)
+	}
+}
+
+// TestTailMergeOrder checks lines arriving within the merge delay of each
+// other come out in time order across files
+func TestTailMergeOrder(t *testing.T) {
+	dir := writeLogDir(t, map[string]string{"a.log": "", "b.log": ""})
+	events := startTail(t, dir, "merge=true&mergedelay=1s")
+	appendFile(t, filepath.Join(dir, "b.log"), tailLine(4, "d")+tailLine(2, "b"))
+	appendFile(t, filepath.Join(dir, "a.log"), tailLine(3, "c")+tailLine(1, "a"))
+	appendFile(t, filepath.Join(dir, "b.log"), tailLine(5, "e"))
+	if got, want := tailMessages(t, events, 5), []string{"a", "b", "c", "d", "e"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want time order %q", got, want)
+	}
+}
+
+// TestTailMergeDelayClamped used to panic on a ticker of zero
+func TestTailMergeDelayClamped(t *testing.T) {
+	for _, delay := range []string{"3ns", "1ms", "1000h"} {
+		t.Run(delay, func(t *testing.T) {
+			dir := writeLogDir(t, map[string]string{"a.log": ""})
+			events := startTail(t, dir, "merge=true&mergedelay="+delay)
+			if delay == "1000h" {
+				// held back for the longest delay, only check the tail is up
+				return
+			}
+			start := time.Now()
+			appendFile(t, filepath.Join(dir, "a.log"), tailLine(3, "c")+tailLine(1, "a")+tailLine(2, "b"))
+			if got, want := tailMessages(t, events, 3), []string{"a", "b", "c"}; !slices.Equal(got, want) {
+				t.Errorf("got %q, want time order %q", got, want)
+			}
+			if took := time.Since(start); took < tailMergeMinDelay {
+				t.Errorf("lines came out after %v, sooner than the shortest delay", took)
+			}
+		})
+	}
+}
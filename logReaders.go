@@ -0,0 +1,79 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+type logReader struct {
+	suffix     string
+	decompress func(r io.Reader) (io.ReadCloser, error) // nil for plain text
+}
+
+// logReaders are recognized log file kinds, a file is read with the first one
+// whose suffix its name has
+var logReaders = []logReader{
+	{suffix: ".log"},
+	{suffix: ".log.gz", decompress: func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	}},
+	{suffix: ".log.zst", decompress: func(r io.Reader) (io.ReadCloser, error) {
+		// files are read line by line, decoding ahead in other goroutines
+		// only costs memory
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	}},
+}
+
+func logReaderFor(name string) (logReader, bool) {
+	for _, r := range logReaders {
+		if strings.HasSuffix(name, r.suffix) {
+			return r, true
+		}
+	}
+	return logReader{}, false
+}
+
+// logFile closes both the decompressor and the file or body under it
+type logFile struct {
+	io.ReadCloser
+	f io.Closer
+}
+
+func (l logFile) Close() error {
+	l.ReadCloser.Close()
+	return l.f.Close()
+}
+
+// openLogFile opens the file decompressing it if its kind requires that,
+// remote sources are fetched as remote says instead
+func openLogFile(fp string, remote *remoteSource) (io.ReadCloser, error) {
+	if isRemote(fp) {
+		return openRemote(fp, remote)
+	}
+	lr, ok := logReaderFor(fp)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a recognized log file", fp)
+	}
+	f, err := os.Open(fp)
+	if err != nil {
+		return nil, err
+	}
+	if lr.decompress == nil {
+		return f, nil
+	}
+	r, err := lr.decompress(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("decompressing %s: %w", fp, err)
+	}
+	return logFile{ReadCloser: r, f: f}, nil
+}
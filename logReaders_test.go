@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func gzipped(t *testing.T, s string) string {
+	t.Helper()
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	if _, err := w.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func zstded(t *testing.T, s string) string {
+	t.Helper()
+	w, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	return string(w.EncodeAll([]byte(s), nil))
+}
+
+func TestScanCompressedFiles(t *testing.T) {
+	content := "{\"msg\":\"one\"}\n{\"msg\":\"two\"}\n"
+	dir := writeLogDir(t, map[string]string{
+		"plain.log":       content,
+		"rotated.log.gz":  gzipped(t, content),
+		"rotated.log.zst": zstded(t, content),
+	})
+	for _, name := range []string{"plain.log", "rotated.log.gz", "rotated.log.zst"} {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			err := scanFile(filepath.Join(dir, name), scanOptions{}, func(fp, line string) error {
+				got = append(got, line)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []string{`{"msg":"one"}`, `{"msg":"two"}`}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestProcessDirZstd(t *testing.T) {
+	dir := writeLogDir(t, map[string]string{
+		"app.log":       "{\"msg\":\"current\"}\n",
+		"app.1.log.zst": zstded(t, "{\"msg\":\"rotated\"}\n"),
+	})
+	res, err := processDir(context.Background(), dir, scanOptions{}, nil, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range res.Messages {
+		got = append(got, m["msg"].(string))
+	}
+	sort.Strings(got)
+	want := []string{"current", "rotated"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCorruptCompressedFileErrorNamesFile(t *testing.T) {
+	content := strings.Repeat("{\"msg\":\"line\"}\n", 1000)
+	truncated := zstded(t, content)
+	dir := writeLogDir(t, map[string]string{
+		"bad.log.gz":        "not gzip",
+		"bad.log.zst":       "not zstd",
+		"truncated.log.zst": truncated[:len(truncated)/2],
+	})
+	for _, name := range []string{"bad.log.gz", "bad.log.zst", "truncated.log.zst"} {
+		t.Run(name, func(t *testing.T) {
+			fp := filepath.Join(dir, name)
+			err := scanFile(fp, scanOptions{}, func(fp, line string) error { return nil })
+			if err == nil {
+				t.Fatal("no error")
+			}
+			if !strings.Contains(err.Error(), fp) {
+				t.Errorf("error %q does not name %s", err, fp)
+			}
+		})
+	}
+}
@@ -2,248 +2,1082 @@ package main
 
 import (
 	"bufio"
-	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"maps"
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/a-h/templ"
-	"github.com/davecgh/go-spew/spew"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+// ruleOps are ops rules are evaluated with
+var ruleOps = rules.DefaultOps()
+
+var (
+	ruleErrorPreview = flag.Int("rule-error-preview", 80, "how many characters of a line to log when rule evaluation fails")
+	ruleErrorVerbose = flag.Bool("rule-error-verbose", false, "log whole lines when rule evaluation fails")
+	setFileDir       = flag.String("set-dir", rules.DefaultSetFileDir, "directory inset rules read set files from")
 )
 
 func main() {
+	flag.Parse()
+	ruleOps["inset"] = rules.InSetOp(*setFileDir)
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 	log.Info().Msg("hello world")
 
+	listenAddr := ":9172"
+	log.Info().Str("addr", listenAddr).Msg("listening")
+	log.Err(http.ListenAndServe(listenAddr, newHandler())).Msg("handle")
+}
+
+// newHandler routes all pages and endpoints, config is read from saved.json
+// in the working directory as requests come
+func newHandler() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", http.NotFound)
 	mux.HandleFunc("/{$}", handleIndex)
 	mux.HandleFunc("/view/{dirName}", handleLogDir)
 	mux.HandleFunc("/view/{dirName}/{ruleSetName}", handleLogDir)
+	mux.HandleFunc("/search", handleSearch)
+	mux.HandleFunc("GET /view/{dirName}/{ruleSetName}/message", handleMessage)
+	mux.HandleFunc("GET /message/{dirName}", handleMessage)
+	mux.HandleFunc("GET /export.ndjson/{dirName}", handleExportNDJSON)
+	mux.HandleFunc("GET /tail/{dirName}", handleTail)
+	mux.HandleFunc("GET /tail/{dirName}/{ruleSetName}", handleTail)
+	mux.HandleFunc("GET /export.ndjson/{dirName}/{ruleSetName}", handleExportNDJSON)
+	mux.HandleFunc("GET /bookmarks", handleBookmarks)
+	mux.HandleFunc("GET /status", handleStatus)
+	mux.HandleFunc("POST /bookmarks", handleBookmarkAdd)
+	mux.HandleFunc("POST /bookmarks/remove", handleBookmarkRemove)
+	mux.HandleFunc("GET /theme/{name}", handleTheme)
+	mux.HandleFunc("/stats/{dirName}", handleRuleStats)
+	mux.HandleFunc("/stats/{dirName}/metrics", handleRuleStatsMetrics)
+	mux.HandleFunc("GET /diff/{dirName}/{ruleSetA}/{ruleSetB}", handleRuleDiff)
+	mux.HandleFunc("GET /groupby/{dirName}", handleGroupBy)
+	mux.HandleFunc("GET /timeline/{dirName}", handleTimeline)
+	mux.HandleFunc("GET /timeline/{dirName}/{ruleSetName}", handleTimeline)
+	mux.HandleFunc("GET /groupby/{dirName}/{ruleSetName}", handleGroupBy)
+	mux.HandleFunc("GET /api/view/{dirName}", handleAPIView)
+	mux.HandleFunc("GET /api/view/{dirName}/{ruleSetName}", handleAPIView)
+	mux.HandleFunc("GET /api/count/{dirName}", handleAPICount)
+	mux.HandleFunc("GET /api/count/{dirName}/{ruleSetName}", handleAPICount)
+	mux.HandleFunc("POST /api/explain-rule", handleAPIExplainRule)
+	mux.HandleFunc("GET /api/rule/{ruleSetName}/compiled", handleAPIRuleCompiled)
+	mux.HandleFunc("POST /api/reload", handleAPIReload)
+	mux.HandleFunc("GET /api/index", handleAPIIndex)
+	mux.HandleFunc("GET /api/stats/{dirName}", handleAPIRuleStats)
+	mux.HandleFunc("GET /api/latest/{dirName}", handleAPILatest)
+	mux.HandleFunc("GET /api/snapshots", handleSnapshots)
+	mux.HandleFunc("POST /api/snapshots/{dirName}", handleSnapshotCreate)
+	mux.HandleFunc("POST /api/snapshots/{dirName}/{ruleSetName}", handleSnapshotCreate)
+	mux.HandleFunc("GET /api/snapshots/{name}/diff", handleSnapshotDiff)
+	mux.HandleFunc("GET /metrics", handleMetrics)
+	mux.HandleFunc("GET /debug/hexdump/{dirName}", handleHexDump)
 	mux.Handle("/static/style.css", triviaFileServer{fp: "static/style.css"})
 	mux.Handle("/static/charts.min.css", triviaFileServer{fp: "static/charts.min.css"})
+	return withTheme(withGzip(mux))
+}
 
-	listenAddr := ":9172"
-	log.Info().Str("addr", listenAddr).Msg("listening")
-	log.Err(http.ListenAndServe(listenAddr, mux)).Msg("handle")
+type SavedStuff struct {
+	RuleSets    map[string]*rules.Rule
+	LogDirs     map[string]map[string]*rules.Rule
+	DirSettings map[string]*DirSettings
+	Constants   map[string]string // substituted for ${NAME} placeholders in rules
+	Default     *rules.Rule       // applied to every view, see effectiveRule
+	Settings    Settings
+	// RuleSetViews are display bundles of rule sets by rule set name, see
+	// viewDisplay.go
+	RuleSetViews map[string]*ViewDisplay
+
+	// remoteRuleSets are names of RuleSets that came from RemoteConfig, see
+	// resolveRuleSet
+	remoteRuleSets map[string]bool
 }
 
-type Rule struct {
-	Op   string
-	Data any
+// DirSettings describe how logs of a particular directory look like
+type DirSettings struct {
+	Default      *rules.Rule // used when no rule set is selected and there is no rule set named ""
+	TimeLayouts  []string    // tried in order when parsing the time field
+	MaxScan      int         // default cap of newest lines views look at, 0 for no cap
+	Projection   *Projection
+	RawBytes     bool   // for logs with binary junk, see scanOptions
+	MessageField string // shown as the message text, defaults to "message"
+	Framing      string // how records are split, "newline" (default) or "rs", see framing.go
+	// DurationFields are shown human-readable, keys are field names and
+	// values their unit: ns, us, ms or s
+	DurationFields map[string]string
+	PinnedFields   []string // shown in this order as the first columns, dotted paths are fine
+	// DerivedFields are added to messages for display, keys are names and
+	// values expressions like "bytes / duration_ms", see derived.go
+	DerivedFields map[string]string
+	// Headers are sent along when the directory is an HTTP(S) URL, like
+	// Authorization, see remoteSource.go
+	Headers map[string]string
+	// Redact masks sensitive values in everything shown, field paths are
+	// as found in logs before Projection, see redaction.go
+	Redact *Redaction
+	// StackFields are shown as stack traces, with runs of repeated lines
+	// and of lines matching any of StackCollapse regular expressions (like
+	// "/vendor/" or "^net/http\\.") folded, see stackTrace.go
+	StackFields   []string
+	StackCollapse []string
 }
 
-func (r Rule) Run(rules ruleset, arg any) (bool, error) {
-	op, ok := rules[r.Op]
-	if !ok {
-		return false, fmt.Errorf("run rule op %q not found", r.Op)
+const defaultMessageField = "message"
+
+func (ds DirSettings) messageField() string {
+	if ds.MessageField == "" {
+		return defaultMessageField
 	}
-	return op(rules, r.Data, arg)
+	return ds.MessageField
 }
 
-func ruleDataToRule(data any) (ret Rule, err error) {
-	obj, ok := data.(map[string]any)
-	if !ok {
-		return ret, fmt.Errorf("data to rule: %q not an object", data)
+func (s SavedStuff) dirSettings(dirName string) DirSettings {
+	ret, ok := s.DirSettings[dirName]
+	if !ok || ret == nil {
+		return DirSettings{}
 	}
-	ret.Op, ok = obj["Op"].(string)
-	if !ok {
-		return ret, fmt.Errorf("data to rule: Op %q not a string", obj["Op"])
+	return *ret
+}
+
+// Settings are operator knobs, zero values mean defaults
+type Settings struct {
+	IndexTailLines  int      // how many last lines of each file index badges look at
+	SearchTailLines int      // how many last lines of each file global search looks at
+	LinkFields      []string // fields rendered as links filtering by their value
+	MaxScanHardCap  int      // lines cap requests can't go over, 0 for no cap
+	MaxRenderRows   int      // rows the HTML view renders per page at most, 0 for defaultMaxRenderRows
+	BookmarksFile   string   // where bookmarked lines are kept, empty disables bookmarks
+	ScanCacheSize   int      // view scan results kept, 0 disables the cache, see scanCache.go
+	ScanCacheTTL    int      // seconds scan results are kept at most, 0 for defaultScanCacheTTL
+	// RequestTimeout is how many seconds view scans may take before they
+	// stop and show what they found so far, 0 for defaultRequestTimeout and
+	// -1 for no limit
+	RequestTimeout int
+	// MaxMessageLength cuts long messages in the view's rows to this many
+	// characters, 0 for defaultMaxMessageLength and -1 to never cut
+	MaxMessageLength int
+	// ReorderRules evaluates cheap ops of and and or rules first, OpCosts
+	// overrides rules.DefaultOpCosts, see rules.Reorder
+	ReorderRules bool
+	OpCosts      map[string]int
+	// DebugToken is the bearer token debug endpoints like /debug/hexdump
+	// and snapshot creation want, empty disables them
+	DebugToken string
+	// StatusRefresh is how many seconds the status page reloads after, 0
+	// for defaultStatusRefresh and -1 for never. Directories that didn't
+	// log for StaleAfter seconds (0 for defaultStaleAfter) show as stale.
+	StatusRefresh int
+	StaleAfter    int
+	// RemoteConfig adds centrally managed rule sets, see remoteConfig.go
+	RemoteConfig *RemoteConfig
+	// SnapshotDir is where view snapshots to diff against are kept, empty
+	// disables them, see snapshots.go
+	SnapshotDir string
+}
+
+// defaultMaxRenderRows keeps browsers responsive, the API is not capped by it
+const defaultMaxRenderRows = 5000
+
+func (s Settings) maxRenderRows() int {
+	if s.MaxRenderRows <= 0 {
+		return defaultMaxRenderRows
 	}
-	ret.Data = obj["Data"]
-	return ret, nil
+	return s.MaxRenderRows
 }
 
-type ruleOpFn func(rules ruleset, data, arg any) (bool, error)
+// defaultMaxMessageLength keeps rows of the view a sane height
+const defaultMaxMessageLength = 500
 
-type ruleset map[string]ruleOpFn
+const defaultRequestTimeout = time.Minute
 
-var (
-	definedRuleOps = ruleset{
-		"not": func(rules ruleset, data, arg any) (bool, error) {
-			d, err := ruleDataToRule(data)
-			if err != nil {
-				return false, fmt.Errorf("rule not: data is not rule: %w", err)
-			}
-			ret, err := d.Run(rules, arg)
-			return !ret, err
-		},
-		"or": func(rules ruleset, data, arg any) (bool, error) {
-			els, ok := data.([]any)
-			if !ok {
-				return false, fmt.Errorf("rule or: data is not array (%q)", spew.Sdump(data))
-			}
-			for i, el := range els {
-				d, err := ruleDataToRule(el)
-				if err != nil {
-					return false, fmt.Errorf("rule or: data %d is not rule: %w", i, err)
-				}
-				ret, err := d.Run(rules, arg)
-				if err != nil {
-					return ret, fmt.Errorf("running or rule %d: %w", i, err)
-				}
-				if ret {
-					return true, nil
-				}
-			}
-			return false, nil
-		},
-		"and": func(rules ruleset, data, arg any) (bool, error) {
-			els, ok := data.([]any)
-			if !ok {
-				return false, fmt.Errorf("rule and: data is not array (%q)", spew.Sdump(data))
-			}
-			for i, el := range els {
-				d, err := ruleDataToRule(el)
-				if err != nil {
-					return false, fmt.Errorf("rule and: data %d is not rule: %w", i, err)
-				}
-				ret, err := d.Run(rules, arg)
-				if err != nil {
-					return ret, fmt.Errorf("running and rule %d: %w", i, err)
-				}
-				if !ret {
-					return false, nil
-				}
-			}
-			return true, nil
-		},
-		"contains": func(rules ruleset, data, arg any) (bool, error) {
-			d, ok := arg.(string)
-			if !ok {
-				return false, errors.New("rule contains: arg is not string")
-			}
-			check, ok := data.(string)
-			if !ok {
-				return false, errors.New("rule contains: data is not string")
-			}
-			return strings.Contains(d, check), nil
-		},
+// scanContext bounds scans of the request by RequestTimeout, they also stop
+// when the client goes away
+func (s Settings) scanContext(r *http.Request) (context.Context, context.CancelFunc) {
+	switch {
+	case s.RequestTimeout < 0:
+		return context.WithCancel(r.Context())
+	case s.RequestTimeout == 0:
+		return context.WithTimeout(r.Context(), defaultRequestTimeout)
 	}
-)
+	return context.WithTimeout(r.Context(), time.Duration(s.RequestTimeout)*time.Second)
+}
 
-type SavedStuff struct {
-	RuleSets map[string]*Rule
-	LogDirs  map[string]map[string]*Rule
+// displaySettings control how messages are rendered in the view
+type displaySettings struct {
+	LinkFields       []string
+	MessageField     string
+	DurationFields   map[string]string
+	PinnedFields     []string
+	LimitPresets     []int
+	LastVisit        int      // index of the message "new since last visit" marker is above, -1 for none
+	Filters          []string // what filters the view, empty when every line is shown
+	RowsCapped       int      // limit asked for when it was over MaxRenderRows, 0 otherwise
+	MaxMessageLength int      // message text in rows is cut past this, 0 for never
+	HiddenFields     []string // left out of params, see ViewDisplay
+	TimeFormat       string   // layout times are shown in, empty for as logged
+	Anchors          []string // element ids of the rows, see messageAnchors
+	ErrorRows        []int    // see errorRows
+	TimeLayouts      []string
+	StackFields      []string
+	StackCollapse    []*regexp.Regexp
 }
 
-func handleIndex(w http.ResponseWriter, r *http.Request) {
-	saved := SavedStuff{}
-	must(json.NewDecoder(bytes.NewReader(noerr(os.ReadFile("saved.json")))).Decode(&saved))
-	templ.Handler(tPage(tIndex(saved))).ServeHTTP(w, r)
+var defaultLinkFields = []string{"trace_id", "request_id"}
+
+// paramsHidden are fields shown elsewhere in the row and left out of params
+func (ds displaySettings) paramsHidden() []string {
+	return slices.Concat(ds.LinkFields, ds.PinnedFields, ds.HiddenFields, ds.StackFields)
 }
 
-func handleLogDir(w http.ResponseWriter, r *http.Request) {
-	savedBytes, err := os.ReadFile("saved.json")
+func (s SavedStuff) displaySettings(dirName string) displaySettings {
+	ret := displaySettings{
+		LinkFields:     s.Settings.LinkFields,
+		MessageField:   s.dirSettings(dirName).messageField(),
+		DurationFields: s.dirSettings(dirName).DurationFields,
+		PinnedFields:   s.dirSettings(dirName).PinnedFields,
+		TimeLayouts:    s.dirSettings(dirName).TimeLayouts,
+		StackFields:    s.dirSettings(dirName).StackFields,
+		StackCollapse:  compileStackCollapse(s.dirSettings(dirName).StackCollapse),
+		LimitPresets:   s.limitPresets(),
+		LastVisit:      -1,
+	}
+	switch l := s.Settings.MaxMessageLength; {
+	case l == 0:
+		ret.MaxMessageLength = defaultMaxMessageLength
+	case l > 0:
+		ret.MaxMessageLength = l
+	}
+	if ret.LinkFields == nil {
+		ret.LinkFields = defaultLinkFields
+	}
+	return ret
+}
+
+func handleIndex(w http.ResponseWriter, r *http.Request) {
+	saved, err := loadSaved()
 	if err != nil {
 		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
 		return
 	}
-	saved := SavedStuff{}
+	templ.Handler(tPage(tIndex(buildIndex(saved)))).ServeHTTP(w, r)
+}
+
+func loadSaved() (saved SavedStuff, err error) {
+	savedBytes, err := os.ReadFile("saved.json")
+	if err != nil {
+		return saved, err
+	}
 	err = json.Unmarshal(savedBytes, &saved)
+	if err != nil {
+		return saved, err
+	}
+	if rc := saved.Settings.RemoteConfig; rc != nil && rc.URL != "" {
+		// failures are logged, local config alone is still usable
+		remote, _ := rc.current()
+		saved = saved.withRemote(remote)
+	}
+	return saved, nil
+}
+
+// viewParams describe what page of which directory is being viewed
+type viewParams struct {
+	Dir       string
+	RuleSet   string
+	Limit     int
+	Offset    int
+	Step      int
+	Files     string // glob of file names to look at, empty for all
+	Exclude   string // glob of file names to skip, see scanOptions
+	MaxScan   int    // how many newest lines to look at, 0 for default
+	Rule      string // ad-hoc rule as JSON, applied on top of the rule set
+	NoDefault bool   // skip global default rule
+	Refresh   int    // reload the newest page every this many seconds, 0 for off
+	Newest    bool   // only look at the newest file, see scanOptions
+	FromLine  int    // 1-based line range of a single file view, see scanOptions
+	ToLine    int
+	// display overrides, see ViewDisplay, lists are comma-separated
+	Columns      string
+	Hide         string
+	MessageField string
+	TimeFormat   string
+	// FileCounts breaks scan counts down by file
+	FileCounts bool
+	Sort       string // field messages are sorted by, see scanOptions
+	SortAsc    bool
+}
+
+// minRefresh keeps auto-refreshing views from rescanning all the time
+const minRefresh = 2
+
+func parseViewParams(r *http.Request) viewParams {
+	ret := viewParams{
+		Dir:       r.PathValue("dirName"),
+		RuleSet:   r.PathValue("ruleSetName"),
+		Limit:     queryInt(r, "limit", preferredLimit(r)),
+		Offset:    queryInt(r, "offset", 0),
+		Step:      queryInt(r, "step", 500),
+		Files:     r.URL.Query().Get("files"),
+		Exclude:   r.URL.Query().Get("exclude"),
+		MaxScan:   queryInt(r, "maxscan", 0),
+		Rule:      r.URL.Query().Get("rule"),
+		NoDefault: r.URL.Query().Get("nodefault") != "",
+		Refresh:   max(0, queryInt(r, "refresh", 0)),
+		Newest:    r.URL.Query().Get("newest") != "",
+		FromLine:  max(0, queryInt(r, "fromLine", 0)),
+		ToLine:    max(0, queryInt(r, "toLine", 0)),
+
+		Columns:      r.URL.Query().Get("columns"),
+		Hide:         r.URL.Query().Get("hide"),
+		MessageField: r.URL.Query().Get("msgfield"),
+		TimeFormat:   r.URL.Query().Get("timefmt"),
+		FileCounts:   r.URL.Query().Get("filecounts") != "",
+		Sort:         r.URL.Query().Get("sort"),
+		SortAsc:      r.URL.Query().Get("sortdir") == "asc",
+	}
+	if ret.Refresh > 0 && ret.Refresh < minRefresh {
+		ret.Refresh = minRefresh
+	}
+	return ret
+}
+
+// scanOptions for the view, line cap defaults to directory's MaxScan and
+// can't go over operator's MaxScanHardCap
+func (s SavedStuff) scanOptions(p viewParams) scanOptions {
+	maxLines := p.MaxScan
+	if maxLines <= 0 {
+		maxLines = s.dirSettings(p.Dir).MaxScan
+	}
+	if hardCap := s.Settings.MaxScanHardCap; hardCap > 0 && (maxLines <= 0 || maxLines > hardCap) {
+		maxLines = hardCap
+	}
+	ds := s.dirSettings(p.Dir)
+	// lines that are not JSON are put where the view will look for them
+	messageField := s.viewDisplaySettings(p).MessageField
+	return scanOptions{
+		Files:        p.Files,
+		Exclude:      p.Exclude,
+		MaxLines:     maxLines,
+		Projection:   ds.Projection,
+		RawBytes:     ds.RawBytes,
+		MessageField: messageField,
+		Framing:      ds.Framing,
+		NewestOnly:   p.Newest,
+		FromLine:     p.FromLine,
+		ToLine:       p.ToLine,
+		FileCounts:   p.FileCounts,
+		SortField:    p.Sort,
+		SortAsc:      p.SortAsc,
+		Derived:      ds.DerivedFields,
+		Redact:       ds.Redact,
+		TimeLayouts:  ds.TimeLayouts,
+		remote:       s.remoteSource(p.Dir),
+	}
+}
+
+// dirScanOptions read the whole directory as configured, without anything
+// views narrow it down with
+func (s SavedStuff) dirScanOptions(dirName string) scanOptions {
+	ds := s.dirSettings(dirName)
+	return scanOptions{Framing: ds.Framing, TimeLayouts: ds.TimeLayouts, remote: s.remoteSource(dirName)}
+}
+
+func handleLogDir(w http.ResponseWriter, r *http.Request) {
+	saved, err := loadSaved()
 	if err != nil {
 		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
 		return
 	}
-	dirName := r.PathValue("dirName")
-	ruleSetName := r.PathValue("ruleSetName")
-	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	p := parseViewParams(r)
+	requested := p.Limit
+	p.Limit = min(p.Limit, saved.Settings.maxRenderRows())
+	ctx, cancel := saved.Settings.scanContext(r)
+	defer cancel()
+
+	rule, err := saved.effectiveRule(p)
 	if err != nil {
-		limit = 500
+		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
+		return
 	}
-	offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+
+	res, err := saved.processView(ctx, p, rule)
 	if err != nil {
-		offset = 0
+		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
+		return
 	}
-	step, err := strconv.Atoi(r.URL.Query().Get("step"))
+
+	rememberLimit(w, r)
+	if p.Refresh > 0 {
+		// plain header refresh works through proxies that break streaming,
+		// it always goes to the first page to show the newest lines
+		w.Header().Set("Refresh", strconv.Itoa(p.Refresh)+"; url="+p.withOffset(0).url())
+	}
+	ds := saved.viewDisplaySettings(p)
+	if p.Sort == "" {
+		// the marker only makes sense with newest first order
+		ds.LastVisit = lastVisitIndex(res.Messages, saved.dirSettings(p.Dir).TimeLayouts, lastVisit(w, r, p))
+	}
+	ds.Filters = saved.activeFilters(p)
+	ds.Anchors = messageAnchors(res.Lines)
+	ds.ErrorRows = errorRows(res.Messages)
+	if requested > p.Limit {
+		ds.RowsCapped = requested
+	}
+
+	templ.Handler(tPage(tView(p, ds, saved.Default != nil, slices.Sorted(maps.Keys(saved.RuleSets)), slices.Sorted(maps.Keys(saved.LogDirs[p.Dir])), res))).ServeHTTP(w, r)
+}
+
+func queryInt(r *http.Request, name string, def int) int {
+	ret, err := strconv.Atoi(r.URL.Query().Get(name))
 	if err != nil {
-		step = 500
+		return def
 	}
+	return ret
+}
 
-	var rule *Rule
+// lookupRule finds rule set by name, directory rule sets shadow global ones.
+// Without a name directory's inline default rule is used. Returned rule has
+// placeholders resolved.
+func lookupRule(saved SavedStuff, dirName, ruleSetName string) (*rules.Rule, error) {
+	return lookupRuleEnv(saved, dirName, ruleSetName, envResolve)
+}
+
+// lookupRuleEnv is lookupRule resolving placeholders with env
+func lookupRuleEnv(saved SavedStuff, dirName, ruleSetName string, env placeholderEnv) (*rules.Rule, error) {
+	var rule *rules.Rule
 	dirRules, ok := saved.LogDirs[dirName]
 	if ok {
 		rule = dirRules[ruleSetName]
 	}
+	if rule == nil && saved.RuleSets[ruleSetName] != nil {
+		return saved.resolveRuleSet(ruleSetName, env)
+	}
+	if rule == nil && ruleSetName == "" {
+		rule = saved.dirSettings(dirName).Default
+	}
+	return saved.resolveRuleFrom(rule, env)
+}
+
+var errRuleSetNotFound = errors.New("rule set not found")
+
+// effectiveRule composes everything that filters the view, all of which have
+// to match, in this order:
+//   - global Default, unless disabled by the nodefault parameter
+//   - selected rule set, or directory's inline default if none is selected
+//   - ad-hoc rule from the rule parameter, matched against lines redacted
+//     the way the view shows them so it can't tell masked values apart
+func (s SavedStuff) effectiveRule(p viewParams) (*rules.Rule, error) {
+	rule, err := lookupRule(s, p.Dir, p.RuleSet)
+	if err != nil {
+		return nil, err
+	}
+	if p.RuleSet != "" && rule == nil {
+		return nil, fmt.Errorf("%w: %q", errRuleSetNotFound, p.RuleSet)
+	}
+	adHoc, err := parseRuleParam(p.Rule)
+	if err == nil {
+		adHoc, err = s.resolveAdHocRule(adHoc)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if adHoc != nil && s.dirSettings(p.Dir).Redact != nil {
+		adHoc = &rules.Rule{Op: "redacted", Data: map[string]any{"Op": adHoc.Op, "Data": adHoc.Data}}
+	}
+	var def *rules.Rule
+	if !p.NoDefault {
+		def, err = s.resolveRule(s.Default)
+		if err != nil {
+			return nil, fmt.Errorf("global default: %w", err)
+		}
+	}
+	return s.Settings.reorderRule(andRules(def, rule, adHoc)), nil
+}
+
+func (s Settings) reorderRule(r *rules.Rule) *rules.Rule {
+	if r == nil || !s.ReorderRules {
+		return r
+	}
+	ret := rules.Reorder(*r, s.OpCosts)
+	return &ret
+}
+
+// activeFilters names the parts effectiveRule composes for the view in
+// the same order, so the view can tell whether anything is filtered at all
+func (s SavedStuff) activeFilters(p viewParams) []string {
+	ret := []string{}
+	if s.Default != nil && !p.NoDefault {
+		ret = append(ret, "global default")
+	}
+	if p.RuleSet != "" {
+		ret = append(ret, fmt.Sprintf("rule set %q", p.RuleSet))
+	} else if rule, _ := lookupRule(s, p.Dir, ""); rule != nil {
+		ret = append(ret, "directory default")
+	}
+	if p.Rule != "" {
+		ret = append(ret, "ad-hoc rule")
+	}
+	return ret
+}
+
+type dirResult struct {
+	Messages []map[string]any // newest first
+	Lines    []string         // raw lines of Messages
+	Files    []string         // paths of files Messages came from
+	Scanned  int              // lines read
+	Matched  int              // lines that passed the rule
+	Took     time.Duration    // wall-clock time of the scan
+	CapHit   bool             // older lines were not scanned due to the line cap
+	TimedOut bool             // scan was stopped by its context, see scanContext
+	MaxLines int              // line cap the scan ran with, 0 for none
+	// FileCounts break Scanned and Matched down by file in scan order, only
+	// with scanOptions.FileCounts
+	FileCounts []fileCount
+}
+
+type fileCount struct {
+	File    string `json:"file"`
+	Scanned int    `json:"scanned"`
+	Matched int    `json:"matched"`
+}
+
+// hasMore reports whether there are matched messages past the returned page
+func (r dirResult) hasMore(offset, limit int) bool {
+	return r.Matched > offset+limit
+}
+
+// truncation is a reason why not everything matching is shown
+type truncation struct {
+	Reason string
+	Limit  int
+}
+
+const (
+	truncatedLineCap = "line-cap" // older lines were never scanned
+	truncatedPage    = "page"     // matched messages past the page were not kept
+	truncatedTimeout = "timeout"  // scan was stopped before reading everything
+)
+
+func (r dirResult) truncations(offset, limit int) []truncation {
+	ret := []truncation{}
+	if r.TimedOut {
+		ret = append(ret, truncation{Reason: truncatedTimeout})
+	}
+	if r.CapHit {
+		ret = append(ret, truncation{Reason: truncatedLineCap, Limit: r.MaxLines})
+	}
+	if r.hasMore(offset, limit) {
+		ret = append(ret, truncation{Reason: truncatedPage, Limit: limit})
+	}
+	return ret
+}
+
+func processDir(ctx context.Context, dirPath string, opts scanOptions, rule *rules.Rule, limit, offset int) (dirResult, error) {
+	return processDirMatch(ctx, dirPath, opts, ruleMatcher(rule, opts), limit, offset)
+}
+
+// errScanStopped ends scans whose context is done
+var errScanStopped = errors.New("scan stopped")
+
+// lineMatcher is a rule ready to be evaluated, callers scanning several
+// times with the same rule can build it once with ruleMatcher
+type lineMatcher func(fp, line string) (bool, error)
+
+// ruleMatcher returns nil for nil rule, which processDirMatch treats as
+// matching everything without evaluating anything. Every matcher has its own
+// state for stateful ops, so it must be used for one scan only. Time ops
+// parse times with the directory's layouts from opts, redacted op redacts
+// with its Redact.
+func ruleMatcher(rule *rules.Rule, opts scanOptions) lineMatcher {
 	if rule == nil {
-		rule = saved.RuleSets[ruleSetName]
+		return nil
 	}
+	ops := ruleOps.ForScan().WithTimeLayouts(opts.TimeLayouts)
+	ops["redacted"] = redactedOp(opts.Redact)
+	return func(fp, line string) (bool, error) {
+		return runRule(ops, rule, fp, line)
+	}
+}
 
-	messages, err := processDir(dirName, rule, limit, offset)
+// processDirMatch is processDir taking an already built matcher. When ctx
+// is done the scan stops and what was found so far is returned as TimedOut.
+func processDirMatch(ctx context.Context, dirPath string, opts scanOptions, match lineMatcher, limit, offset int) (ret dirResult, err error) {
+	started := time.Now()
+	opts = opts.withContext(ctx)
+	ret.MaxLines = opts.MaxLines
+	shape, err := opts.shaper()
 	if err != nil {
-		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
-		return
+		return ret, err
+	}
+	defer func() {
+		ret.Took = time.Since(started)
+	}()
+	buf := NewLogBuffer(limit + offset)
+	// file paths are pushed in step with lines so their indices match
+	files := NewLogBuffer(limit + offset)
+	var sorted *sortedLines
+	if opts.SortField != "" {
+		sorted = newSortedLines(opts, limit+offset)
 	}
+	// count is the FileCounts entry of the file being scanned
+	var count *fileCount
+	countIdx := map[string]int{}
+	ret.CapHit, err = scanDir(dirPath, opts, func(fp, line string) error {
+		ret.Scanned++
+		// checked every so often as it takes a lock
+		if ret.Scanned%1024 == 0 && ctx.Err() != nil {
+			ret.TimedOut = true
+			return errScanStopped
+		}
+		if opts.FileCounts {
+			if count == nil || count.File != fp {
+				i, ok := countIdx[fp]
+				if !ok {
+					i = len(ret.FileCounts)
+					countIdx[fp] = i
+					ret.FileCounts = append(ret.FileCounts, fileCount{File: fp})
+				}
+				count = &ret.FileCounts[i]
+			}
+			count.Scanned++
+		}
+		// nil matcher skips evaluation altogether, "always" rule yields
+		// the same messages but is still run for every line
+		if match != nil {
+			ok, err := match(fp, line)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return nil
+			}
+		}
+		ret.Matched++
+		if count != nil {
+			count.Matched++
+		}
+		if sorted != nil {
+			sorted.push(fp, line)
+			return nil
+		}
+		buf.Push(line)
+		files.Push(fp)
+		return nil
+	})
+	// reads of remote sources fail rather than stop when ctx is done
+	if errors.Is(err, errScanStopped) || (err != nil && ctx.Err() != nil) {
+		ret.TimedOut = true
+		err = nil
+	}
+	if err != nil {
+		return ret, err
+	}
+	var msgs, msgFiles []string
+	if sorted != nil {
+		msgs, msgFiles = sorted.page(offset, limit)
+	} else {
+		msgs, err = buf.Get(offset, limit)
+		if err != nil {
+			return ret, err
+		}
+		msgFiles, err = files.Get(offset, limit)
+		if err != nil {
+			return ret, err
+		}
+		slices.Reverse(msgs)
+		slices.Reverse(msgFiles)
+	}
+	ret.Messages = []map[string]any{}
+	ret.Lines = []string{}
+	ret.Files = []string{}
+	for i, msg := range msgs {
+		ret.Messages = append(ret.Messages, shape.message(msg))
+		ret.Lines = append(ret.Lines, shape.red.line(msg))
+		ret.Files = append(ret.Files, msgFiles[i])
+	}
+	return ret, nil
+}
 
-	templ.Handler(tPage(tView(dirName, ruleSetName, slices.Sorted(maps.Keys(saved.RuleSets)), slices.Sorted(maps.Keys(dirRules)), limit, offset, step, messages))).ServeHTTP(w, r)
+// messageAnchors are element ids of lines derived from their content, so they
+// stay the same across reloads and pages. Repeated lines get a suffix.
+func messageAnchors(lines []string) []string {
+	ret := make([]string, len(lines))
+	seen := map[string]int{}
+	for i, l := range lines {
+		id := lineAnchor(l)
+		seen[id]++
+		if n := seen[id]; n > 1 {
+			id += "-" + strconv.Itoa(n)
+		}
+		ret[i] = id
+	}
+	return ret
+}
+
+// lineAnchor is the content-derived id of a line without duplicate suffix
+func lineAnchor(line string) string {
+	sum := sha1.Sum([]byte(line))
+	return "m-" + hex.EncodeToString(sum[:6])
 }
 
-func processDir(dirPath string, rule *Rule, limit, offset int) ([]map[string]any, error) {
+// parseMessage is like the package one, but in RawBytes mode lines with
+// invalid UTF-8 are kept as the message byte for byte because JSON decoding
+// would replace offending bytes. They are only replaced when rendering.
+func (o scanOptions) parseMessage(line string) map[string]any {
+	if o.RawBytes && !utf8.ValidString(line) {
+		return map[string]any{o.messageField(): line}
+	}
+	return parseMessage(line, o.messageField())
+}
+
+// messageShaper turns scanned lines into messages the way views show them
+type messageShaper struct {
+	opts    scanOptions
+	derived []derivedField
+	red     *redactor
+}
+
+func (o scanOptions) shaper() (*messageShaper, error) {
+	derived, err := parseDerivedFields(o.Derived)
+	if err != nil {
+		return nil, err
+	}
+	red, err := o.Redact.compile()
+	if err != nil {
+		return nil, err
+	}
+	return &messageShaper{opts: o, derived: derived, red: red}, nil
+}
+
+// message parses the line, redacts it and then applies projection and
+// derived fields, so redaction works on field paths as found in logs
+func (s *messageShaper) message(line string) map[string]any {
+	m := s.opts.parseMessage(line)
+	s.red.message(m)
+	s.opts.Projection.apply(m)
+	addDerived(m, s.derived)
+	return m
+}
+
+// messageField is where lines that can't be parsed go
+func (o scanOptions) messageField() string {
+	if o.MessageField == "" {
+		return defaultMessageField
+	}
+	return o.MessageField
+}
+
+// parseMessage parses JSON line, anything else is put into messageField as is
+func parseMessage(line, messageField string) map[string]any {
+	msgParsed := map[string]any{}
+	err := json.Unmarshal([]byte(line), &msgParsed)
+	if err != nil {
+		return map[string]any{messageField: line}
+	}
+	return msgParsed
+}
+
+// lineFn is called for every scanned line with path of the file it came from
+type lineFn func(fp, line string) error
+
+// runRule evaluates the rule against a line of fp. On failure only a preview
+// of the line is logged and the line is kept out of the returned error, so
+// huge or sensitive lines don't end up on pages and in logs.
+func runRule(ops rules.Ops, rule *rules.Rule, fp, line string) (bool, error) {
+	match, err := rule.Run(ops, rules.NewLine(line))
+	if err == nil {
+		return match, nil
+	}
+	ev := log.Warn().Err(err).Str("op", rule.Op).Str("file", fp)
+	if *ruleErrorVerbose {
+		ev.Str("line", line)
+	} else {
+		ev.Str("linePreview", linePreview(line, *ruleErrorPreview))
+	}
+	ev.Msg("rule evaluation failed")
+	return false, fmt.Errorf("processing rule %s on a line of %s: %w", rule.Op, fp, err)
+}
+
+func linePreview(line string, n int) string {
+	r := []rune(line)
+	if len(r) <= n {
+		return line
+	}
+	return fmt.Sprintf("%s... (%d bytes)", string(r[:max(n, 0)]), len(line))
+}
+
+// scanOptions narrow down which files of a directory get scanned and how
+// messages are shaped
+type scanOptions struct {
+	Files        string // glob matched against file names, empty for all
+	Exclude      string // drops files matching it out of those Files selected
+	MaxLines     int    // only look at this many newest lines, 0 for all
+	Projection   *Projection
+	RawBytes     bool   // keep lines that are not valid UTF-8 unparsed, see parseMessage
+	MessageField string // field unparsed lines are put into, "message" if empty
+	Framing      string // see framing.go
+	// NewestOnly narrows files down to the one modified last after Files is
+	// applied. Rules and line caps then work as usual on that file alone, so
+	// rule sets match only recent lines and MaxLines tails just that file.
+	NewestOnly bool
+	Derived    map[string]string // derived field definitions, see derived.go
+	Redact     *Redaction        // applied to messages and lines shown
+	// FromLine and ToLine limit the scan to a range of line numbers (not
+	// times) of a single file, 1-based and inclusive, 0 for no bound. The
+	// file is read from the start up to ToLine and MaxLines doesn't apply.
+	// Files has to narrow the directory down to one file.
+	FromLine, ToLine int
+	// FileCounts has processDir break counts down by file, see dirResult
+	FileCounts bool
+	// SortField orders matched messages by a field (descending unless
+	// SortAsc) instead of newest first, offset and limit then apply to that
+	// order. See sortedLines.
+	SortField string
+	SortAsc   bool
+	// TimeLayouts are the directory's layouts time ops of rules parse
+	// string times with, see rules.Ops.WithTimeLayouts
+	TimeLayouts []string
+	// remote is set when config lists the directory as a remote source, it
+	// is kept out of cache keys along with its headers
+	remote *remoteSource
+}
+
+func (o scanOptions) validate() error {
+	if o.Files != "" {
+		_, err := filepath.Match(o.Files, "")
+		if err != nil {
+			return fmt.Errorf("files pattern %q: %w", o.Files, err)
+		}
+	}
+	if o.Exclude != "" {
+		_, err := filepath.Match(o.Exclude, "")
+		if err != nil {
+			return fmt.Errorf("exclude pattern %q: %w", o.Exclude, err)
+		}
+	}
+	if !validFraming(o.Framing) {
+		return fmt.Errorf("unknown framing %q", o.Framing)
+	}
+	if _, err := parseDerivedFields(o.Derived); err != nil {
+		return err
+	}
+	if _, err := o.Redact.compile(); err != nil {
+		return err
+	}
+	if o.ToLine > 0 && o.FromLine > o.ToLine {
+		return fmt.Errorf("line range %d-%d is empty", o.FromLine, o.ToLine)
+	}
+	return nil
+}
+
+// scanDir calls fn for every line of directory's log files, oldest first.
+// Reports whether older lines were skipped because of MaxLines.
+func scanDir(dirPath string, opts scanOptions, fn lineFn) (bool, error) {
+	files, err := logFiles(dirPath, opts)
+	if err != nil {
+		return false, err
+	}
+	if opts.FromLine > 0 || opts.ToLine > 0 {
+		if len(files) != 1 {
+			return false, fmt.Errorf("line range needs a single file, files selected: %d", len(files))
+		}
+		return false, scanLineRange(files[0], opts, fn)
+	}
+	if opts.MaxLines > 0 {
+		return scanTail(files, opts.MaxLines, opts, fn)
+	}
+	for _, fp := range files {
+		err = scanFile(fp, opts, fn)
+		if err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// errLineRangeDone stops reading once past the end of the line range
+var errLineRangeDone = errors.New("line range done")
+
+func scanLineRange(fp string, opts scanOptions, fn lineFn) error {
+	n := 0
+	err := scanFile(fp, opts, func(fp, line string) error {
+		n++
+		if n < opts.FromLine {
+			return nil
+		}
+		if opts.ToLine > 0 && n > opts.ToLine {
+			return errLineRangeDone
+		}
+		return fn(fp, line)
+	})
+	if errors.Is(err, errLineRangeDone) {
+		return nil
+	}
+	return err
+}
+
+// scanTail calls fn for maxLines newest lines of files, which are expected to
+// be ordered oldest to newest
+func scanTail(files []string, maxLines int, opts scanOptions, fn lineFn) (bool, error) {
+	type fileLines struct {
+		fp    string
+		lines []string
+	}
+	chunks := []fileLines{}
+	remaining := maxLines
+	capHit := false
+	for i := len(files) - 1; i >= 0; i-- {
+		if remaining == 0 {
+			capHit = true
+			break
+		}
+		// one more line than needed tells whether the file goes further back
+		lines, err := tailFile(files[i], remaining+1, opts)
+		if err != nil {
+			return false, err
+		}
+		if len(lines) > remaining {
+			lines = lines[1:]
+			capHit = true
+		}
+		remaining -= len(lines)
+		chunks = append(chunks, fileLines{fp: files[i], lines: lines})
+		if capHit {
+			break
+		}
+	}
+	for _, chunk := range slices.Backward(chunks) {
+		for _, l := range chunk.lines {
+			err := fn(chunk.fp, l)
+			if err != nil {
+				return capHit, err
+			}
+		}
+	}
+	return capHit, nil
+}
+
+// logFiles lists paths of log files in the directory in name order
+func logFiles(dirPath string, opts scanOptions) ([]string, error) {
+	err := opts.validate()
+	if err != nil {
+		return nil, err
+	}
+	if isRemote(dirPath) {
+		return []string{dirPath}, nil
+	}
 	d, err := os.ReadDir(dirPath)
 	if err != nil {
 		return nil, err
 	}
-	buf := NewLogBuffer(limit + offset)
+	ret := []string{}
 	for _, de := range d {
 		if de.IsDir() {
 			continue
 		}
 		n := de.Name()
-		if !strings.HasSuffix(n, ".log") {
+		if _, ok := logReaderFor(n); !ok {
 			continue
 		}
-		f, err := os.Open(filepath.Join(dirPath, de.Name()))
+		if opts.Files != "" {
+			match, _ := filepath.Match(opts.Files, n)
+			if !match {
+				continue
+			}
+		}
+		if opts.Exclude != "" {
+			match, _ := filepath.Match(opts.Exclude, n)
+			if match {
+				continue
+			}
+		}
+		ret = append(ret, filepath.Join(dirPath, n))
+	}
+	if opts.NewestOnly && len(ret) > 1 {
+		return newestFile(ret)
+	}
+	return ret, nil
+}
+
+// newestFile picks the file modified last, falling back to the last name
+func newestFile(files []string) ([]string, error) {
+	newest := files[len(files)-1]
+	var newestTime time.Time
+	for _, fp := range files {
+		st, err := os.Stat(fp)
 		if err != nil {
 			return nil, err
 		}
-		defer f.Close()
-		scanner := bufio.NewScanner(f)
-		if rule == nil {
-			for scanner.Scan() {
-				buf.Push(scanner.Text())
-			}
-		} else {
-			for scanner.Scan() {
-				match, err := rule.Run(definedRuleOps, scanner.Text())
-				if err != nil {
-					return nil, fmt.Errorf("processing rule on line %q: %w", scanner.Text(), err)
-				}
-				if match {
-					buf.Push(scanner.Text())
-				}
-			}
+		if st.ModTime().After(newestTime) {
+			newest, newestTime = fp, st.ModTime()
 		}
 	}
-	ret := []map[string]any{}
-	msgs, err := buf.Get(offset, limit)
+	return []string{newest}, nil
+}
+
+// maxLineSize is the longest line scanner accepts, longer ones fail the scan
+const maxLineSize = 16 * 1024 * 1024
+
+// scanFile calls fn for every record of the file, only Framing and how
+// remote sources are fetched of opts apply
+func scanFile(fp string, opts scanOptions, fn lineFn) error {
+	f, err := openLogFile(fp, opts.remote)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	record, err := scanRecords(scanner, opts.Framing)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	for _, msg := range slices.Backward(msgs) {
-		msgParsed := map[string]any{}
-		err = json.Unmarshal([]byte(msg), &msgParsed)
-		if err != nil {
-			ret = append(ret, map[string]any{"message": msg})
+	for scanner.Scan() {
+		line, ok := record(scanner.Bytes())
+		if !ok {
 			continue
 		}
-		ret = append(ret, msgParsed)
+		err = fn(fp, line)
+		if err != nil {
+			return err
+		}
 	}
-	return ret, nil
+	err = scanner.Err()
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", fp, err)
+	}
+	return nil
 }
 
-func marshalOtherParams(msg map[string]any) (ret string) {
-	skip := []string{"level", "time", "message"}
+// trimLine drops trailing whitespace including stray \r left by CRLF files,
+// it would otherwise end up in JSON parsing and substring matching
+func trimLine(line string) string {
+	return strings.TrimRight(line, " \t\r")
+}
+
+// marshalOtherParams output is plain text built from log data, it must only be
+// rendered through escaping template expressions
+func marshalOtherParams(msg map[string]any, messageField string, hide ...string) (ret string) {
+	skip := []string{"level", "time", messageField}
 	for _, k := range slices.Sorted(maps.Keys(msg)) {
-		if slices.Contains(skip, k) {
+		if slices.Contains(skip, k) || slices.Contains(hide, k) {
 			continue
 		}
 		ret += fmt.Sprintf("%q=%v ", k, msg[k])
@@ -258,14 +1092,3 @@ type triviaFileServer struct {
 func (s triviaFileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	http.ServeFile(w, r, s.fp)
 }
-
-func must(err error) {
-	if err != nil {
-		panic(err)
-	}
-}
-
-func noerr[T any](ret T, err error) T {
-	must(err)
-	return ret
-}
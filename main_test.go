@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+// writeLogDir creates a directory with the given files and their contents
+func writeLogDir(t testing.TB, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// withSaved writes saved.json into a temporary working directory for the
+// duration of the test, handlers read config from there
+func withSaved(t testing.TB, saved SavedStuff) {
+	t.Helper()
+	b, err := json.Marshal(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := writeLogDir(t, map[string]string{"saved.json": string(b)})
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// get requests the path from newHandler and returns the response body
+func get(t testing.TB, path string) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	newHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec.Code, rec.Body.String()
+}
+
+func TestCRLFLines(t *testing.T) {
+	dir := writeLogDir(t, map[string]string{
+		"a.log": "{\"level\":\"error\",\"message\":\"m1\"}\r\n{\"level\":\"warn\",\"message\":\"m2\"} \t\r\nplain text\r\n",
+	})
+	res, err := processDir(context.Background(), dir, scanOptions{}, nil, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]any{
+		{"message": "plain text"},
+		{"level": "warn", "message": "m2"},
+		{"level": "error", "message": "m1"},
+	}
+	if !reflect.DeepEqual(res.Messages, want) {
+		t.Errorf("got %v, want %v", res.Messages, want)
+	}
+
+	// the closing brace is last on the line once \r is gone
+	rule := &rules.Rule{Op: "contains", Data: `"m1"}`}
+	res, err = processDir(context.Background(), dir, scanOptions{}, rule, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Messages) != 1 {
+		t.Errorf("got %v, want the m1 line", res.Messages)
+	}
+
+	counts, err := getLevelCounts(dir, 10, scanOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts.Errors != 1 || counts.Warns != 1 {
+		t.Errorf("got %d errors and %d warnings from the tail, want 1 and 1", counts.Errors, counts.Warns)
+	}
+}
+
+func TestEffectiveRuleOrder(t *testing.T) {
+	contains := func(s string) *rules.Rule { return &rules.Rule{Op: "contains", Data: s} }
+	and := func(rs ...*rules.Rule) *rules.Rule {
+		data := []any{}
+		for _, r := range rs {
+			data = append(data, map[string]any{"Op": r.Op, "Data": r.Data})
+		}
+		return &rules.Rule{Op: "and", Data: data}
+	}
+	saved := SavedStuff{
+		Default:  contains("global"),
+		RuleSets: map[string]*rules.Rule{"global set": contains("global set")},
+		LogDirs: map[string]map[string]*rules.Rule{
+			"d":     {"dir set": contains("dir set"), "global set": contains("shadowing set")},
+			"plain": {},
+		},
+		DirSettings: map[string]*DirSettings{"d": {Default: contains("dir default")}},
+	}
+	adHoc := `{"Op":"contains","Data":"ad-hoc"}`
+	for _, c := range []struct {
+		name    string
+		p       viewParams
+		want    *rules.Rule
+		filters []string
+	}{
+		{"everything", viewParams{Dir: "d", Rule: adHoc}, and(contains("global"), contains("dir default"), contains("ad-hoc")), []string{"global default", "directory default", "ad-hoc rule"}},
+		{"rule set replaces directory default", viewParams{Dir: "d", RuleSet: "dir set", Rule: adHoc}, and(contains("global"), contains("dir set"), contains("ad-hoc")), []string{"global default", `rule set "dir set"`, "ad-hoc rule"}},
+		{"directory rule set shadows global", viewParams{Dir: "d", RuleSet: "global set"}, and(contains("global"), contains("shadowing set")), []string{"global default", `rule set "global set"`}},
+		{"global rule set", viewParams{Dir: "plain", RuleSet: "global set"}, and(contains("global"), contains("global set")), []string{"global default", `rule set "global set"`}},
+		{"nodefault", viewParams{Dir: "d", NoDefault: true, Rule: adHoc}, and(contains("dir default"), contains("ad-hoc")), []string{"directory default", "ad-hoc rule"}},
+		{"global default only", viewParams{Dir: "plain"}, contains("global"), []string{"global default"}},
+		{"nothing", viewParams{Dir: "plain", NoDefault: true}, nil, []string{}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := saved.effectiveRule(c.p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+			if filters := saved.activeFilters(c.p); !reflect.DeepEqual(filters, c.filters) {
+				t.Errorf("got filters %q, want %q", filters, c.filters)
+			}
+		})
+	}
+	if _, err := saved.effectiveRule(viewParams{Dir: "d", RuleSet: "missing"}); !errors.Is(err, errRuleSetNotFound) {
+		t.Errorf("got %v for missing rule set", err)
+	}
+}
+
+func TestNoDefaultParam(t *testing.T) {
+	dir := writeLogDir(t, map[string]string{
+		"a.log": "{\"message\":\"/healthz\"}\n{\"message\":\"GET /\"}\n",
+	})
+	withSaved(t, SavedStuff{
+		LogDirs: map[string]map[string]*rules.Rule{dir: {}},
+		Default: &rules.Rule{Op: "not", Data: map[string]any{"Op": "contains", "Data": "healthz"}},
+	})
+	for path, want := range map[string]string{
+		"/api/count/" + url.PathEscape(dir):                  `"matched":1`,
+		"/api/count/" + url.PathEscape(dir) + "?nodefault=1": `"matched":2`,
+	} {
+		code, body := get(t, path)
+		if code != http.StatusOK || !strings.Contains(body, want) {
+			t.Errorf("%s: got %d %s, want %s", path, code, body, want)
+		}
+	}
+}
+
+// writeSyntheticDir fills a directory with files of JSON lines, the same
+// arguments always make the same logs
+func writeSyntheticDir(b testing.TB, files, lines int) string {
+	b.Helper()
+	rnd := rand.New(rand.NewPCG(uint64(files), uint64(lines)))
+	levels := []string{"debug", "info", "info", "info", "warn", "error"}
+	paths := []string{"/", "/api/view", "/api/count", "/healthz", "/static/style.css"}
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	contents := map[string]string{}
+	for f := range files {
+		var sb strings.Builder
+		for i := range lines {
+			fmt.Fprintf(&sb, `{"time":%q,"level":%q,"message":"request %d","http":{"path":%q,"status":%d},"user":"u%d"}`+"\n",
+				start.Add(time.Duration(f*lines+i)*time.Second).Format(time.RFC3339),
+				levels[rnd.IntN(len(levels))], i, paths[rnd.IntN(len(paths))],
+				[]int{200, 200, 200, 404, 500}[rnd.IntN(5)], rnd.IntN(100))
+		}
+		contents[fmt.Sprintf("app-%03d.log", f)] = sb.String()
+	}
+	return writeLogDir(b, contents)
+}
+
+// benchmarkRules go from nothing to a tree like rule sets people write
+var benchmarkRules = map[string]*rules.Rule{
+	"none":     nil,
+	"contains": {Op: "contains", Data: "error"},
+	"tree": {Op: "and", Data: []any{
+		map[string]any{"Op": "not", "Data": map[string]any{"Op": "fieldcontains", "Data": map[string]any{"Field": "http.path", "Value": "/healthz"}}},
+		map[string]any{"Op": "or", "Data": []any{
+			map[string]any{"Op": "minlevel", "Data": "warn"},
+			map[string]any{"Op": "eq", "Data": map[string]any{"Field": "http.status", "Value": 404.0}},
+			map[string]any{"Op": "regexset", "Data": []any{`u9\d"`, `request 1\d\d"`}},
+		}},
+	}},
+}
+
+func BenchmarkProcessDir(b *testing.B) {
+	for _, size := range []struct{ files, lines int }{{1, 10000}, {10, 1000}, {10, 10000}} {
+		dir := writeSyntheticDir(b, size.files, size.lines)
+		for _, name := range []string{"none", "contains", "tree"} {
+			b.Run(fmt.Sprintf("files=%d/lines=%d/rule=%s", size.files, size.lines, name), func(b *testing.B) {
+				b.ReportAllocs()
+				for range b.N {
+					_, err := processDir(context.Background(), dir, scanOptions{}, benchmarkRules[name], 100, 0)
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(size.files*size.lines*b.N)/b.Elapsed().Seconds(), "lines/s")
+			})
+		}
+	}
+}
+
+func TestRawBytes(t *testing.T) {
+	raw := "legacy \xff\xfe payload"
+	dir := writeLogDir(t, map[string]string{
+		"a.log": "{\"message\":\"clean\"}\n" + raw + "\n{\"message\":\"bad \xff json\"}\n",
+	})
+	rule := &rules.Rule{Op: "bytescontains", Data: `\xff`}
+	res, err := processDir(context.Background(), dir, scanOptions{RawBytes: true}, rule, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Matched != 2 {
+		t.Fatalf("got %d matched, want 2", res.Matched)
+	}
+	// newest first
+	if res.Lines[1] != raw || res.Messages[1]["message"] != raw {
+		t.Errorf("got line %q message %q, want original bytes %q", res.Lines[1], res.Messages[1]["message"], raw)
+	}
+	if res.Messages[0]["message"] != "{\"message\":\"bad \xff json\"}" {
+		t.Errorf("got %q, JSON with invalid UTF-8 is not decoded in raw bytes mode", res.Messages[0]["message"])
+	}
+
+	res, err = processDir(context.Background(), dir, scanOptions{}, rule, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Messages[0]["message"] != "bad � json" {
+		t.Errorf("got %q, JSON decoding replaces invalid UTF-8 without raw bytes mode", res.Messages[0]["message"])
+	}
+
+	withSaved(t, SavedStuff{
+		LogDirs:     map[string]map[string]*rules.Rule{dir: {}},
+		DirSettings: map[string]*DirSettings{dir: {RawBytes: true}},
+	})
+	code, body := get(t, "/view/"+url.PathEscape(dir))
+	if code != http.StatusOK {
+		t.Fatalf("got %d %s", code, body)
+	}
+	if !utf8.ValidString(body) {
+		t.Error("page is not valid UTF-8")
+	}
+	// a run of invalid bytes becomes one replacement character
+	if !strings.Contains(body, "legacy � payload") {
+		t.Error("invalid bytes are not shown as replacement characters")
+	}
+}
+
+func TestMessageField(t *testing.T) {
+	if got := (DirSettings{}).messageField(); got != "message" {
+		t.Errorf("default message field is %q", got)
+	}
+	msg := map[string]any{"msg": "primary", "message": "other", "level": "info", "time": "t", "code": 1.0}
+	if got, want := marshalOtherParams(msg, "msg"), `"code"=1 "message"=other `; got != want {
+		t.Errorf("got params %q, want %q", got, want)
+	}
+	if got, want := parseMessage("plain text", "description"), map[string]any{"description": "plain text"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	dir := writeLogDir(t, map[string]string{
+		"a.log": "{\"msg\":\"primary-text\",\"message\":\"secondary-text\",\"description\":\"third-text\"}\nnot json\n",
+	})
+	withSaved(t, SavedStuff{
+		LogDirs:     map[string]map[string]*rules.Rule{dir: {}},
+		DirSettings: map[string]*DirSettings{dir: {MessageField: "msg"}},
+	})
+	for _, c := range []struct {
+		query   string
+		primary string
+		params  string
+	}{
+		{"", "primary-text", `&#34;message&#34;=secondary-text`},
+		{"?msgfield=description", "third-text", `&#34;msg&#34;=primary-text`},
+	} {
+		code, body := get(t, "/view/"+url.PathEscape(dir)+c.query)
+		if code != http.StatusOK {
+			t.Fatalf("got %d %s", code, body)
+		}
+		if !strings.Contains(body, "<td><pre>"+c.primary+"</pre></td>") {
+			t.Errorf("%q: %s is not in the message column", c.query, c.primary)
+		}
+		if !strings.Contains(body, c.params) {
+			t.Errorf("%q: params have no %s", c.query, c.params)
+		}
+		if !strings.Contains(body, "<td><pre>not json</pre></td>") {
+			t.Errorf("%q: plain line is not in the message column", c.query)
+		}
+	}
+}
+
+// TestRepeatRule filters a directory down to repeated lines
+func TestRepeatRule(t *testing.T) {
+	dir := writeLogDir(t, map[string]string{
+		"a.log": "{\"message\":\"flood\",\"_repeat\":40}\n{\"message\":\"once\"}\n{\"message\":\"twice\",\"_repeat\":2}\n",
+	})
+	rule := &rules.Rule{Op: "repeat", Data: map[string]any{"Min": 2.0}}
+	res, err := processDir(context.Background(), dir, scanOptions{}, rule, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range res.Messages {
+		got = append(got, m["message"].(string))
+	}
+	want := []string{"flood", "twice"}
+	slices.Sort(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestLogFilesExclude(t *testing.T) {
+	dir := writeLogDir(t, map[string]string{
+		"app.log":          "{}\n",
+		"app-debug.log":    "{}\n",
+		"app.1.log.gz":     gzipped(t, "{}\n"),
+		"worker.log":       "{}\n",
+		"worker-debug.log": "{}\n",
+		"notes.txt":        "x",
+	})
+	for _, c := range []struct {
+		name           string
+		files, exclude string
+		want           []string
+	}{
+		{"neither", "", "", []string{"app-debug.log", "app.1.log.gz", "app.log", "worker-debug.log", "worker.log"}},
+		{"exclude only", "", "*debug*", []string{"app.1.log.gz", "app.log", "worker.log"}},
+		{"overlapping", "app*", "*debug*", []string{"app.1.log.gz", "app.log"}},
+		{"exclude inside include", "app*", "app.*.gz", []string{"app-debug.log", "app.log"}},
+		{"exclude everything included", "*debug*", "*.log", []string{}},
+		{"same pattern", "worker*", "worker*", []string{}},
+		{"disjoint", "worker*", "app*", []string{"worker-debug.log", "worker.log"}},
+		{"exclude non-log file", "", "*.txt", []string{"app-debug.log", "app.1.log.gz", "app.log", "worker-debug.log", "worker.log"}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := logFiles(dir, scanOptions{Files: c.files, Exclude: c.exclude})
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := []string{}
+			for _, fp := range got {
+				names = append(names, filepath.Base(fp))
+			}
+			slices.Sort(names)
+			if !reflect.DeepEqual(names, c.want) {
+				t.Errorf("got %q, want %q", names, c.want)
+			}
+		})
+	}
+	if _, err := logFiles(dir, scanOptions{Exclude: "[debug"}); err == nil {
+		t.Error("bad exclude pattern accepted")
+	}
+	withSaved(t, SavedStuff{LogDirs: map[string]map[string]*rules.Rule{dir: {}}})
+	if code, _ := get(t, "/api/view/"+url.PathEscape(dir)+"?exclude=%5Bdebug"); code == http.StatusOK {
+		t.Error("view with a bad exclude pattern succeeded")
+	}
+	code, body := get(t, "/api/view/"+url.PathEscape(dir)+"?files=app*&exclude=*debug*")
+	if code != http.StatusOK {
+		t.Fatalf("got %d: %s", code, body)
+	}
+	var res apiViewResponse
+	if err := json.Unmarshal([]byte(body), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Messages) != 2 {
+		t.Errorf("got %d messages, want one of each of the 2 selected files", len(res.Messages))
+	}
+}
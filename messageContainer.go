@@ -2,6 +2,9 @@ package main
 
 import (
 	"errors"
+	"fmt"
+	"iter"
+	"slices"
 )
 
 type LogBuffer struct {
@@ -81,10 +84,8 @@ func (b *LogBuffer) Get(offset, limit int) ([]string, error) {
 
 	// Calculate starting index (offset from newest)
 	// We need to go backwards 'offset' positions from newest
-	startIndex := newestIndex
-	for i := 0; i < offset; i++ {
-		startIndex = (startIndex - 1 + b.capacity) % b.capacity
-	}
+	// offset is less than size, so one wrap is enough
+	startIndex := (newestIndex - offset + b.capacity) % b.capacity
 
 	// Now collect 'count' messages moving forward from startIndex
 	// But note: we want them in chronological order (oldest to newest)
@@ -93,10 +94,7 @@ func (b *LogBuffer) Get(offset, limit int) ([]string, error) {
 	// Calculate chronological start index (oldest of the range we want)
 	// We have startIndex which is offset from newest, but we want the chronological
 	// start which is 'count-1' positions before this in time
-	chronoStart := startIndex
-	for i := 0; i < (count - 1); i++ {
-		chronoStart = (chronoStart - 1 + b.capacity) % b.capacity
-	}
+	chronoStart := (startIndex - (count - 1) + b.capacity) % b.capacity
 
 	// Now collect messages in chronological order
 	for i := 0; i < count; i++ {
@@ -108,6 +106,8 @@ func (b *LogBuffer) Get(offset, limit int) ([]string, error) {
 }
 
 // GetAll returns all messages in chronological order (oldest to newest)
+// It copies the whole buffer, which is unbounded for big capacities, so
+// callers that only need part of it should use Get or All instead
 func (b *LogBuffer) GetAll() []string {
 	if b.size == 0 {
 		return []string{}
@@ -115,7 +115,7 @@ func (b *LogBuffer) GetAll() []string {
 
 	result := make([]string, b.size)
 
-	if b.isFull || b.start < b.end {
+	if b.start < b.end {
 		// Simple case: buffer is contiguous
 		copy(result, b.buffer[b.start:b.end])
 	} else {
@@ -129,6 +129,18 @@ func (b *LogBuffer) GetAll() []string {
 	return result
 }
 
+// All iterates over messages in chronological order without copying them
+// The buffer must not be modified while iterating
+func (b *LogBuffer) All() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for i := 0; i < b.size; i++ {
+			if !yield(b.buffer[(b.start+i)%b.capacity]) {
+				return
+			}
+		}
+	}
+}
+
 // Size returns current number of messages in buffer
 func (b *LogBuffer) Size() int {
 	return b.size
@@ -146,3 +158,35 @@ func (b *LogBuffer) Clear() {
 	b.end = 0
 	b.isFull = false
 }
+
+// invariants reports the first inconsistency between the buffer's indices,
+// size and contents, for checking the index math after changes
+func (b *LogBuffer) invariants() error {
+	switch {
+	case b.capacity <= 0 || len(b.buffer) != b.capacity:
+		return fmt.Errorf("capacity %d with buffer of %d", b.capacity, len(b.buffer))
+	case b.size < 0 || b.size > b.capacity:
+		return fmt.Errorf("size %d out of [0, %d]", b.size, b.capacity)
+	case b.start < 0 || b.start >= b.capacity || b.end < 0 || b.end >= b.capacity:
+		return fmt.Errorf("start %d or end %d out of [0, %d)", b.start, b.end, b.capacity)
+	case b.isFull != (b.size == b.capacity):
+		return fmt.Errorf("isFull %v with size %d of %d", b.isFull, b.size, b.capacity)
+	case (b.start+b.size)%b.capacity != b.end:
+		return fmt.Errorf("start %d plus size %d doesn't end at %d", b.start, b.size, b.end)
+	case len(b.GetAll()) != b.size:
+		return fmt.Errorf("GetAll returned %d messages of %d", len(b.GetAll()), b.size)
+	}
+	if b.size > 0 {
+		got, err := b.Get(0, b.size)
+		if err != nil {
+			return err
+		}
+		if !slices.Equal(got, b.GetAll()) {
+			return errors.New("Get of everything differs from GetAll")
+		}
+		if !slices.Equal(slices.Collect(b.All()), b.GetAll()) {
+			return errors.New("All differs from GetAll")
+		}
+	}
+	return nil
+}
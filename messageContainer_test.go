@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// TestLogBufferGet checks every offset and limit against slicing GetAll
+func TestLogBufferGet(t *testing.T) {
+	for capacity := 1; capacity <= 6; capacity++ {
+		b := NewLogBuffer(capacity)
+		for pushed := 0; pushed <= 2*capacity+1; pushed++ {
+			all := b.GetAll()
+			for offset := 0; offset <= capacity+1; offset++ {
+				for limit := 1; limit <= capacity+1; limit++ {
+					got, err := b.Get(offset, limit)
+					if err != nil {
+						t.Fatal(err)
+					}
+					end := max(len(all)-offset, 0)
+					want := all[max(end-limit, 0):end]
+					if !slices.Equal(got, want) {
+						t.Fatalf("capacity %d pushed %d: Get(%d, %d) = %q, want %q", capacity, pushed, offset, limit, got, want)
+					}
+				}
+			}
+			b.Push(strconv.Itoa(pushed))
+		}
+	}
+}
+
+// TestLogBufferInvariants runs random sequences of operations and checks
+// the buffer's indices and contents against a plain slice after each one
+func TestLogBufferInvariants(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(3, 4))
+	for capacity := 1; capacity <= 8; capacity++ {
+		b := NewLogBuffer(capacity)
+		var model []string
+		for step := range 2000 {
+			var op string
+			switch n := rnd.IntN(20); {
+			case n < 12:
+				op = "Push"
+				msg := strconv.Itoa(step)
+				b.Push(msg)
+				model = append(model, msg)
+				if len(model) > capacity {
+					model = model[1:]
+				}
+			case n < 16:
+				offset, limit := rnd.IntN(capacity+2), 1+rnd.IntN(capacity+1)
+				op = fmt.Sprintf("Get(%d, %d)", offset, limit)
+				got, err := b.Get(offset, limit)
+				if err != nil {
+					t.Fatalf("capacity %d step %d %s: %v", capacity, step, op, err)
+				}
+				end := max(len(model)-offset, 0)
+				if want := model[max(end-limit, 0):end]; !slices.Equal(got, want) {
+					t.Fatalf("capacity %d step %d %s = %q, want %q", capacity, step, op, got, want)
+				}
+			case n < 19:
+				op = "GetAll"
+				if got := b.GetAll(); !slices.Equal(got, model) {
+					t.Fatalf("capacity %d step %d GetAll = %q, want %q", capacity, step, got, model)
+				}
+			default:
+				op = "Clear"
+				b.Clear()
+				model = nil
+			}
+			if err := b.invariants(); err != nil {
+				t.Fatalf("capacity %d step %d after %s: %v", capacity, step, op, err)
+			}
+			if b.Size() != len(model) || b.Capacity() != capacity {
+				t.Fatalf("capacity %d step %d after %s: size %d of %d, want %d of %d", capacity, step, op, b.Size(), b.Capacity(), len(model), capacity)
+			}
+		}
+	}
+}
+
+// fullLogBuffer is a wrapped around buffer of n short lines
+func fullLogBuffer(n int) *LogBuffer {
+	b := NewLogBuffer(n)
+	for i := range n + n/2 {
+		b.Push(`{"level":"info","message":"line ` + strconv.Itoa(i) + `"}`)
+	}
+	return b
+}
+
+// BenchmarkLogBufferRead compares ways to read a preview of a big buffer and
+// to go through all of it
+func BenchmarkLogBufferRead(b *testing.B) {
+	const preview = 100
+	for _, n := range []int{1000, 100000, 1000000} {
+		buf := fullLogBuffer(n)
+		b.Run("GetAll/"+strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				total := 0
+				for _, l := range buf.GetAll() {
+					total += len(l)
+				}
+				_ = total
+			}
+		})
+		b.Run("All/"+strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				total := 0
+				for l := range buf.All() {
+					total += len(l)
+				}
+				_ = total
+			}
+		})
+		b.Run("GetAllPreview/"+strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				_ = buf.GetAll()[:preview]
+			}
+		})
+		b.Run("GetPreview/"+strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				if _, err := buf.Get(n-preview, preview); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run("AllPreview/"+strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				i := 0
+				for range buf.All() {
+					i++
+					if i == preview {
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkLogBufferPush(b *testing.B) {
+	for _, capacity := range []int{100, 10000} {
+		b.Run(strconv.Itoa(capacity), func(b *testing.B) {
+			buf := NewLogBuffer(capacity)
+			b.ReportAllocs()
+			for range b.N {
+				buf.Push("line")
+			}
+		})
+	}
+}
+
+func BenchmarkLogBufferGet(b *testing.B) {
+	buf := fullLogBuffer(10000)
+	for _, c := range []struct{ offset, limit int }{{0, 100}, {5000, 100}, {0, 10000}} {
+		b.Run(fmt.Sprintf("offset=%d/limit=%d", c.offset, c.limit), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				if _, err := buf.Get(c.offset, c.limit); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/a-h/templ"
+)
+
+type messageDetail struct {
+	Dir     string
+	File    string // where the line was found, may differ from the requested one after rotation
+	LineNo  int    // 1-based number of the record in File
+	Moved   bool   // line was not in the requested file anymore
+	Line    string
+	Pretty  []jsonToken
+	BackURL string
+	ID      string // anchor of Line without duplicate suffix
+	// Bookmarks tells whether the page offers bookmarking, see bookmarks.go
+	Bookmarks bool
+	// Flat is set instead of Pretty for the one field per row view,
+	// ToggleURL switches between the two
+	Flat      []flatField
+	ToggleURL string
+}
+
+// findMessage looks for the newest line with the anchor id, first in the
+// requested file and then in the rest of the directory newest file first,
+// as rotation moves lines to other files
+func findMessage(dirPath string, opts scanOptions, file, id string) (*messageDetail, error) {
+	files, err := logFiles(dirPath, scanOptions{Framing: opts.Framing})
+	if err != nil {
+		return nil, err
+	}
+	// duplicate suffix is only meaningful within the page the link came from
+	if i := strings.LastIndex(id, "-"); i > len("m-") {
+		id = id[:i]
+	}
+	// anchors of the view are made from redacted lines
+	red, err := opts.Redact.compile()
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(files)
+	if i := slices.IndexFunc(files, func(fp string) bool { return filepath.Base(fp) == file }); i > 0 {
+		fp := files[i]
+		files = slices.Insert(slices.Delete(files, i, i+1), 0, fp)
+	}
+	for _, fp := range files {
+		ret := &messageDetail{Dir: dirPath, File: fp}
+		n := 0
+		err := scanFile(fp, opts, func(fp, line string) error {
+			n++
+			line = red.line(line)
+			if lineAnchor(line) == id {
+				ret.LineNo, ret.Line = n, line
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if ret.LineNo > 0 {
+			ret.Moved = filepath.Base(fp) != file
+			return ret, nil
+		}
+	}
+	return nil, errors.New("message not found, it may have been rotated away")
+}
+
+func handleMessage(w http.ResponseWriter, r *http.Request) {
+	saved, err := loadSaved()
+	if err != nil {
+		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
+		return
+	}
+	p := parseViewParams(r)
+	q := r.URL.Query()
+	opts := saved.scanOptions(p).withContext(r.Context())
+	d, err := findMessage(p.Dir, opts, q.Get("file"), q.Get("id"))
+	if err != nil {
+		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
+		return
+	}
+	tq := r.URL.Query()
+	if q.Get("view") == "flat" {
+		d.Flat = flattenJSON(d.Line)
+		tq.Del("view")
+	} else {
+		tq.Set("view", "flat")
+	}
+	if d.Flat == nil {
+		d.Pretty = prettyJSON(d.Line)
+	}
+	d.ToggleURL = r.URL.Path + "?" + tq.Encode()
+	d.BackURL = p.url() + "#" + q.Get("id")
+	d.ID = lineAnchor(d.Line)
+	d.Bookmarks = saved.Settings.BookmarksFile != ""
+	templ.Handler(tPage(tMessageDetail(d))).ServeHTTP(w, r)
+}
+
+// messageURL links a row of the view to its detail page
+func (p viewParams) messageURL(fp, id string) string {
+	ret := "/message/" + url.PathEscape(p.Dir)
+	if p.RuleSet != "" {
+		ret = "/view/" + url.PathEscape(p.Dir) + "/" + url.PathEscape(p.RuleSet) + "/message"
+	}
+	q := p.query()
+	q.Set("file", filepath.Base(fp))
+	q.Set("id", id)
+	return ret + "?" + q.Encode()
+}
+
+// jsonToken is a piece of pretty-printed JSON, Class is empty for whitespace
+// and punctuation
+type jsonToken struct {
+	Class string
+	Text  string
+}
+
+// prettyJSON indents the line keeping key order and duplicate keys as they
+// are and splits it into tokens for highlighting. Lines that are not JSON
+// come back as a single plain token.
+func prettyJSON(line string) []jsonToken {
+	buf := bytes.Buffer{}
+	if json.Indent(&buf, []byte(line), "", "  ") != nil {
+		return []jsonToken{{Text: line}}
+	}
+	s := buf.String()
+	ret := []jsonToken{}
+	for len(s) > 0 {
+		n, class := 1, ""
+		switch c := s[0]; {
+		case c == '"':
+			n = jsonStringLen(s)
+			class = "json-string"
+			if strings.HasPrefix(strings.TrimLeft(s[n:], " "), ":") {
+				class = "json-key"
+			}
+		case c == '-' || (c >= '0' && c <= '9'):
+			n = strings.IndexAny(s, ",]} \n")
+			if n < 0 {
+				n = len(s)
+			}
+			class = "json-number"
+		case strings.HasPrefix(s, "true"), strings.HasPrefix(s, "null"):
+			n, class = 4, "json-literal"
+		case strings.HasPrefix(s, "false"):
+			n, class = 5, "json-literal"
+		}
+		if last := len(ret) - 1; class == "" && last >= 0 && ret[last].Class == "" {
+			ret[last].Text += s[:n]
+		} else {
+			ret = append(ret, jsonToken{Class: class, Text: s[:n]})
+		}
+		s = s[n:]
+	}
+	return ret
+}
+
+// jsonStringLen is the length of the quoted string s starts with
+func jsonStringLen(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// flatField is a leaf of a message with its dotted path
+type flatField struct {
+	Path  string
+	Value string
+}
+
+// flattenJSON lists leaf fields of the line sorted by path, nested objects
+// become dotted paths and array elements are keyed by index ("tags.0").
+// Strings are shown as they are, other values as JSON, so numbers keep
+// their original form. Lines that are not JSON objects give nil.
+func flattenJSON(line string) []flatField {
+	dec := json.NewDecoder(strings.NewReader(line))
+	dec.UseNumber()
+	msg := map[string]any{}
+	if dec.Decode(&msg) != nil {
+		return nil
+	}
+	ret := []flatField{}
+	var walk func(path string, v any)
+	walk = func(path string, v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if len(v) > 0 {
+				for k, el := range v {
+					walk(joinPath(path, k), el)
+				}
+				return
+			}
+		case []any:
+			if len(v) > 0 {
+				for i, el := range v {
+					walk(joinPath(path, strconv.Itoa(i)), el)
+				}
+				return
+			}
+		case string:
+			ret = append(ret, flatField{Path: path, Value: v})
+			return
+		}
+		b, _ := json.Marshal(v)
+		ret = append(ret, flatField{Path: path, Value: string(b)})
+	}
+	walk("", msg)
+	slices.SortFunc(ret, func(a, b flatField) int { return strings.Compare(a.Path, b.Path) })
+	return ret
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
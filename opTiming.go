@@ -0,0 +1,50 @@
+package main
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+// opTiming is time spent evaluating one op of a rule set over a scan. Time
+// of and, or and not includes their nested rules.
+type opTiming struct {
+	Op    string        `json:"op"`
+	Calls int           `json:"calls"`
+	Total time.Duration `json:"total_ns"`
+}
+
+// timedOps wraps every op to record its calls and time into timings, the
+// returned ops are for a single scan just like ForScan ones
+func timedOps(ops rules.Ops, timings map[string]*opTiming) rules.Ops {
+	ret := rules.Ops{}
+	for name, op := range ops {
+		t := &opTiming{Op: name}
+		ret[name] = func(ops rules.Ops, data, arg any) (bool, error) {
+			started := time.Now()
+			res, err := op(ops, data, arg)
+			t.Total += time.Since(started)
+			t.Calls++
+			return res, err
+		}
+		timings[name] = t
+	}
+	return ret
+}
+
+// usedTimings lists ops that ran, slowest first
+func usedTimings(timings map[string]*opTiming) []opTiming {
+	ret := []opTiming{}
+	for _, name := range slices.Sorted(maps.Keys(timings)) {
+		if t := timings[name]; t.Calls > 0 {
+			ret = append(ret, *t)
+		}
+	}
+	slices.SortStableFunc(ret, func(a, b opTiming) int {
+		return cmp.Compare(b.Total, a.Total)
+	})
+	return ret
+}
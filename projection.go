@@ -0,0 +1,49 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+// Projection reshapes parsed messages before they are returned. Omit is
+// applied first, then Rename moves values found at dotted source paths to
+// top level output names.
+type Projection struct {
+	Rename map[string]string
+	Omit   []string
+}
+
+func (p *Projection) apply(msg map[string]any) {
+	if p == nil {
+		return
+	}
+	for _, path := range p.Omit {
+		deleteField(msg, path)
+	}
+	// sorted so that clashing renames resolve the same way every time
+	for _, src := range slices.Sorted(maps.Keys(p.Rename)) {
+		v, ok := rules.LookupField(msg, src)
+		if !ok {
+			continue
+		}
+		deleteField(msg, src)
+		msg[p.Rename[src]] = v
+	}
+}
+
+// deleteField removes value at dotted path, missing paths are ignored
+func deleteField(msg map[string]any, path string) {
+	parts := strings.Split(path, ".")
+	cur := msg
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := cur[p].(map[string]any)
+		if !ok {
+			return
+		}
+		cur = next
+	}
+	delete(cur, parts[len(parts)-1])
+}
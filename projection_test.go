@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestProjectionApply(t *testing.T) {
+	line := `{"message":"m","level":"info","http":{"path":"/api","status":200,"headers":{"cookie":"c"}},"trace":"t"}`
+	for _, c := range []struct {
+		name string
+		p    *Projection
+		want string
+	}{
+		{"nil", nil, line},
+		{"rename", &Projection{Rename: map[string]string{"level": "severity"}},
+			`{"message":"m","severity":"info","http":{"path":"/api","status":200,"headers":{"cookie":"c"}},"trace":"t"}`},
+		{"omit", &Projection{Omit: []string{"trace"}},
+			`{"message":"m","level":"info","http":{"path":"/api","status":200,"headers":{"cookie":"c"}}}`},
+		{"rename nested", &Projection{Rename: map[string]string{"http.status": "status"}},
+			`{"message":"m","level":"info","status":200,"http":{"path":"/api","headers":{"cookie":"c"}},"trace":"t"}`},
+		{"omit nested", &Projection{Omit: []string{"http.headers.cookie", "http.path"}},
+			`{"message":"m","level":"info","http":{"status":200,"headers":{}},"trace":"t"}`},
+		{"omit before rename", &Projection{Omit: []string{"http.status"}, Rename: map[string]string{"http.status": "status"}},
+			`{"message":"m","level":"info","http":{"path":"/api","headers":{"cookie":"c"}},"trace":"t"}`},
+		{"rename object", &Projection{Rename: map[string]string{"http.headers": "headers"}},
+			`{"message":"m","level":"info","headers":{"cookie":"c"},"http":{"path":"/api","status":200},"trace":"t"}`},
+		{"missing paths", &Projection{Omit: []string{"nope", "http.nope.deeper", "message.deeper"}, Rename: map[string]string{"nope": "x", "trace.deeper": "y"}}, line},
+		{"clashing renames", &Projection{Rename: map[string]string{"level": "out", "trace": "out"}},
+			`{"message":"m","out":"t","http":{"path":"/api","status":200,"headers":{"cookie":"c"}}}`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var got, want map[string]any
+			if err := json.Unmarshal([]byte(line), &got); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(c.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			c.p.apply(got)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestProcessDirProjection(t *testing.T) {
+	dir := writeLogDir(t, map[string]string{
+		"a.log": "{\"message\":\"m\",\"http\":{\"status\":500},\"secret\":\"s\"}\n",
+	})
+	opts := scanOptions{Projection: &Projection{Rename: map[string]string{"http.status": "status"}, Omit: []string{"secret"}}}
+	res, err := processDir(context.Background(), dir, opts, nil, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]any{{"message": "m", "http": map[string]any{}, "status": 500.0}}
+	if !reflect.DeepEqual(res.Messages, want) {
+		t.Errorf("got %v, want %v", res.Messages, want)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+const redactedMask = "****"
+
+// Redaction masks sensitive values before messages are shown, logs on disk
+// are never touched. Fields are dotted paths whose whole values are masked,
+// Patterns are regular expressions masked wherever they match in string
+// values. With Hash masks are short hashes of the masked values instead of
+// stars, so equal values can still be told apart from different ones and
+// the fieldhash op can filter by them. Ad-hoc rules see lines as shown, so
+// they can only filter by masks themselves, see effectiveRule.
+type Redaction struct {
+	Fields   []string
+	Patterns []string
+	Hash     bool
+}
+
+type redactor struct {
+	fields   []string
+	patterns []*regexp.Regexp
+	hash     bool
+}
+
+// compile returns nil redactor for nil or empty redaction
+func (r *Redaction) compile() (*redactor, error) {
+	if r == nil || len(r.Fields)+len(r.Patterns) == 0 {
+		return nil, nil
+	}
+	ret := &redactor{fields: r.Fields, hash: r.Hash}
+	for _, p := range r.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("redaction pattern %q: %w", p, err)
+		}
+		ret.patterns = append(ret.patterns, re)
+	}
+	return ret, nil
+}
+
+func (r *redactor) mask(v any) string {
+	if !r.hash {
+		return redactedMask
+	}
+	return "#" + rules.ValueHash(v)
+}
+
+// message redacts parsed message in place
+func (r *redactor) message(msg map[string]any) {
+	if r == nil {
+		return
+	}
+	for _, path := range r.fields {
+		if v, ok := rules.LookupField(msg, path); ok {
+			setField(msg, path, r.mask(v))
+		}
+	}
+	if len(r.patterns) > 0 {
+		for k, v := range msg {
+			msg[k] = r.value(v)
+		}
+	}
+}
+
+// value applies patterns to strings found in v
+func (r *redactor) value(v any) any {
+	switch v := v.(type) {
+	case string:
+		return r.text(v)
+	case map[string]any:
+		for k, el := range v {
+			v[k] = r.value(el)
+		}
+	case []any:
+		for i, el := range v {
+			v[i] = r.value(el)
+		}
+	}
+	return v
+}
+
+func (r *redactor) text(s string) string {
+	for _, re := range r.patterns {
+		s = re.ReplaceAllStringFunc(s, func(m string) string { return r.mask(m) })
+	}
+	return s
+}
+
+// line redacts a raw line. JSON objects are re-encoded after redaction, so
+// key order and formatting of the original are lost, anything else only has
+// patterns applied.
+func (r *redactor) line(line string) string {
+	if r == nil {
+		return line
+	}
+	msg := map[string]any{}
+	if json.Unmarshal([]byte(line), &msg) != nil {
+		return r.text(line)
+	}
+	r.message(msg)
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return r.text(line)
+	}
+	return string(b)
+}
+
+// redactedOp makes the redacted op, which evaluates the rule in Data
+// against the line redacted with r. It is only there for scans, see
+// ruleMatcher.
+func redactedOp(r *Redaction) rules.OpFn {
+	red, err := r.compile()
+	return func(ops rules.Ops, data, arg any) (bool, error) {
+		if err != nil {
+			return false, err
+		}
+		rule, err := rules.DataToRule(data)
+		if err != nil {
+			return false, fmt.Errorf("rule redacted: %w", err)
+		}
+		l, ok := arg.(*rules.Line)
+		if !ok {
+			return false, errors.New("rule redacted: only works on lines")
+		}
+		return rule.Run(ops, rules.NewLine(red.line(l.Raw)))
+	}
+}
+
+// setField replaces value at dotted path, which has to exist
+func setField(msg map[string]any, path string, v any) {
+	parts := strings.Split(path, ".")
+	cur := msg
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := cur[p].(map[string]any)
+		if !ok {
+			return
+		}
+		cur = next
+	}
+	cur[parts[len(parts)-1]] = v
+}
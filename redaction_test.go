@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+func TestRedactorMessage(t *testing.T) {
+	red, err := (&Redaction{
+		Fields:   []string{"token", "req.auth", "missing", "msg.deeper"},
+		Patterns: []string{`[a-z]+@example\.com`},
+	}).compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := map[string]any{
+		"token": "s3cr3t",
+		"msg":   "mail bob@example.com and amy@example.com",
+		"req":   map[string]any{"auth": map[string]any{"user": "bob"}, "path": "/"},
+		"to":    []any{"bob@example.com", 1.0, map[string]any{"cc": "amy@example.com"}},
+		"n":     1.0,
+	}
+	red.message(msg)
+	want := map[string]any{
+		"token": redactedMask,
+		"msg":   "mail **** and ****",
+		"req":   map[string]any{"auth": redactedMask, "path": "/"},
+		"to":    []any{redactedMask, 1.0, map[string]any{"cc": redactedMask}},
+		"n":     1.0,
+	}
+	if !reflect.DeepEqual(msg, want) {
+		t.Errorf("got %v, want %v", msg, want)
+	}
+
+	if got := red.line("token s3cr3t for bob@example.com"); got != "token s3cr3t for ****" {
+		t.Errorf("plain line: got %q", got)
+	}
+	var nilRed *redactor
+	if got := nilRed.line(`{"token":"s3cr3t"}`); got != `{"token":"s3cr3t"}` {
+		t.Errorf("nil redactor changed line to %q", got)
+	}
+	if r, err := (&Redaction{}).compile(); r != nil || err != nil {
+		t.Errorf("empty redaction compiled to %v, %v", r, err)
+	}
+	if _, err := (&Redaction{Patterns: []string{"("}}).compile(); err == nil {
+		t.Error("bad pattern accepted")
+	}
+}
+
+func TestRedactorHash(t *testing.T) {
+	red, err := (&Redaction{Fields: []string{"user"}, Hash: true}).compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := map[string]any{"user": "bob"}
+	b := map[string]any{"user": "bob"}
+	c := map[string]any{"user": "amy"}
+	red.message(a)
+	red.message(b)
+	red.message(c)
+	if a["user"] != b["user"] || a["user"] == c["user"] || a["user"] == "bob" {
+		t.Errorf("got hashes %v, %v, %v", a["user"], b["user"], c["user"])
+	}
+	// fieldhash filters by the shown hash
+	ok, err := rules.Rule{Op: "fieldhash", Data: map[string]any{"Field": "user", "Hash": a["user"]}}.Match(`{"user":"bob"}`)
+	if err != nil || !ok {
+		t.Errorf("fieldhash with shown hash: got %v, %v", ok, err)
+	}
+}
+
+// TestRedactedOutputs checks every way a directory is shown for the secret
+// values. There is no CSV export, NDJSON is the export format.
+func TestRedactedOutputs(t *testing.T) {
+	const secret = "tok-s3cr3t"
+	dir := writeLogDir(t, map[string]string{
+		"a.log": `{"level":"info","message":"login for bob@example.com","token":"` + secret + `","user":"bob"}` + "\n" +
+			`plain text with bob@example.com` + "\n",
+	})
+	withSaved(t, SavedStuff{
+		RuleSets: map[string]*rules.Rule{"all": {Op: "always"}, "none": {Op: "never"}},
+		LogDirs:  map[string]map[string]*rules.Rule{dir: {}},
+		DirSettings: map[string]*DirSettings{dir: {
+			Redact: &Redaction{Fields: []string{"token"}, Patterns: []string{`[a-z]+@example\.com`}},
+		}},
+	})
+	d := url.PathEscape(dir)
+	for _, path := range []string{
+		"/view/" + d,
+		"/api/view/" + d,
+		"/export.ndjson/" + d,
+		"/search?q=login",
+		"/groupby/" + d + "?field=token",
+		"/diff/" + d + "/all/none",
+	} {
+		t.Run(path, func(t *testing.T) {
+			code, body := get(t, path)
+			if code != http.StatusOK {
+				t.Fatalf("got %d: %s", code, body)
+			}
+			for _, s := range []string{secret, "bob@example.com"} {
+				if strings.Contains(body, s) {
+					t.Errorf("output has %s", s)
+				}
+			}
+			if !strings.Contains(body, redactedMask) {
+				t.Error("output has no masks")
+			}
+		})
+	}
+
+	_, body := get(t, "/export.ndjson/"+d)
+	var got []map[string]any
+	dec := json.NewDecoder(strings.NewReader(body))
+	for dec.More() {
+		var m map[string]any
+		if err := dec.Decode(&m); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, m)
+	}
+	if len(got) != 2 {
+		t.Fatalf("exported %d messages", len(got))
+	}
+	for _, m := range got {
+		if _, ok := m["token"]; ok && m["token"] != redactedMask {
+			t.Errorf("exported token %v", m["token"])
+		}
+		if m["user"] != nil && m["user"] != "bob" {
+			t.Errorf("field not asked for was changed to %v", m["user"])
+		}
+	}
+}
+
+// TestAdHocRuleRedacted checks ad-hoc rules can't find out masked values
+// by filtering on them
+func TestAdHocRuleRedacted(t *testing.T) {
+	dir := writeLogDir(t, map[string]string{
+		"a.log": `{"token":"tok-s3cr3t","user":"bob"}` + "\n" + `{"token":"other","user":"amy"}` + "\n",
+	})
+	withSaved(t, SavedStuff{
+		RuleSets:    map[string]*rules.Rule{"secret": {Op: "contains", Data: "s3cr3t"}},
+		LogDirs:     map[string]map[string]*rules.Rule{dir: {}},
+		DirSettings: map[string]*DirSettings{dir: {Redact: &Redaction{Fields: []string{"token"}, Hash: true}}},
+	})
+	count := func(query string) string {
+		t.Helper()
+		code, body := get(t, "/api/count/"+url.PathEscape(dir)+query)
+		if code != http.StatusOK {
+			t.Fatalf("got %d: %s", code, body)
+		}
+		return body
+	}
+	for _, rule := range []string{
+		`{"Op":"contains","Data":"s3cr3t"}`,
+		`{"Op":"fieldhash","Data":{"Field":"token","Hash":"#` + rules.ValueHash("tok-s3cr3t") + `"}}`,
+	} {
+		if body := count("?rule=" + url.QueryEscape(rule)); !strings.Contains(body, `"matched":0`) {
+			t.Errorf("ad-hoc %s matched masked value: %s", rule, body)
+		}
+	}
+	mask := `{"Op":"contains","Data":"#` + rules.ValueHash("tok-s3cr3t") + `"}`
+	if body := count("?rule=" + url.QueryEscape(mask)); !strings.Contains(body, `"matched":1`) {
+		t.Errorf("ad-hoc rule didn't match the shown mask: %s", body)
+	}
+	// saved rule sets are the operator's and see lines as they are
+	if body := count("/secret"); !strings.Contains(body, `"matched":1`) {
+		t.Errorf("saved rule set: %s", body)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+const defaultRemoteConfigRefresh = time.Minute
+
+// RemoteConfig is where centrally managed rule sets are fetched from. The
+// URL serves a config in the saved.json format, only its RuleSets and
+// Constants are used and they are added to local ones, local definitions
+// win on name clashes. Placeholders of remote rule sets only resolve to
+// Constants, never to environment variables. Fetched configs are validated
+// before use, when a fetch fails or the config is invalid the last good one
+// stays in use.
+type RemoteConfig struct {
+	URL     string
+	Headers map[string]string // sent along, like Authorization
+	Refresh int               // seconds between fetches, 0 for defaultRemoteConfigRefresh
+}
+
+func (rc *RemoteConfig) refresh() time.Duration {
+	if rc.Refresh <= 0 {
+		return defaultRemoteConfigRefresh
+	}
+	return time.Duration(rc.Refresh) * time.Second
+}
+
+func (rc *RemoteConfig) equal(other *RemoteConfig) bool {
+	return rc.URL == other.URL && rc.Refresh == other.Refresh && maps.Equal(rc.Headers, other.Headers)
+}
+
+// remoteConfigState is the config being fetched and the last fetch, good is
+// kept across failed ones
+type remoteConfigState struct {
+	rc      RemoteConfig
+	fetched time.Time // start of the last attempt
+	good    *SavedStuff
+	err     error
+}
+
+var (
+	// remoteConfig is only held to read or update the state, fetches happen
+	// without it so requests never wait for the remote
+	remoteConfig   remoteConfigState
+	remoteConfigMu sync.Mutex
+	// remoteConfigFetchMu keeps fetches one at a time
+	remoteConfigFetchMu sync.Mutex
+	remoteConfigWake    = make(chan struct{}, 1)
+	remoteConfigStart   sync.Once
+
+	// remoteConfigMinReload is how often reload may fetch ahead of Refresh,
+	// the reload endpoint needs no auth
+	remoteConfigMinReload = 10 * time.Second
+
+	remoteConfigClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+// current returns the last good remote config and the error of the last
+// attempt without waiting for a fetch. Configs are fetched in the background
+// every Refresh, a config seen for the first time is fetched right away and
+// until that is done there is none.
+func (rc *RemoteConfig) current() (*SavedStuff, error) {
+	remoteConfigStart.Do(func() { go runRemoteConfigFetcher() })
+	remoteConfigMu.Lock()
+	defer remoteConfigMu.Unlock()
+	if !remoteConfig.rc.equal(rc) {
+		remoteConfig = remoteConfigState{rc: RemoteConfig{URL: rc.URL, Headers: maps.Clone(rc.Headers), Refresh: rc.Refresh}}
+		select {
+		case remoteConfigWake <- struct{}{}:
+		default:
+		}
+	}
+	return remoteConfig.good, remoteConfig.err
+}
+
+// reload fetches the config right away unless that was done less than
+// remoteConfigMinReload ago, then the last result is returned
+func (rc *RemoteConfig) reload() (*SavedStuff, error) {
+	rc.current()
+	remoteConfigMu.Lock()
+	cur := remoteConfig.rc
+	remoteConfigMu.Unlock()
+	return fetchRemoteConfigState(cur, remoteConfigMinReload)
+}
+
+// runRemoteConfigFetcher fetches the config current last saw whenever its
+// Refresh passes
+func runRemoteConfigFetcher() {
+	for {
+		remoteConfigMu.Lock()
+		rc := remoteConfig.rc
+		wait := rc.refresh() - time.Since(remoteConfig.fetched)
+		remoteConfigMu.Unlock()
+		switch {
+		case rc.URL == "":
+			<-remoteConfigWake
+		case wait > 0:
+			select {
+			case <-time.After(wait):
+			case <-remoteConfigWake:
+			}
+		default:
+			fetchRemoteConfigState(rc, rc.refresh())
+		}
+	}
+}
+
+// fetchRemoteConfigState fetches rc unless the last attempt is younger than
+// minAge and returns the state after it. Results for a config that was
+// replaced meanwhile are dropped.
+func fetchRemoteConfigState(rc RemoteConfig, minAge time.Duration) (*SavedStuff, error) {
+	remoteConfigFetchMu.Lock()
+	defer remoteConfigFetchMu.Unlock()
+	remoteConfigMu.Lock()
+	if !remoteConfig.rc.equal(&rc) || (!remoteConfig.fetched.IsZero() && time.Since(remoteConfig.fetched) < minAge) {
+		defer remoteConfigMu.Unlock()
+		return remoteConfig.good, remoteConfig.err
+	}
+	remoteConfig.fetched = time.Now()
+	remoteConfigMu.Unlock()
+
+	got, err := fetchRemoteConfig(&rc)
+
+	remoteConfigMu.Lock()
+	defer remoteConfigMu.Unlock()
+	if !remoteConfig.rc.equal(&rc) {
+		return remoteConfig.good, remoteConfig.err
+	}
+	remoteConfig.err = err
+	if err != nil {
+		log.Warn().Err(err).Str("url", rc.URL).Msg("fetching remote config, keeping last good one")
+	} else {
+		remoteConfig.good = got
+	}
+	return remoteConfig.good, remoteConfig.err
+}
+
+func fetchRemoteConfig(rc *RemoteConfig) (*SavedStuff, error) {
+	req, err := http.NewRequest(http.MethodGet, rc.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range rc.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := remoteConfigClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("remote config: %s", resp.Status)
+	}
+	got := SavedStuff{}
+	err = json.NewDecoder(resp.Body).Decode(&got)
+	if err != nil {
+		return nil, fmt.Errorf("remote config: %w", err)
+	}
+	got = SavedStuff{RuleSets: got.RuleSets, Constants: got.Constants, remoteRuleSets: map[string]bool{}}
+	for k := range got.RuleSets {
+		got.remoteRuleSets[k] = true
+	}
+	if errs := got.validate(); len(errs) > 0 {
+		return nil, fmt.Errorf("remote config: %w (%d problems in total)", errs[0], len(errs))
+	}
+	return &got, nil
+}
+
+// withRemote adds rule sets and constants of the remote config to the
+// local ones and remembers which rule sets are remote
+func (s SavedStuff) withRemote(remote *SavedStuff) SavedStuff {
+	if remote == nil {
+		return s
+	}
+	ruleSets := maps.Clone(s.RuleSets)
+	if ruleSets == nil {
+		ruleSets = map[string]*rules.Rule{}
+	}
+	s.remoteRuleSets = map[string]bool{}
+	for k, v := range remote.RuleSets {
+		if _, ok := ruleSets[k]; !ok {
+			ruleSets[k] = v
+			s.remoteRuleSets[k] = true
+		}
+	}
+	s.RuleSets = ruleSets
+	constants := maps.Clone(remote.Constants)
+	if constants == nil {
+		constants = map[string]string{}
+	}
+	maps.Copy(constants, s.Constants)
+	s.Constants = constants
+	return s
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+// remoteConfigServer serves a config with rule set "central" while ok is
+// true and fails otherwise, hits counts requests
+type remoteConfigServer struct {
+	*httptest.Server
+	hits atomic.Int32
+	ok   atomic.Bool
+	hold chan struct{} // requests wait on it when set
+}
+
+func newRemoteConfigServer(t *testing.T, hold chan struct{}) *remoteConfigServer {
+	t.Helper()
+	srv := &remoteConfigServer{hold: hold}
+	srv.ok.Store(true)
+	srv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		srv.hits.Add(1)
+		if srv.hold != nil {
+			<-srv.hold
+		}
+		if r.Header.Get("Authorization") != "Bearer t0ken" || !srv.ok.Load() {
+			http.Error(w, "no", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(SavedStuff{RuleSets: map[string]*rules.Rule{"central": {Op: "always"}}})
+	}))
+	t.Cleanup(srv.Close)
+	// the fetcher outlives tests, leave it nothing to fetch
+	t.Cleanup(func() {
+		remoteConfigMu.Lock()
+		remoteConfig = remoteConfigState{}
+		remoteConfigMu.Unlock()
+	})
+	return srv
+}
+
+func withRemoteConfig(t *testing.T, url string, refresh int) {
+	t.Helper()
+	withSaved(t, SavedStuff{
+		RuleSets: map[string]*rules.Rule{"local": {Op: "never"}},
+		Settings: Settings{RemoteConfig: &RemoteConfig{URL: url, Headers: map[string]string{"Authorization": "Bearer t0ken"}, Refresh: refresh}},
+	})
+}
+
+// waitRuleSet loads config until it has the rule set
+func waitRuleSet(t *testing.T, name string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		saved, err := loadSaved()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if saved.RuleSets[name] != nil {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("rule set %s never showed up", name)
+}
+
+func TestRemoteConfigDoesNotBlock(t *testing.T) {
+	hold := make(chan struct{})
+	srv := newRemoteConfigServer(t, hold)
+	withRemoteConfig(t, srv.URL, 0)
+	start := time.Now()
+	for range 10 {
+		saved, err := loadSaved()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if saved.RuleSets["local"] == nil || saved.RuleSets["central"] != nil {
+			t.Fatalf("got rule sets %v before the fetch finished", saved.RuleSets)
+		}
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("loading config took %v with the remote hanging", took)
+	}
+	close(hold)
+	waitRuleSet(t, "central")
+}
+
+func TestRemoteConfigKeepsLastGood(t *testing.T) {
+	srv := newRemoteConfigServer(t, nil)
+	withRemoteConfig(t, srv.URL, 0)
+	prev := remoteConfigMinReload
+	remoteConfigMinReload = 0
+	t.Cleanup(func() { remoteConfigMinReload = prev })
+	rc := &RemoteConfig{URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer t0ken"}}
+	got, err := rc.reload()
+	if err != nil || got == nil || got.RuleSets["central"] == nil {
+		t.Fatalf("got %v, %v", got, err)
+	}
+	srv.ok.Store(false)
+	got, err = rc.reload()
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("got error %v, want the failed fetch", err)
+	}
+	if got == nil || got.RuleSets["central"] == nil {
+		t.Error("last good config dropped")
+	}
+	waitRuleSet(t, "central")
+}
+
+// TestRemoteConfigReloadRateLimited checks the unauthenticated reload
+// endpoint can't be used to hammer the remote
+func TestRemoteConfigReloadRateLimited(t *testing.T) {
+	srv := newRemoteConfigServer(t, nil)
+	withRemoteConfig(t, srv.URL, 0)
+	for i := range 5 {
+		rec := httptest.NewRecorder()
+		newHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/reload", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("reload %d: got %d: %s", i, rec.Code, rec.Body.String())
+		}
+	}
+	if n := srv.hits.Load(); n != 1 {
+		t.Errorf("5 reloads fetched %d times, want once", n)
+	}
+}
+
+func TestRemoteConfigRefreshes(t *testing.T) {
+	srv := newRemoteConfigServer(t, nil)
+	withRemoteConfig(t, srv.URL, 1)
+	waitRuleSet(t, "central")
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.hits.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("fetched %d times, want a fetch every second", srv.hits.Load())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// another URL replaces the config and is fetched right away
+	other := newRemoteConfigServer(t, nil)
+	withRemoteConfig(t, other.URL, 60)
+	waitRuleSet(t, "central")
+	if other.hits.Load() != 1 {
+		t.Errorf("new config fetched %d times", other.hits.Load())
+	}
+}
+
+func TestRemoteConfigOnIndex(t *testing.T) {
+	srv := newRemoteConfigServer(t, nil)
+	logDir := writeLogDir(t, map[string]string{"a.log": `{"level":"info"}` + "\n"})
+	withSaved(t, SavedStuff{
+		LogDirs:  map[string]map[string]*rules.Rule{logDir: {}},
+		Settings: Settings{RemoteConfig: &RemoteConfig{URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer t0ken"}}},
+	})
+	waitRuleSet(t, "central")
+	code, body := get(t, "/")
+	if code != http.StatusOK || !strings.Contains(body, "/central") {
+		t.Errorf("index does not list the remote rule set: %d %s", code, body)
+	}
+
+	// a broken config is shown instead of failing the request
+	err := os.WriteFile("saved.json", []byte("{"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, body = get(t, "/")
+	if code != http.StatusOK || !strings.Contains(body, "unexpected end of JSON input") {
+		t.Errorf("got %d %s", code, body)
+	}
+}
+
+// TestRemoteRuleSetsNoEnv checks whoever controls the remote can't read
+// environment variables of the server through placeholders
+func TestRemoteRuleSetsNoEnv(t *testing.T) {
+	t.Setenv("JLV_TEST_SECRET", "hunter2")
+	secret := &rules.Rule{Op: "contains", Data: "${JLV_TEST_SECRET}"}
+	local := SavedStuff{
+		RuleSets:  map[string]*rules.Rule{"local": secret},
+		Constants: map[string]string{"HOST": "db1"},
+	}
+	saved := local.withRemote(&SavedStuff{RuleSets: map[string]*rules.Rule{
+		"central": secret,
+		"host":    {Op: "contains", Data: "${HOST}"},
+		"local":   {Op: "never"},
+	}})
+
+	for name, want := range map[string]string{"local": "hunter2", "host": "db1"} {
+		r, err := lookupRule(saved, "", name)
+		if err != nil || r == nil || r.Data != want {
+			t.Errorf("rule set %s: got %v, %v, want %q", name, r, err, want)
+		}
+	}
+	_, err := lookupRule(saved, "", "central")
+	if err == nil || !strings.Contains(err.Error(), "not defined in constants") {
+		t.Errorf("remote rule set resolved from environment: %v", err)
+	}
+	if _, err := dirRuleSets(saved, ""); err == nil {
+		t.Error("dirRuleSets resolved remote rule set from environment")
+	}
+	if errs := saved.validate(); len(errs) != 1 || !strings.Contains(errs[0].Error(), `rule set "central"`) {
+		t.Errorf("got validation errors %v", errs)
+	}
+
+	// such configs are refused when fetched
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(SavedStuff{RuleSets: map[string]*rules.Rule{"central": secret}})
+	}))
+	t.Cleanup(srv.Close)
+	got, err := fetchRemoteConfig(&RemoteConfig{URL: srv.URL})
+	if err == nil {
+		t.Errorf("fetched %v", got.RuleSets)
+	}
+
+	// nor does the compiled rule endpoint show it
+	withSaved(t, local)
+	remoteConfigMu.Lock()
+	remoteConfig = remoteConfigState{rc: RemoteConfig{URL: srv.URL}, fetched: time.Now(), good: &SavedStuff{RuleSets: map[string]*rules.Rule{"central": secret}, remoteRuleSets: map[string]bool{"central": true}}}
+	remoteConfigMu.Unlock()
+	t.Cleanup(func() {
+		remoteConfigMu.Lock()
+		remoteConfig = remoteConfigState{}
+		remoteConfigMu.Unlock()
+	})
+	b, err := json.Marshal(SavedStuff{RuleSets: local.RuleSets, Constants: local.Constants, Settings: Settings{RemoteConfig: &RemoteConfig{URL: srv.URL}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("saved.json", b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	code, body := get(t, "/api/rule/central/compiled")
+	if code != http.StatusUnprocessableEntity || strings.Contains(body, "hunter2") {
+		t.Errorf("got %d %s", code, body)
+	}
+}
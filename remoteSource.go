@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// remoteClient fetches remote sources, bodies are streamed so there is no
+// overall timeout, only one for the server to start answering
+var remoteClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		ResponseHeaderTimeout: 30 * time.Second,
+	},
+}
+
+// isRemote tells whether a log directory from config is an HTTP(S) URL. Such
+// a source is a single log that is fetched on every scan and goes through
+// the same framing, rules and rendering as local files.
+func isRemote(dirPath string) bool {
+	return strings.HasPrefix(dirPath, "http://") || strings.HasPrefix(dirPath, "https://")
+}
+
+// remoteSource is what fetching a remote directory takes. Scans get it
+// through scanOptions built from config, remote directories of scans
+// without one are not fetched as views take directories from the request.
+type remoteSource struct {
+	ctx     context.Context // the fetch stops with it, like when the request goes away
+	headers map[string]string
+}
+
+// remoteSource is how scans fetch dirName, nil unless config lists it as a
+// remote source. Fetches are not bound by a context until withContext.
+func (s SavedStuff) remoteSource(dirName string) *remoteSource {
+	if _, ok := s.LogDirs[dirName]; !ok || !isRemote(dirName) {
+		return nil
+	}
+	return &remoteSource{ctx: context.Background(), headers: s.dirSettings(dirName).Headers}
+}
+
+// withContext has remote sources of the scan fetched with ctx
+func (o scanOptions) withContext(ctx context.Context) scanOptions {
+	if o.remote != nil {
+		o.remote = &remoteSource{ctx: ctx, headers: o.remote.headers}
+	}
+	return o
+}
+
+// openRemote starts fetching the URL as src says, bodies of URLs with a .gz
+// or .zst path are decompressed as they are read
+func openRemote(rawURL string, src *remoteSource) (io.ReadCloser, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if src == nil {
+		return nil, fmt.Errorf("remote source %s is not in LogDirs", u.Redacted())
+	}
+	req, err := http.NewRequestWithContext(src.ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range src.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetching %s: %s", u.Redacted(), resp.Status)
+	}
+	lr, _ := logReaderFor(u.Path)
+	if lr.decompress == nil {
+		return resp.Body, nil
+	}
+	r, err := lr.decompress(resp.Body)
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("decompressing %s: %w", u.Redacted(), err)
+	}
+	return logFile{ReadCloser: r, f: resp.Body}, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+const sourceLines = "{\"level\":\"info\",\"message\":\"one\"}\n{\"level\":\"error\",\"message\":\"two\"}\nplain three\n"
+
+// remoteLogServer serves sourceLines at /app.log and gzipped at /app.log.gz
+// to requests with the token
+func remoteLogServer(t *testing.T, token string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, "no", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/app.log":
+			io.WriteString(w, sourceLines)
+		case "/app.log.gz":
+			io.WriteString(w, gzipped(t, sourceLines))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func sourceMessages(t *testing.T, saved SavedStuff, dir string, rule *rules.Rule) []string {
+	t.Helper()
+	res, err := processDir(context.Background(), dir, saved.dirScanOptions(dir), rule, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret := []string{}
+	for _, m := range res.Messages {
+		ret = append(ret, m["message"].(string))
+	}
+	return ret
+}
+
+// TestSources runs the same scans over a local directory and remote URLs,
+// they have to give the same messages
+func TestSources(t *testing.T) {
+	srv := remoteLogServer(t, "t0ken")
+	local := writeLogDir(t, map[string]string{"app.log": sourceLines})
+	remote, remoteGz := srv.URL+"/app.log", srv.URL+"/app.log.gz"
+	headers := &DirSettings{Headers: map[string]string{"Authorization": "Bearer t0ken"}}
+	saved := SavedStuff{
+		LogDirs:     map[string]map[string]*rules.Rule{local: {}, remote: {}, remoteGz: {}},
+		DirSettings: map[string]*DirSettings{remote: headers, remoteGz: headers},
+	}
+	errors := &rules.Rule{Op: "eq", Data: map[string]any{"Field": "level", "Value": "error"}}
+	for _, rule := range []*rules.Rule{nil, errors} {
+		want := sourceMessages(t, saved, local, rule)
+		if len(want) == 0 {
+			t.Fatal("no messages from local directory")
+		}
+		for _, dir := range []string{remote, remoteGz} {
+			if got := sourceMessages(t, saved, dir, rule); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s with rule %v: got %q, want %q like local", dir, rule, got, want)
+			}
+		}
+	}
+}
+
+func TestRemoteSourceErrors(t *testing.T) {
+	srv := remoteLogServer(t, "t0ken")
+	listed, noAuth, missing := srv.URL+"/app.log", srv.URL+"/app.log?noauth", srv.URL+"/missing.log"
+	headers := &DirSettings{Headers: map[string]string{"Authorization": "Bearer t0ken"}}
+	saved := SavedStuff{
+		LogDirs:     map[string]map[string]*rules.Rule{listed: {}, noAuth: {}, missing: {}},
+		DirSettings: map[string]*DirSettings{listed: headers, missing: headers},
+	}
+	for _, c := range []struct{ url, err string }{
+		{srv.URL + "/other.log", "not in LogDirs"},
+		{noAuth, "401"},
+		{missing, "404"},
+	} {
+		_, err := processDir(context.Background(), c.url, saved.scanOptions(viewParams{Dir: c.url}), nil, 10, 0)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: got %v, want error with %q", c.url, err, c.err)
+		}
+	}
+}
+
+// TestRemoteSourceStreams reads the first line while the server still holds
+// back the rest of the body
+func TestRemoteSourceStreams(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "{\"message\":\"first\"}\n")
+		w.(http.Flusher).Flush()
+		<-release
+		io.WriteString(w, "{\"message\":\"second\"}\n")
+	}))
+	defer srv.Close()
+	defer close(release)
+	body, err := openRemote(srv.URL+"/app.log", &remoteSource{ctx: context.Background()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	line, err := bufio.NewReader(body).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "{\"message\":\"first\"}\n" {
+		t.Errorf("got %q", line)
+	}
+}
+
+// TestRemoteSourceCancel stops a scan of a remote source that stalled mid
+// body once its context is done
+func TestRemoteSourceCancel(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "{\"message\":\"first\"}\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+	dir := srv.URL + "/app.log"
+	saved := SavedStuff{LogDirs: map[string]map[string]*rules.Rule{dir: {}}}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		res, err := processDir(ctx, dir, saved.dirScanOptions(dir), nil, 10, 0)
+		if err == nil && !res.TimedOut {
+			err = fmt.Errorf("got %+v, want a timed out scan", res)
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("scan kept waiting for the stalled body")
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/a-h/templ"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+const (
+	// diffDefaultMaxScan bounds diffs of directories without a line cap
+	diffDefaultMaxScan = 100000
+	diffSamples        = 20
+)
+
+// ruleDiffClass is one of A-only, B-only, both and neither
+type ruleDiffClass struct {
+	Name    string
+	Matched int
+	Samples []map[string]any
+	buf     *LogBuffer
+}
+
+type ruleDiff struct {
+	Dir          string
+	MessageField string
+	A, B         string
+	Scanned      int
+	CapHit       bool
+	Classes      []*ruleDiffClass
+}
+
+// diffRules evaluates both rules against every line in a single pass and
+// classifies lines by which of them matched
+func diffRules(dirPath string, opts scanOptions, a, b *rules.Rule) (*ruleDiff, error) {
+	ret := &ruleDiff{Dir: dirPath, MessageField: opts.messageField()}
+	for _, n := range []string{"only A", "only B", "both", "neither"} {
+		ret.Classes = append(ret.Classes, &ruleDiffClass{Name: n, buf: NewLogBuffer(diffSamples)})
+	}
+	red, err := opts.Redact.compile()
+	if err != nil {
+		return nil, err
+	}
+	matchA, matchB := ruleMatcher(a, opts), ruleMatcher(b, opts)
+	ret.CapHit, err = scanDir(dirPath, opts, func(fp, line string) error {
+		ret.Scanned++
+		ma, err := matchA(fp, line)
+		if err != nil {
+			return fmt.Errorf("rule set A: %w", err)
+		}
+		mb, err := matchB(fp, line)
+		if err != nil {
+			return fmt.Errorf("rule set B: %w", err)
+		}
+		var c *ruleDiffClass
+		switch {
+		case ma && !mb:
+			c = ret.Classes[0]
+		case !ma && mb:
+			c = ret.Classes[1]
+		case ma && mb:
+			c = ret.Classes[2]
+		default:
+			c = ret.Classes[3]
+		}
+		c.Matched++
+		c.buf.Push(line)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range ret.Classes {
+		samples, err := c.buf.Get(0, diffSamples)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range slices.Backward(samples) {
+			m := opts.parseMessage(l)
+			red.message(m)
+			opts.Projection.apply(m)
+			c.Samples = append(c.Samples, m)
+		}
+	}
+	return ret, nil
+}
+
+func handleRuleDiff(w http.ResponseWriter, r *http.Request) {
+	saved, err := loadSaved()
+	if err != nil {
+		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
+		return
+	}
+	p := parseViewParams(r)
+	nameA, nameB := r.PathValue("ruleSetA"), r.PathValue("ruleSetB")
+	lookup := func(name string) (*rules.Rule, error) {
+		rule, err := lookupRule(saved, p.Dir, name)
+		if err == nil && rule == nil {
+			err = fmt.Errorf("%w: %q", errRuleSetNotFound, name)
+		}
+		return rule, err
+	}
+	ruleA, err := lookup(nameA)
+	if err != nil {
+		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
+		return
+	}
+	ruleB, err := lookup(nameB)
+	if err != nil {
+		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
+		return
+	}
+	opts := saved.scanOptions(p).withContext(r.Context())
+	if opts.MaxLines <= 0 {
+		opts.MaxLines = diffDefaultMaxScan
+	}
+	diff, err := diffRules(p.Dir, opts, ruleA, ruleB)
+	if err != nil {
+		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
+		return
+	}
+	diff.A, diff.B = nameA, nameB
+	templ.Handler(tPage(tRuleDiff(diff))).ServeHTTP(w, r)
+}
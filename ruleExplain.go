@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+type apiExplainRequest struct {
+	Rule *rules.Rule `json:"rule"`
+	Line string      `json:"line"`
+}
+
+func handleAPIExplainRule(w http.ResponseWriter, r *http.Request) {
+	req := apiExplainRequest{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("parsing request: %w", err))
+		return
+	}
+	if req.Rule == nil {
+		writeJSONError(w, http.StatusBadRequest, errors.New("rule is missing"))
+		return
+	}
+	writeJSON(w, http.StatusOK, rules.Explain(ruleOps, *req.Rule, rules.NewLine(req.Line)))
+}
+
+// handleAPIRuleCompiled returns the rule set as views evaluate it, with
+// placeholders substituted and and/or children in evaluation order. With dir
+// parameter directory rule sets shadow global ones like they do in that
+// directory's views. The endpoint needs no auth, so placeholders resolved
+// from environment variables show as "<env:NAME>" rather than their values.
+func handleAPIRuleCompiled(w http.ResponseWriter, r *http.Request) {
+	saved, err := loadSaved()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	name := r.PathValue("ruleSetName")
+	rule, err := lookupRuleEnv(saved, r.URL.Query().Get("dir"), name, envRedacted)
+	if err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if rule == nil {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("%w: %q", errRuleSetNotFound, name))
+		return
+	}
+	writeJSON(w, http.StatusOK, saved.Settings.reorderRule(rule))
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+var rulePlaceholderRe = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// placeholderEnv is what placeholders not in Constants resolve to
+type placeholderEnv int
+
+const (
+	envNone     placeholderEnv = iota // nothing, they are errors
+	envResolve                        // environment variables
+	envRedacted                       // "<env:NAME>" when the variable is set, for showing rules
+)
+
+// resolveRule substitutes ${NAME} placeholders in string values of rule data
+// with Constants from the config or, failing that, environment variables.
+// Rules without placeholders are returned as is.
+func (s SavedStuff) resolveRule(r *rules.Rule) (*rules.Rule, error) {
+	return s.resolveRuleFrom(r, envResolve)
+}
+
+// resolveAdHocRule is resolveRule for rules from requests, which only get
+// Constants. Environment of the server is not for whoever can send a rule.
+func (s SavedStuff) resolveAdHocRule(r *rules.Rule) (*rules.Rule, error) {
+	return s.resolveRuleFrom(r, envNone)
+}
+
+// resolveRuleSet resolves the global rule set of the name with env. Rule
+// sets of the remote config are resolved like ad-hoc rules, the environment
+// of the server is not for whoever controls the remote either.
+func (s SavedStuff) resolveRuleSet(name string, env placeholderEnv) (*rules.Rule, error) {
+	if s.remoteRuleSets[name] {
+		env = envNone
+	}
+	return s.resolveRuleFrom(s.RuleSets[name], env)
+}
+
+func (s SavedStuff) resolveRuleFrom(r *rules.Rule, env placeholderEnv) (*rules.Rule, error) {
+	if r == nil {
+		return nil, nil
+	}
+	data, err := s.resolvePlaceholders(r.Data, env)
+	if err != nil {
+		return nil, fmt.Errorf("rule %s: %w", r.Op, err)
+	}
+	return &rules.Rule{Op: r.Op, Data: data}, nil
+}
+
+func (s SavedStuff) resolvePlaceholders(data any, env placeholderEnv) (any, error) {
+	switch d := data.(type) {
+	case string:
+		var err error
+		ret := rulePlaceholderRe.ReplaceAllStringFunc(d, func(m string) string {
+			name := rulePlaceholderRe.FindStringSubmatch(m)[1]
+			if v, ok := s.Constants[name]; ok {
+				return v
+			}
+			if v, ok := os.LookupEnv(name); ok && env != envNone {
+				if env == envRedacted {
+					return "<env:" + name + ">"
+				}
+				return v
+			}
+			if err == nil && env != envNone {
+				err = fmt.Errorf("placeholder %s is not defined in constants or environment", m)
+			}
+			if err == nil {
+				err = fmt.Errorf("placeholder %s is not defined in constants", m)
+			}
+			return m
+		})
+		return ret, err
+	case []any:
+		ret := make([]any, len(d))
+		for i, v := range d {
+			r, err := s.resolvePlaceholders(v, env)
+			if err != nil {
+				return nil, err
+			}
+			ret[i] = r
+		}
+		return ret, nil
+	case map[string]any:
+		ret := make(map[string]any, len(d))
+		for k, v := range d {
+			r, err := s.resolvePlaceholders(v, env)
+			if err != nil {
+				return nil, err
+			}
+			ret[k] = r
+		}
+		return ret, nil
+	}
+	return data, nil
+}
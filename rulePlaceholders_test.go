@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+func TestRulePlaceholders(t *testing.T) {
+	t.Setenv("JLV_TEST_SECRET", "s3cr3t")
+	saved := SavedStuff{
+		Constants: map[string]string{"SERVICE": "billing"},
+		RuleSets: map[string]*rules.Rule{
+			"env":   {Op: "contains", Data: "${JLV_TEST_SECRET}"},
+			"const": {Op: "contains", Data: "${SERVICE}"},
+		},
+	}
+	for _, c := range []struct {
+		name string
+		p    viewParams
+		want *rules.Rule
+		err  string
+	}{
+		{"rule set from env", viewParams{RuleSet: "env"}, &rules.Rule{Op: "contains", Data: "s3cr3t"}, ""},
+		{"rule set from constants", viewParams{RuleSet: "const"}, &rules.Rule{Op: "contains", Data: "billing"}, ""},
+		{"ad-hoc from constants", viewParams{Rule: `{"Op":"contains","Data":"${SERVICE}"}`}, &rules.Rule{Op: "contains", Data: "billing"}, ""},
+		{"ad-hoc not from env", viewParams{Rule: `{"Op":"contains","Data":"${JLV_TEST_SECRET}"}`}, nil, "placeholder ${JLV_TEST_SECRET} is not defined in constants"},
+		{"ad-hoc nested not from env", viewParams{Rule: `{"Op":"not","Data":{"Op":"contains","Data":"${JLV_TEST_SECRET}"}}`}, nil, "is not defined in constants"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := saved.effectiveRule(c.p)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("got error %v, want %q", err, c.err)
+				}
+				if strings.Contains(err.Error(), "s3cr3t") {
+					t.Errorf("error %q has environment value", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestAdHocRuleCantReadEnv(t *testing.T) {
+	t.Setenv("JLV_TEST_SECRET", "s3cr3t")
+	dir := writeLogDir(t, map[string]string{"a.log": "{\"message\":\"s3cr3t\"}\n"})
+	withSaved(t, SavedStuff{LogDirs: map[string]map[string]*rules.Rule{dir: {}}})
+	code, body := get(t, "/api/count/"+url.PathEscape(dir)+"?rule="+url.QueryEscape(`{"Op":"contains","Data":"${JLV_TEST_SECRET}"}`))
+	if code != http.StatusBadRequest {
+		t.Errorf("got status %d, want 400: %s", code, body)
+	}
+	if strings.Contains(body, "s3cr3t") {
+		t.Errorf("response has environment value: %s", body)
+	}
+}
+
+func TestRuleCompiledRedactsEnv(t *testing.T) {
+	t.Setenv("JLV_TEST_SECRET", "s3cr3t")
+	dir := writeLogDir(t, map[string]string{"a.log": "{\"message\":\"s3cr3t billing\"}\n"})
+	withSaved(t, SavedStuff{
+		Constants: map[string]string{"SERVICE": "billing"},
+		RuleSets: map[string]*rules.Rule{
+			"both": {Op: "and", Data: []any{
+				map[string]any{"Op": "contains", "Data": "${JLV_TEST_SECRET}"},
+				map[string]any{"Op": "contains", "Data": "${SERVICE}"},
+			}},
+			"undefined": {Op: "contains", Data: "${JLV_TEST_UNDEFINED}"},
+		},
+		LogDirs: map[string]map[string]*rules.Rule{dir: {"local": {Op: "contains", Data: "x${JLV_TEST_SECRET}x"}}},
+	})
+	for _, c := range []struct {
+		path string
+		want *rules.Rule
+	}{
+		{"/api/rule/both/compiled", &rules.Rule{Op: "and", Data: []any{
+			map[string]any{"Op": "contains", "Data": "<env:JLV_TEST_SECRET>"},
+			map[string]any{"Op": "contains", "Data": "billing"},
+		}}},
+		{"/api/rule/local/compiled?dir=" + url.QueryEscape(dir), &rules.Rule{Op: "contains", Data: "x<env:JLV_TEST_SECRET>x"}},
+	} {
+		code, body := get(t, c.path)
+		if strings.Contains(body, "s3cr3t") {
+			t.Errorf("%s: response has environment value: %s", c.path, body)
+		}
+		got := &rules.Rule{}
+		if code != http.StatusOK || json.Unmarshal([]byte(body), got) != nil {
+			t.Errorf("%s: got %d %s", c.path, code, body)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.path, got, c.want)
+		}
+	}
+	code, body := get(t, "/api/rule/undefined/compiled")
+	if code != http.StatusUnprocessableEntity || !strings.Contains(body, "is not defined in constants or environment") {
+		t.Errorf("got %d %s", code, body)
+	}
+
+	// views still match the value itself
+	code, body = get(t, "/api/count/"+url.PathEscape(dir)+"/both")
+	if code != http.StatusOK || !strings.Contains(body, `"matched":1`) {
+		t.Errorf("got %d %s", code, body)
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/a-h/templ"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+// ruleStatsTTL is how long match counts for a directory are reused before rescanning
+const ruleStatsTTL = 30 * time.Second
+
+type ruleMatchCount struct {
+	Name    string     `json:"name"`
+	Matched int        `json:"matched"`
+	Ops     []opTiming `json:"ops,omitempty"` // only with timing, see opTiming.go
+}
+
+type ruleStats struct {
+	Scanned     int              `json:"scanned"`
+	InvalidJSON int              `json:"invalid_json"` // lines shown as plain text, see invalidjson op
+	Counts      []ruleMatchCount `json:"counts"`
+	At          time.Time        `json:"at"`
+	Timing      bool             `json:"timing"`
+	CapHit      bool             `json:"cap_hit"`   // older lines were not scanned due to the line cap
+	TimedOut    bool             `json:"timed_out"` // scan was stopped by its context, see scanContext
+}
+
+// ruleStatsEntry is the cached stats of one directory, rule sets and scan
+// options combination, its mutex is held while the stats are collected
+type ruleStatsEntry struct {
+	mu      sync.Mutex
+	stats   *ruleStats
+	created time.Time
+}
+
+var (
+	ruleStatsCache   = map[string]*ruleStatsEntry{}
+	ruleStatsCacheMu sync.Mutex // guards the map only, never held while scanning
+)
+
+// collectRuleStats evaluates every rule against every line of the directory
+// in a single pass. With timing every op call is timed as well, which costs
+// two clock reads per op per line. When ctx is done the scan stops and what
+// was counted so far is returned as TimedOut.
+func collectRuleStats(ctx context.Context, dirPath string, opts scanOptions, ruleSets map[string]*rules.Rule, timing bool) (*ruleStats, error) {
+	opts = opts.withContext(ctx)
+	names := slices.Sorted(maps.Keys(ruleSets))
+	ret := &ruleStats{
+		Counts: make([]ruleMatchCount, len(names)),
+		At:     time.Now(),
+		Timing: timing,
+	}
+	matchers := make([]lineMatcher, len(names))
+	timings := make([]map[string]*opTiming, len(names))
+	for i, n := range names {
+		ret.Counts[i].Name = n
+		if !timing || ruleSets[n] == nil {
+			matchers[i] = ruleMatcher(ruleSets[n], opts)
+			continue
+		}
+		timings[i] = map[string]*opTiming{}
+		ops := timedOps(ruleOps.ForScan().WithTimeLayouts(opts.TimeLayouts), timings[i])
+		matchers[i] = func(fp, line string) (bool, error) {
+			return runRule(ops, ruleSets[n], fp, line)
+		}
+	}
+	var err error
+	ret.CapHit, err = scanDir(dirPath, opts, func(fp, line string) error {
+		ret.Scanned++
+		// checked every so often as it takes a lock
+		if ret.Scanned%1024 == 0 && ctx.Err() != nil {
+			ret.TimedOut = true
+			return errScanStopped
+		}
+		if !rules.IsJSONObject(line) {
+			ret.InvalidJSON++
+		}
+		for i, n := range names {
+			if matchers[i] == nil {
+				ret.Counts[i].Matched++
+				continue
+			}
+			match, err := matchers[i](fp, line)
+			if err != nil {
+				return fmt.Errorf("rule set %q: %w", n, err)
+			}
+			if match {
+				ret.Counts[i].Matched++
+			}
+		}
+		return nil
+	})
+	// reads of remote sources fail rather than stop when ctx is done
+	if errors.Is(err, errScanStopped) || (err != nil && ctx.Err() != nil) {
+		ret.TimedOut = true
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, t := range timings {
+		if t != nil {
+			ret.Counts[i].Ops = usedTimings(t)
+		}
+	}
+	return ret, nil
+}
+
+// getRuleStats returns cached stats for the directory if they are fresh enough,
+// otherwise rescans it. Stats are cached by directory, rule sets and scan
+// options, so editing a rule set is picked up right away. Scans of the same
+// combination are serialized so concurrent dashboard loads don't evaluate
+// everything several times over, other directories are not held up by them.
+// Timed scans are asked for when looking into something slow and always
+// run afresh, so do scans that were stopped by ctx.
+func getRuleStats(ctx context.Context, dirPath string, opts scanOptions, ruleSets map[string]*rules.Rule, timing bool) (*ruleStats, error) {
+	if timing {
+		return collectRuleStats(ctx, dirPath, opts, ruleSets, true)
+	}
+	e := ruleStatsEntryFor(ruleStatsKey(dirPath, opts, ruleSets))
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stats != nil && time.Since(e.stats.At) < ruleStatsTTL {
+		return e.stats, nil
+	}
+	s, err := collectRuleStats(ctx, dirPath, opts, ruleSets, false)
+	if err != nil {
+		return nil, err
+	}
+	if !s.TimedOut {
+		e.stats = s
+	}
+	return s, nil
+}
+
+func ruleStatsKey(dirPath string, opts scanOptions, ruleSets map[string]*rules.Rule) string {
+	return dirPath + "\x00" + rules.ValueHash(struct {
+		Opts     scanOptions
+		RuleSets map[string]*rules.Rule
+	}{opts, ruleSets})
+}
+
+// ruleStatsEntryFor returns the cache entry of the key, creating it if
+// needed. Stale entries that are not being collected are dropped on the way
+// so keys of edited rule sets don't pile up.
+func ruleStatsEntryFor(key string) *ruleStatsEntry {
+	ruleStatsCacheMu.Lock()
+	defer ruleStatsCacheMu.Unlock()
+	e, ok := ruleStatsCache[key]
+	if ok {
+		return e
+	}
+	for k, old := range ruleStatsCache {
+		if !old.mu.TryLock() {
+			continue
+		}
+		at := old.created
+		if old.stats != nil {
+			at = old.stats.At
+		}
+		if time.Since(at) >= ruleStatsTTL {
+			delete(ruleStatsCache, k)
+		}
+		old.mu.Unlock()
+	}
+	e = &ruleStatsEntry{created: time.Now()}
+	ruleStatsCache[key] = e
+	return e
+}
+
+// ruleStatsScanOptions read the directory as configured, capped to the
+// newest lines the same way views without maxscan parameter are
+func ruleStatsScanOptions(saved SavedStuff, dirName string) scanOptions {
+	opts := saved.dirScanOptions(dirName)
+	opts.MaxLines = saved.scanOptions(viewParams{Dir: dirName}).MaxLines
+	return opts
+}
+
+// dirRuleSets merges global and directory rule sets, directory ones take precedence
+func dirRuleSets(saved SavedStuff, dirName string) (map[string]*rules.Rule, error) {
+	ret := map[string]*rules.Rule{}
+	for k := range saved.RuleSets {
+		r, err := saved.resolveRuleSet(k, envResolve)
+		if err != nil {
+			return nil, fmt.Errorf("rule set %q: %w", k, err)
+		}
+		ret[k] = r
+	}
+	for k, v := range saved.LogDirs[dirName] {
+		r, err := saved.resolveRule(v)
+		if err != nil {
+			return nil, fmt.Errorf("rule set %q: %w", k, err)
+		}
+		ret[k] = r
+	}
+	return ret, nil
+}
+
+func handleRuleStats(w http.ResponseWriter, r *http.Request) {
+	saved, err := loadSaved()
+	if err != nil {
+		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
+		return
+	}
+	dirName := r.PathValue("dirName")
+	ruleSets, err := dirRuleSets(saved, dirName)
+	if err != nil {
+		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
+		return
+	}
+	ctx, cancel := saved.Settings.scanContext(r)
+	defer cancel()
+	stats, err := getRuleStats(ctx, dirName, ruleStatsScanOptions(saved, dirName), ruleSets, r.URL.Query().Has("timing"))
+	if err != nil {
+		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
+		return
+	}
+	templ.Handler(tPage(tRuleStats(dirName, stats))).ServeHTTP(w, r)
+}
+
+func handleRuleStatsMetrics(w http.ResponseWriter, r *http.Request) {
+	saved, err := loadSaved()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	dirName := r.PathValue("dirName")
+	ruleSets, err := dirRuleSets(saved, dirName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ctx, cancel := saved.Settings.scanContext(r)
+	defer cancel()
+	stats, err := getRuleStats(ctx, dirName, ruleStatsScanOptions(saved, dirName), ruleSets, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+	fmt.Fprintln(w, "# TYPE logviewer_lines_scanned gauge")
+	fmt.Fprintf(w, "logviewer_lines_scanned{dir=\"%s\"} %d\n", metricLabel(dirName), stats.Scanned)
+	fmt.Fprintln(w, "# TYPE logviewer_lines_invalid_json gauge")
+	fmt.Fprintf(w, "logviewer_lines_invalid_json{dir=\"%s\"} %d\n", metricLabel(dirName), stats.InvalidJSON)
+	fmt.Fprintln(w, "# TYPE logviewer_rule_matches gauge")
+	for _, c := range stats.Counts {
+		fmt.Fprintf(w, "logviewer_rule_matches{dir=\"%s\",ruleset=\"%s\"} %d\n", metricLabel(dirName), metricLabel(c.Name), c.Matched)
+	}
+	fmt.Fprintln(w, "# EOF")
+}
+
+// metricLabel escapes a label value the way OpenMetrics wants it, which is
+// only backslash, double quote and line feed
+var metricLabel = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace
+
+// handleAPIRuleStats is the stats page as JSON, timing parameter turns on
+// per-op timing
+func handleAPIRuleStats(w http.ResponseWriter, r *http.Request) {
+	saved, err := loadSaved()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	dirName := r.PathValue("dirName")
+	ruleSets, err := dirRuleSets(saved, dirName)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	ctx, cancel := saved.Settings.scanContext(r)
+	defer cancel()
+	stats, err := getRuleStats(ctx, dirName, ruleStatsScanOptions(saved, dirName), ruleSets, r.URL.Query().Has("timing"))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func ruleStatsBarSize(stats *ruleStats, matched int) templ.SafeCSS {
+	size := 0.0
+	if stats.Scanned > 0 {
+		size = float64(matched) / float64(stats.Scanned)
+	}
+	return templ.SafeCSS(fmt.Sprintf("--size: %.4f;", size))
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+func TestGetRuleStats(t *testing.T) {
+	dir := writeLogDir(t, map[string]string{
+		"a.log": `{"level":"error","message":"a"}` + "\n" + `{"level":"info","message":"b"}` + "\n",
+	})
+	ruleSets := map[string]*rules.Rule{
+		"err": {Op: "fieldcontains", Data: map[string]any{"Field": "level", "Value": "error"}},
+	}
+	got, err := getRuleStats(context.Background(), dir, scanOptions{}, ruleSets, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Scanned != 2 || got.Counts[0].Matched != 1 {
+		t.Fatalf("got %d scanned and %d matched, want 2 and 1", got.Scanned, got.Counts[0].Matched)
+	}
+	again, err := getRuleStats(context.Background(), dir, scanOptions{}, ruleSets, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != got {
+		t.Error("stats were collected again instead of cached")
+	}
+
+	ruleSets["err"] = &rules.Rule{Op: "contains", Data: `"message"`}
+	edited, err := getRuleStats(context.Background(), dir, scanOptions{}, ruleSets, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.Counts[0].Matched != 2 {
+		t.Errorf("got %d matched after editing the rule set, want 2", edited.Counts[0].Matched)
+	}
+}
+
+func TestGetRuleStatsOtherDirNotBlocked(t *testing.T) {
+	slow := writeLogDir(t, map[string]string{"a.log": "a\n"})
+	other := writeLogDir(t, map[string]string{"a.log": "a\n"})
+	ruleSets := map[string]*rules.Rule{"all": nil}
+
+	// holding the entry is what a scan of the slow directory does
+	e := ruleStatsEntryFor(ruleStatsKey(slow, scanOptions{}, ruleSets))
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	done := make(chan error)
+	go func() {
+		_, err := getRuleStats(context.Background(), other, scanOptions{}, ruleSets, false)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stats of another directory waited for the slow one")
+	}
+}
+
+// TestRuleStatsInvalidJSON counts lines the invalidjson op matches
+func TestRuleStatsInvalidJSON(t *testing.T) {
+	dir := writeLogDir(t, map[string]string{
+		"a.log": "{\"message\":\"ok\"}\nnull\n{\"message\":\n[1,2]\nplain text\n",
+	})
+	ruleSets := map[string]*rules.Rule{"invalid": {Op: "invalidjson"}}
+	got, err := collectRuleStats(context.Background(), dir, scanOptions{}, ruleSets, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Scanned != 5 || got.InvalidJSON != 3 {
+		t.Errorf("got %d invalid of %d scanned, want 3 of 5", got.InvalidJSON, got.Scanned)
+	}
+	if got.Counts[0].Matched != got.InvalidJSON {
+		t.Errorf("invalidjson matched %d, stats counted %d", got.Counts[0].Matched, got.InvalidJSON)
+	}
+}
+
+// TestRuleStatsPageStyles checks the dashboard only links stylesheets that
+// are there and its bars are styled by them
+func TestRuleStatsPageStyles(t *testing.T) {
+	dir := writeLogDir(t, map[string]string{"a.log": `{"level":"error"}` + "\n"})
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	css, err := os.ReadFile(filepath.Join(wd, "static", "style.css"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	withSaved(t, SavedStuff{LogDirs: map[string]map[string]*rules.Rule{dir: {"all": {Op: "always"}}}})
+	code, body := get(t, "/stats/"+url.PathEscape(dir))
+	if code != http.StatusOK {
+		t.Fatalf("got %d: %s", code, body)
+	}
+	for _, m := range regexp.MustCompile(`<link rel="stylesheet" href="/static/([^"]+)"`).FindAllStringSubmatch(body, -1) {
+		if _, err := os.Stat(filepath.Join(wd, "static", m[1])); err != nil {
+			t.Errorf("page links missing stylesheet: %v", err)
+		}
+	}
+	if !strings.Contains(body, `class="bar-chart`) || !strings.Contains(string(css), ".bar-chart td") {
+		t.Error("dashboard bars are not styled by style.css")
+	}
+}
+
+func TestRuleStatsMetricsLabels(t *testing.T) {
+	dir := writeLogDir(t, map[string]string{"a.log": `{"level":"error"}` + "\n"})
+	withSaved(t, SavedStuff{LogDirs: map[string]map[string]*rules.Rule{dir: {"a\"b\\c\nd é": {Op: "always"}}}})
+	code, body := get(t, "/stats/"+url.PathEscape(dir)+"/metrics")
+	if code != http.StatusOK {
+		t.Fatalf("got %d: %s", code, body)
+	}
+	if want := `ruleset="a\"b\\c\nd é"} 1`; !strings.Contains(body, want) {
+		t.Errorf("no %s in\n%s", want, body)
+	}
+}
+
+func TestRuleStatsLimits(t *testing.T) {
+	dir := writeLogDir(t, map[string]string{"a.log": strings.Repeat(`{"message":"x"}`+"\n", 3000)})
+	withSaved(t, SavedStuff{
+		LogDirs:     map[string]map[string]*rules.Rule{dir: {"all": {Op: "always"}}},
+		DirSettings: map[string]*DirSettings{dir: {MaxScan: 2000}},
+	})
+	code, body := get(t, "/api/stats/"+url.PathEscape(dir))
+	if code != http.StatusOK || !strings.Contains(body, `"scanned":2000`) || !strings.Contains(body, `"cap_hit":true`) {
+		t.Errorf("got %d %s, want stats of the newest 2000 lines", code, body)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	got, err := getRuleStats(ctx, dir, scanOptions{}, map[string]*rules.Rule{"all": {Op: "always"}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.TimedOut || got.Scanned >= 3000 {
+		t.Errorf("got %+v, want stats stopped by timeout", got)
+	}
+	again, err := getRuleStats(context.Background(), dir, scanOptions{}, map[string]*rules.Rule{"all": {Op: "always"}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.TimedOut || again.Scanned != 3000 {
+		t.Errorf("got %+v, stopped stats were cached", again)
+	}
+}
@@ -0,0 +1,11 @@
+package rules
+
+// comparisons are the Op values ops comparing a line's value against Data
+// accept, each tells whether a cmp.Compare-style result satisfies it
+var comparisons = map[string]func(c int) bool{
+	"lt":  func(c int) bool { return c < 0 },
+	"lte": func(c int) bool { return c <= 0 },
+	"eq":  func(c int) bool { return c == 0 },
+	"gte": func(c int) bool { return c >= 0 },
+	"gt":  func(c int) bool { return c > 0 },
+}
@@ -0,0 +1,47 @@
+package rules
+
+import (
+	"cmp"
+	"errors"
+	"strings"
+)
+
+// opCountContains counts non-overlapping occurrences of Value and compares
+// them to Count, Data is {"Value": "retry", "Op": "gte", "Count": 3}. The
+// raw line is looked at unless Field is given, lines without a string
+// Field have no occurrences.
+func opCountContains(ops Ops, data, arg any) (bool, error) {
+	obj, ok := data.(map[string]any)
+	if !ok {
+		return false, errors.New("rule countcontains: data is not object")
+	}
+	check, ok := obj["Value"].(string)
+	if !ok || check == "" {
+		return false, errors.New("rule countcontains: Value is not a non-empty string")
+	}
+	cmpName, ok := obj["Op"].(string)
+	if !ok {
+		return false, errors.New("rule countcontains: Op is not string")
+	}
+	compare, ok := comparisons[cmpName]
+	if !ok {
+		return false, errors.New("rule countcontains: unknown Op")
+	}
+	count, ok := obj["Count"].(float64)
+	if !ok || count != float64(int(count)) {
+		return false, errors.New("rule countcontains: Count is not integer")
+	}
+	s := ""
+	if f, ok := obj["Field"]; ok {
+		field, ok := f.(string)
+		if !ok {
+			return false, errors.New("rule countcontains: Field is not string")
+		}
+		if v, ok := lineField(arg, field); ok {
+			s, _ = v.(string)
+		}
+	} else if s, ok = argString(arg); !ok {
+		return false, errors.New("rule countcontains: arg is not string")
+	}
+	return compare(cmp.Compare(strings.Count(s, check), int(count))), nil
+}
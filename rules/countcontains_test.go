@@ -0,0 +1,51 @@
+package rules
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCountContains(t *testing.T) {
+	data := func(op string, count int) string {
+		return fmt.Sprintf(`{"Value":"retry","Op":%q,"Count":%d}`, op, count)
+	}
+	// counts of "retry" in the line, 3 is the threshold
+	lines := map[int]string{
+		0: `{"msg":"done"}`,
+		2: `{"msg":"retry","err":"retry failed"}`,
+		3: `{"msg":"retry retry retry"}`,
+		4: `{"msg":"retryretry","next":"retry retry"}`,
+	}
+	var cases []opCase
+	for _, c := range []struct {
+		op   string
+		want map[int]bool
+	}{
+		{"lt", map[int]bool{0: true, 2: true, 3: false, 4: false}},
+		{"lte", map[int]bool{0: true, 2: true, 3: true, 4: false}},
+		{"eq", map[int]bool{0: false, 2: false, 3: true, 4: false}},
+		{"gte", map[int]bool{0: false, 2: false, 3: true, 4: true}},
+		{"gt", map[int]bool{0: false, 2: false, 3: false, 4: true}},
+	} {
+		for n, line := range lines {
+			cases = append(cases, opCase{fmt.Sprintf("%s 3 with %d", c.op, n), data(c.op, 3), line, c.want[n], false})
+		}
+	}
+	cases = append(cases, []opCase{
+		{"zero count eq", data("eq", 0), lines[0], true, false},
+		{"zero count gt", data("gt", 0), lines[2], true, false},
+		{"non-overlapping", `{"Value":"aa","Op":"eq","Count":2}`, `aaaaa`, true, false},
+		{"key names count", `{"Value":"msg","Op":"eq","Count":1}`, `{"msg":"x"}`, true, false},
+		{"field", `{"Value":"retry","Op":"eq","Count":2,"Field":"next"}`, lines[4], true, false},
+		{"field ignores rest of line", `{"Value":"retry","Op":"eq","Count":0,"Field":"other"}`, lines[3], true, false},
+		{"nested field", `{"Value":"x","Op":"gte","Count":2,"Field":"a.b"}`, `{"a":{"b":"xax"}}`, true, false},
+		{"field not string", `{"Value":"1","Op":"eq","Count":0,"Field":"n"}`, `{"n":111}`, true, false},
+		{"plain line", data("gte", 3), `retry retry retry failed`, true, false},
+		{"empty value", `{"Value":"","Op":"eq","Count":0}`, `x`, false, true},
+		{"unknown op", `{"Value":"x","Op":"ge","Count":1}`, `x`, false, true},
+		{"fractional count", `{"Value":"x","Op":"eq","Count":1.5}`, `x`, false, true},
+		{"count string", `{"Value":"x","Op":"eq","Count":"1"}`, `x`, false, true},
+		{"field not string in data", `{"Value":"x","Op":"eq","Count":1,"Field":1}`, `x`, false, true},
+	}...)
+	testOp(t, DefaultOps(), "countcontains", cases)
+}
@@ -0,0 +1,72 @@
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"strconv"
+)
+
+// statefulOps make ops that remember previous lines, ForScan gives every
+// scan fresh ones
+var statefulOps = map[string]func() OpFn{
+	"delta": DeltaOp,
+}
+
+// ForScan returns ops for a single pass over lines in order. Stateful ops
+// only work with such ops, with anything else they always fail.
+func (o Ops) ForScan() Ops {
+	ret := maps.Clone(o)
+	for k, mk := range statefulOps {
+		ret[k] = mk()
+	}
+	return ret
+}
+
+func opNeedsScan(name string) OpFn {
+	return func(ops Ops, data, arg any) (bool, error) {
+		return false, fmt.Errorf("rule %s: only works when scanning lines in order", name)
+	}
+}
+
+// DeltaOp makes the delta op, which matches when a numeric field differs from
+// its value on the previous line having it by more than Threshold. Data is
+// {"Field": "counter", "Threshold": 100}. The first line with the field,
+// lines without it and lines the op is not evaluated on (say, because an
+// earlier and rule failed) never match and don't move the previous value.
+// Every delta rule of a tree has its own previous value, rules with the same
+// Data share it.
+func DeltaOp() OpFn {
+	prev := map[string]float64{}
+	return func(ops Ops, data, arg any) (bool, error) {
+		obj, field, err := dataObject("delta", data)
+		if err != nil {
+			return false, err
+		}
+		key, err := json.Marshal(obj)
+		if err != nil {
+			return false, fmt.Errorf("rule delta: %w", err)
+		}
+		threshold, ok := obj["Threshold"].(float64)
+		if !ok {
+			return false, errors.New("rule delta: Threshold is not a number")
+		}
+		v, ok := lineField(arg, field)
+		if !ok {
+			return false, nil
+		}
+		s, ok := LooseString(v)
+		if !ok {
+			return false, nil
+		}
+		cur, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(cur) {
+			return false, nil
+		}
+		last, seen := prev[string(key)]
+		prev[string(key)] = cur
+		return seen && math.Abs(cur-last) > threshold, nil
+	}
+}
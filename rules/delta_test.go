@@ -0,0 +1,123 @@
+package rules
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestDelta runs delta over an ordered sequence of lines, want are indexes of
+// lines matching
+func TestDelta(t *testing.T) {
+	for _, c := range []struct {
+		name      string
+		threshold float64
+		lines     []string
+		want      []int
+	}{
+		{"counter reset", 100, []string{
+			`{"n":1000}`, `{"n":1010}`, `{"n":1050}`, `{"n":0}`, `{"n":5}`, `{"n":200}`,
+		}, []int{3, 5}},
+		{"threshold is exclusive", 10, []string{
+			`{"n":0}`, `{"n":10}`, `{"n":21}`, `{"n":11}`, `{"n":0.5}`,
+		}, []int{2, 4}},
+		{"lines without field are skipped", 5, []string{
+			`{"n":0}`, `{"other":100}`, `plain text`, `{"n":3}`, `{"n":"10"}`, `{"n":"x"}`, `{"n":null}`, `{"n":20}`,
+		}, []int{4, 7}},
+		{"first line never matches", 0, []string{
+			`{"n":1e9}`, `{"n":1e9}`, `{"n":-1e9}`,
+		}, []int{2}},
+		{"negative values", 50, []string{
+			`{"n":-100}`, `{"n":-20}`, `{"n":20}`, `{"n":-40}`,
+		}, []int{1, 3}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			r := Rule{Op: "delta", Data: map[string]any{"Field": "n", "Threshold": c.threshold}}
+			ops := DefaultOps().ForScan()
+			got := []int{}
+			for i, l := range c.lines {
+				m, err := r.Run(ops, NewLine(l))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if m {
+					got = append(got, i)
+				}
+			}
+			if !slices.Equal(got, c.want) {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestDeltaFieldsAreSeparate(t *testing.T) {
+	ops := DefaultOps().ForScan()
+	a := Rule{Op: "delta", Data: map[string]any{"Field": "a", "Threshold": 1.0}}
+	b := Rule{Op: "delta", Data: map[string]any{"Field": "b", "Threshold": 1.0}}
+	r := Or(a, b)
+	var got []bool
+	for _, l := range []string{`{"a":0,"b":100}`, `{"a":10}`, `{"b":100}`, `{"b":0}`} {
+		m, err := r.Run(ops, NewLine(l))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, m)
+	}
+	if want := []bool{false, true, false, true}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// Two delta rules on one field keep their own previous value, here or skips
+// the second one when the first matched
+func TestDeltaSameFieldTwice(t *testing.T) {
+	ops := DefaultOps().ForScan()
+	big := Rule{Op: "delta", Data: map[string]any{"Field": "n", "Threshold": 100.0}}
+	small := Rule{Op: "delta", Data: map[string]any{"Field": "n", "Threshold": 5.0}}
+	r := Or(big, small)
+	var got []bool
+	for _, l := range []string{`{"n":0}`, `{"n":50}`, `{"n":200}`, `{"n":203}`, `{"n":204}`} {
+		m, err := r.Run(ops, NewLine(l))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, m)
+	}
+	// small last saw 50, so 203 is way off for it
+	if want := []bool{false, true, true, true, false}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// Lines an earlier and rule rejected are not seen by delta and don't move
+// the previous value
+func TestDeltaAfterFailedAnd(t *testing.T) {
+	ops := DefaultOps().ForScan()
+	r := And(Rule{Op: "contains", Data: "svc-a"}, Rule{Op: "delta", Data: map[string]any{"Field": "n", "Threshold": 10.0}})
+	var got []bool
+	for _, l := range []string{`{"s":"svc-a","n":0}`, `{"s":"svc-b","n":100}`, `{"s":"svc-a","n":5}`, `{"s":"svc-a","n":50}`} {
+		m, err := r.Run(ops, NewLine(l))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, m)
+	}
+	if want := []bool{false, false, false, true}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDeltaErrors(t *testing.T) {
+	for _, data := range []any{
+		nil,
+		"n",
+		map[string]any{"Field": "n"},
+		map[string]any{"Field": "n", "Threshold": "10"},
+		map[string]any{"Threshold": 10.0},
+	} {
+		r := Rule{Op: "delta", Data: data}
+		if _, err := r.Run(DefaultOps().ForScan(), NewLine(`{"n":1}`)); err == nil {
+			t.Errorf("%v: no error", data)
+		}
+	}
+}
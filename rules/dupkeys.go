@@ -0,0 +1,54 @@
+package rules
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// hasDuplicateKeys walks the line token by token since decoding into a map
+// silently keeps only the last of repeated keys. This is several times
+// slower than plain decoding, which is why it's a separate opt-in op.
+// Lines that are not valid JSON have no duplicate keys.
+func hasDuplicateKeys(line string) bool {
+	type frame struct {
+		keys      map[string]struct{} // nil for arrays
+		expectKey bool
+	}
+	dec := json.NewDecoder(strings.NewReader(line))
+	stack := []frame{}
+	found := false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		top := len(stack) - 1
+		if top >= 0 && stack[top].keys != nil {
+			if stack[top].expectKey {
+				if k, ok := tok.(string); ok {
+					if _, dup := stack[top].keys[k]; dup {
+						// keep going, the rest of the line may be broken
+						found = true
+					}
+					stack[top].keys[k] = struct{}{}
+					stack[top].expectKey = false
+					continue
+				}
+			} else {
+				// this token is the value, next one is a key again
+				stack[top].expectKey = true
+			}
+		}
+		switch tok {
+		case json.Delim('{'):
+			stack = append(stack, frame{keys: map[string]struct{}{}, expectKey: true})
+		case json.Delim('['):
+			stack = append(stack, frame{})
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return found
+			}
+		}
+	}
+}
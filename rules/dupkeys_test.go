@@ -0,0 +1,34 @@
+package rules
+
+import "testing"
+
+func TestDupKeys(t *testing.T) {
+	testOp(t, DefaultOps(), "dupkeys", []opCase{
+		{"top level", `null`, `{"level":"info","level":"error"}`, true, false},
+		{"same value", `null`, `{"a":1,"a":1}`, true, false},
+		{"not adjacent", `null`, `{"a":1,"b":2,"c":3,"a":4}`, true, false},
+		{"nested", `null`, `{"req":{"id":1,"id":2}}`, true, false},
+		{"in array", `null`, `{"items":[{"a":1},{"b":1,"b":2}]}`, true, false},
+		{"escaped", `null`, `{"a":1,"\u0061":2}`, true, false},
+		{"after nested object", `null`, `{"a":{"x":1},"a":2}`, true, false},
+		{"after array", `null`, `{"a":[1,{"a":1}],"a":2}`, true, false},
+		{"distinct", `null`, `{"level":"info","msg":"level"}`, false, false},
+		{"same key in sibling objects", `null`, `{"a":{"id":1},"b":{"id":1}}`, false, false},
+		{"same key at different depths", `{"ignored":true}`, `{"id":1,"req":{"id":2,"req":{"id":3}}}`, false, false},
+		{"key equals value", `null`, `{"a":"a","b":"a"}`, false, false},
+		{"empty object", `null`, `{}`, false, false},
+		{"array", `null`, `["a","a"]`, false, false},
+		{"invalid json", `null`, `{"a":1,"a"`, false, false},
+		{"broken after duplicate", `null`, `{"a":1,"a":2,`, false, false},
+		{"plain text", `null`, `a=1 a=2`, false, false},
+	})
+}
+
+func BenchmarkDupKeys(b *testing.B) {
+	line := `{"time":"2024-03-01T10:00:00Z","level":"info","message":"request done","req":{"method":"GET","path":"/api/view","status":200},"took":0.0123}`
+	for range b.N {
+		if hasDuplicateKeys(line) {
+			b.Fatal("found duplicate keys")
+		}
+	}
+}
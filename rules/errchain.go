@@ -0,0 +1,67 @@
+package rules
+
+import (
+	"errors"
+	"strings"
+)
+
+// opErrChain matches when any message of an error chain contains a
+// substring, Data is {"Contains": "connection refused"} with optional
+// "Field" ("errors" by default) holding the chain array and "MsgField"
+// ("msg" by default, dotted paths are fine) of its elements. Elements that
+// are plain strings are their own message and elements with a chain of
+// their own under Field are searched too. Missing or non-array chains
+// never match.
+func opErrChain(ops Ops, data, arg any) (bool, error) {
+	obj, ok := data.(map[string]any)
+	if !ok {
+		return false, errors.New("rule errchain: data is not object")
+	}
+	check, ok := obj["Contains"].(string)
+	if !ok {
+		return false, errors.New("rule errchain: Contains is not string")
+	}
+	field, msgField := "errors", "msg"
+	if f, ok := obj["Field"]; ok {
+		field, ok = f.(string)
+		if !ok {
+			return false, errors.New("rule errchain: Field is not string")
+		}
+	}
+	if f, ok := obj["MsgField"]; ok {
+		msgField, ok = f.(string)
+		if !ok {
+			return false, errors.New("rule errchain: MsgField is not string")
+		}
+	}
+	v, ok := lineField(arg, field)
+	if !ok {
+		return false, nil
+	}
+	return chainContains(v, field, msgField, check), nil
+}
+
+func chainContains(chain any, field, msgField, check string) bool {
+	els, ok := chain.([]any)
+	if !ok {
+		return false
+	}
+	for _, el := range els {
+		switch el := el.(type) {
+		case string:
+			if strings.Contains(el, check) {
+				return true
+			}
+		case map[string]any:
+			if msg, ok := LookupField(el, msgField); ok {
+				if s, ok := msg.(string); ok && strings.Contains(s, check) {
+					return true
+				}
+			}
+			if nested, ok := LookupField(el, field); ok && chainContains(nested, field, msgField, check) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package rules
+
+import "testing"
+
+func TestErrChain(t *testing.T) {
+	refused := `{"Contains":"refused"}`
+	testOp(t, DefaultOps(), "errchain", []opCase{
+		{"single element", refused, `{"errors":[{"msg":"connection refused"}]}`, true, false},
+		{"single element no match", refused, `{"errors":[{"msg":"timeout"}]}`, false, false},
+		{"first of many", refused, `{"errors":[{"msg":"connection refused"},{"msg":"dial"},{"msg":"fetch"}]}`, true, false},
+		{"last of many", refused, `{"errors":[{"msg":"fetch"},{"msg":"dial"},{"msg":"connection refused"}]}`, true, false},
+		{"none of many", refused, `{"errors":[{"msg":"fetch"},{"msg":"dial"}]}`, false, false},
+		{"string elements", refused, `{"errors":["fetch","connection refused"]}`, true, false},
+		{"mixed elements", refused, `{"errors":[1,null,["connection refused"],{"msg":"dial"},"refused"]}`, true, false},
+		{"arrays in chain are not chains", refused, `{"errors":[["connection refused"]]}`, false, false},
+		{"nested", refused, `{"errors":[{"msg":"fetch","errors":[{"msg":"dial","errors":[{"msg":"connection refused"}]}]}]}`, true, false},
+		{"nested strings", refused, `{"errors":[{"msg":"fetch","errors":["connection refused"]}]}`, true, false},
+		{"nested no match", refused, `{"errors":[{"msg":"fetch","errors":[{"msg":"dial","errors":[]}]}]}`, false, false},
+		{"nested not array", refused, `{"errors":[{"msg":"fetch","errors":{"msg":"connection refused"}}]}`, false, false},
+		{"other keys ignored", refused, `{"errors":[{"msg":"fetch","detail":"connection refused"}]}`, false, false},
+		{"msg not string", refused, `{"errors":[{"msg":{"text":"connection refused"}}]}`, false, false},
+		{"empty chain", refused, `{"errors":[]}`, false, false},
+		{"chain not array", refused, `{"errors":"connection refused"}`, false, false},
+		{"missing", refused, `{"error":"connection refused"}`, false, false},
+		{"plain line", refused, `connection refused`, false, false},
+		{"case matters", refused, `{"errors":[{"msg":"Connection REFUSED"}]}`, false, false},
+		{"empty contains", `{"Contains":""}`, `{"errors":[{"msg":""}]}`, true, false},
+
+		{"custom fields", `{"Contains":"refused","Field":"cause.chain","MsgField":"error.text"}`,
+			`{"cause":{"chain":[{"error":{"text":"dial"}},{"error":{"text":"connection refused"}}]}}`, true, false},
+		{"custom field nests under its path", `{"Contains":"refused","Field":"cause.chain","MsgField":"m"}`,
+			`{"cause":{"chain":[{"m":"fetch","cause":{"chain":[{"m":"connection refused"}]}}]}}`, true, false},
+		{"custom field ignores default", `{"Contains":"refused","Field":"causes"}`, `{"errors":[{"msg":"connection refused"}]}`, false, false},
+
+		{"contains not string", `{"Contains":1}`, `{"errors":[]}`, false, true},
+		{"field not string", `{"Contains":"x","Field":1}`, `{"errors":[]}`, false, true},
+		{"msg field not string", `{"Contains":"x","MsgField":1}`, `{"errors":[]}`, false, true},
+		{"data not object", `"refused"`, `{"errors":[]}`, false, true},
+	})
+}
@@ -0,0 +1,41 @@
+package rules
+
+// Explanation is a rule tree annotated with what each node evaluated to
+type Explanation struct {
+	Op       string         `json:"op"`
+	Data     any            `json:"data,omitempty"`
+	Result   bool           `json:"result"`
+	Error    string         `json:"error,omitempty"`
+	Children []*Explanation `json:"children,omitempty"`
+}
+
+// Explain evaluates every node of the rule tree against arg. Children of
+// and/or are all evaluated even where the real run would short-circuit.
+func Explain(ops Ops, r Rule, arg any) *Explanation {
+	ret := &Explanation{Op: r.Op}
+	res, err := r.Run(ops, arg)
+	ret.Result = res
+	if err != nil {
+		ret.Error = err.Error()
+	}
+	switch r.Op {
+	case "not":
+		d, err := DataToRule(r.Data)
+		if err == nil {
+			ret.Children = append(ret.Children, Explain(ops, d, arg))
+		}
+	case "and", "or":
+		els, _ := r.Data.([]any)
+		for _, el := range els {
+			d, err := DataToRule(el)
+			if err != nil {
+				ret.Children = append(ret.Children, &Explanation{Error: err.Error()})
+				continue
+			}
+			ret.Children = append(ret.Children, Explain(ops, d, arg))
+		}
+	default:
+		ret.Data = r.Data
+	}
+	return ret
+}
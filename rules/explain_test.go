@@ -0,0 +1,54 @@
+package rules
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	r := And(
+		Rule{Op: "contains", Data: "error"},
+		Or(
+			Rule{Op: "eq", Data: map[string]any{"Field": "code", "Value": 500.0}},
+			Not(Rule{Op: "exists", Data: "user"}),
+		),
+	)
+	got := Explain(DefaultOps(), r, NewLine(`{"msg":"ok","code":500}`))
+	// and fails on its first child, the rest is still evaluated
+	want := &Explanation{Op: "and", Result: false, Children: []*Explanation{
+		{Op: "contains", Data: "error", Result: false},
+		{Op: "or", Result: true, Children: []*Explanation{
+			{Op: "eq", Data: map[string]any{"Field": "code", "Value": 500.0}, Result: true},
+			{Op: "not", Result: true, Children: []*Explanation{
+				{Op: "exists", Data: "user", Result: false},
+			}},
+		}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestExplainErrors(t *testing.T) {
+	r := Rule{Op: "or", Data: []any{
+		"not a rule",
+		map[string]any{"Op": "missing"},
+		map[string]any{"Op": "contains", "Data": "a"},
+	}}
+	got := Explain(DefaultOps(), r, NewLine("a"))
+	if got.Error == "" || got.Result {
+		t.Errorf("or with a broken child: got %+v", got)
+	}
+	if len(got.Children) != 3 {
+		t.Fatalf("got %d children, want 3", len(got.Children))
+	}
+	if got.Children[0].Op != "" || got.Children[0].Error == "" {
+		t.Errorf("child that is not a rule: got %+v", got.Children[0])
+	}
+	if got.Children[1].Op != "missing" || got.Children[1].Error == "" {
+		t.Errorf("unknown op: got %+v", got.Children[1])
+	}
+	if !got.Children[2].Result || got.Children[2].Error != "" {
+		t.Errorf("working child after broken ones: got %+v", got.Children[2])
+	}
+}
@@ -0,0 +1,44 @@
+package rules
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ValueHash is a short stable key of a value: first 6 bytes of SHA-256 of
+// its string form (JSON for objects, arrays and null) in hex, 12 digits.
+// Redaction shows hashed values the same way prefixed with "#". It is for
+// correlating lines without showing the value, not for security: it is
+// unsalted and short, small value sets like numeric IDs are easily reversed.
+func ValueHash(v any) string {
+	s, ok := LooseString(v)
+	if !ok {
+		b, _ := json.Marshal(v)
+		s = string(b)
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:6])
+}
+
+// opFieldHash matches when ValueHash of a field is Hash, Data is
+// {"Field": "user_id", "Hash": "#1a2b3c4d5e6f"} with "#" optional so hashes
+// can be copied from redacted views. Missing fields never match.
+func opFieldHash(ops Ops, data, arg any) (bool, error) {
+	obj, field, err := dataObject("fieldhash", data)
+	if err != nil {
+		return false, err
+	}
+	check, ok := obj["Hash"].(string)
+	if !ok {
+		return false, errors.New("rule fieldhash: Hash is not string")
+	}
+	check = strings.ToLower(strings.TrimPrefix(check, "#"))
+	v, ok := lineField(arg, field)
+	if !ok {
+		return false, nil
+	}
+	return ValueHash(v) == check, nil
+}
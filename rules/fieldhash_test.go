@@ -0,0 +1,57 @@
+package rules
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestValueHash pins hashes so they stay the same across releases, hashes
+// copied from redacted views keep working in saved rules
+func TestValueHash(t *testing.T) {
+	for _, c := range []struct {
+		json string
+		want string
+	}{
+		{`"bob"`, "81b637d8fcd2"},
+		{`""`, "e3b0c44298fc"},
+		{`42`, "73475cb40a56"},
+		{`42.0`, "73475cb40a56"},
+		{`"42"`, "73475cb40a56"},
+		{`4.2e1`, "73475cb40a56"},
+		{`1.5`, "9f29a130438b"},
+		{`true`, "b5bea41b6c62"},
+		{`null`, "74234e98afe7"},
+		{`{"a":1,"b":2}`, "43258cff783f"},
+		{`{"b":2, "a":1}`, "43258cff783f"},
+		{`[1,"x"]`, "b1aafb0d4ee7"},
+	} {
+		var v any
+		if err := json.Unmarshal([]byte(c.json), &v); err != nil {
+			t.Fatal(err)
+		}
+		for range 3 {
+			if got := ValueHash(v); got != c.want {
+				t.Errorf("%s: got %s, want %s", c.json, got, c.want)
+			}
+		}
+	}
+	if ValueHash("bob") == ValueHash("Bob") {
+		t.Error("hash ignores case")
+	}
+}
+
+func TestFieldHash(t *testing.T) {
+	testOp(t, DefaultOps(), "fieldhash", []opCase{
+		{"with prefix", `{"Field":"user","Hash":"#81b637d8fcd2"}`, `{"user":"bob"}`, true, false},
+		{"without prefix", `{"Field":"user","Hash":"81b637d8fcd2"}`, `{"user":"bob"}`, true, false},
+		{"upper case", `{"Field":"user","Hash":"#81B637D8FCD2"}`, `{"user":"bob"}`, true, false},
+		{"other value", `{"Field":"user","Hash":"#81b637d8fcd2"}`, `{"user":"amy"}`, false, false},
+		{"number and string", `{"Field":"id","Hash":"#73475cb40a56"}`, `{"id":42}`, true, false},
+		{"object key order", `{"Field":"u","Hash":"#43258cff783f"}`, `{"u":{"b":2,"a":1}}`, true, false},
+		{"null", `{"Field":"u","Hash":"#74234e98afe7"}`, `{"u":null}`, true, false},
+		{"nested", `{"Field":"req.user","Hash":"#81b637d8fcd2"}`, `{"req":{"user":"bob"}}`, true, false},
+		{"missing", `{"Field":"user","Hash":"#74234e98afe7"}`, `{"id":1}`, false, false},
+		{"full sha is not short hash", `{"Field":"user","Hash":"81b637d8fcd2c6da6359e6963113a1170de795e4b725b84d1e0b4cfd9ec58ce9"}`, `{"user":"bob"}`, false, false},
+		{"hash not string", `{"Field":"user","Hash":1}`, `{"user":"bob"}`, false, true},
+	})
+}
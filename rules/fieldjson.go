@@ -0,0 +1,35 @@
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// opFieldJSON matches Pattern against the field value encoded back to JSON,
+// so objects and arrays can be matched as text. Data is {"Field": "http",
+// "Pattern": "..."}, strings are matched with their quotes. Encoding is
+// compact with keys sorted, whatever the line had.
+func opFieldJSON(ops Ops, data, arg any) (bool, error) {
+	obj, field, err := dataObject("fieldjson", data)
+	if err != nil {
+		return false, err
+	}
+	pattern, ok := obj["Pattern"].(string)
+	if !ok {
+		return false, errors.New("rule fieldjson: Pattern is not string")
+	}
+	re, err := compileRegexSet([]any{pattern})
+	if err != nil {
+		return false, fmt.Errorf("rule fieldjson: %w", err)
+	}
+	v, ok := lineField(arg, field)
+	if !ok {
+		return false, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return false, fmt.Errorf("rule fieldjson: encoding field: %w", err)
+	}
+	return re.Match(b), nil
+}
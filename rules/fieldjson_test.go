@@ -0,0 +1,34 @@
+package rules
+
+import "testing"
+
+func TestFieldJSON(t *testing.T) {
+	testOp(t, DefaultOps(), "fieldjson", []opCase{
+		{"object", `{"Field":"http","Pattern":"\"status\":5\\d\\d"}`, `{"http":{"method":"GET","status":502}}`, true, false},
+		{"object no match", `{"Field":"http","Pattern":"\"status\":5\\d\\d"}`, `{"http":{"method":"GET","status":200}}`, false, false},
+		{"keys sorted", `{"Field":"http","Pattern":"^\\{\"a\":1,\"b\":2\\}$"}`, `{"http":{"b":2,"a":1}}`, true, false},
+		{"compact", `{"Field":"http","Pattern":"\": "}`, `{"http": {"a": 1}}`, false, false},
+		{"nested object", `{"Field":"req","Pattern":"\"headers\":\\{[^}]*\"x-id\""}`, `{"req":{"headers":{"x-id":"1"}}}`, true, false},
+		{"empty object", `{"Field":"http","Pattern":"^\\{\\}$"}`, `{"http":{}}`, true, false},
+		{"array", `{"Field":"tags","Pattern":"^\\[\"db\",\"http\"\\]$"}`, `{"tags":["db","http"]}`, true, false},
+		{"array element", `{"Field":"codes","Pattern":"\\b404\\b"}`, `{"codes":[200,404]}`, true, false},
+		{"array of objects", `{"Field":"errs","Pattern":"\\{\"code\":\"E1\"\\}"}`, `{"errs":[{"code":"E0"},{"code":"E1"}]}`, true, false},
+		{"empty array", `{"Field":"tags","Pattern":"^\\[\\]$"}`, `{"tags":[]}`, true, false},
+		{"array no match", `{"Field":"tags","Pattern":"cache"}`, `{"tags":["db"]}`, false, false},
+		{"string has quotes", `{"Field":"msg","Pattern":"^\"ok\"$"}`, `{"msg":"ok"}`, true, false},
+		{"html escaped", `{"Field":"msg","Pattern":"\\\\u003c"}`, `{"msg":"<b>"}`, true, false},
+		{"number", `{"Field":"n","Pattern":"^1.5$"}`, `{"n":1.5}`, true, false},
+		{"null", `{"Field":"n","Pattern":"^null$"}`, `{"n":null}`, true, false},
+		{"missing", `{"Field":"http","Pattern":".*"}`, `{"other":{}}`, false, false},
+		{"not json", `{"Field":"http","Pattern":".*"}`, `http={}`, false, false},
+		{"bad pattern", `{"Field":"http","Pattern":"("}`, `{"http":{}}`, false, true},
+		{"pattern not string", `{"Field":"http","Pattern":1}`, `{"http":{}}`, false, true},
+		{"no field", `{"Pattern":"x"}`, `{"http":{}}`, false, true},
+	})
+	t.Run("encoding error", func(t *testing.T) {
+		r := Rule{Op: "fieldjson", Data: map[string]any{"Field": "f", "Pattern": "x"}}
+		if _, err := r.MatchMap(map[string]any{"f": func() {}}); err == nil {
+			t.Error("no error for a value that can't be encoded")
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// dataObject checks that rule data is an object with a string Field
+func dataObject(op string, data any) (obj map[string]any, field string, err error) {
+	obj, ok := data.(map[string]any)
+	if !ok {
+		return nil, "", fmt.Errorf("rule %s: data is not object", op)
+	}
+	field, ok = obj["Field"].(string)
+	if !ok {
+		return nil, "", fmt.Errorf("rule %s: Field is not string", op)
+	}
+	return obj, field, nil
+}
+
+// dataFields is Data of ops taking a list of field paths
+func dataFields(op string, data any) ([]string, error) {
+	els, ok := data.([]any)
+	if !ok {
+		return nil, fmt.Errorf("rule %s: data is not array", op)
+	}
+	ret := make([]string, len(els))
+	for i, el := range els {
+		ret[i], ok = el.(string)
+		if !ok {
+			return nil, fmt.Errorf("rule %s: data %d is not string", op, i)
+		}
+	}
+	return ret, nil
+}
+
+// argString is the raw line of arg, parsed messages are encoded back
+func argString(arg any) (string, bool) {
+	switch a := arg.(type) {
+	case string:
+		return a, true
+	case *Line:
+		return a.Raw, true
+	case map[string]any:
+		b, err := json.Marshal(a)
+		if err != nil {
+			return "", false
+		}
+		return string(b), true
+	}
+	return "", false
+}
+
+// lineField extracts value at dotted path from a JSON line or parsed message,
+// lines that are not JSON objects have no fields
+func lineField(arg any, path string) (any, bool) {
+	switch a := arg.(type) {
+	case map[string]any:
+		return LookupField(a, path)
+	case *Line:
+		msg, ok := a.Message()
+		if !ok {
+			return nil, false
+		}
+		return LookupField(msg, path)
+	case string:
+		msg := map[string]any{}
+		if json.Unmarshal([]byte(a), &msg) != nil {
+			return nil, false
+		}
+		return LookupField(msg, path)
+	}
+	return nil, false
+}
+
+// LookupField finds value at dotted path like "http.request.method"
+func LookupField(msg map[string]any, path string) (any, bool) {
+	var cur any = msg
+	for _, p := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		cur, ok = m[p]
+		if !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// LooseString brings scalar JSON values to a common string form so that
+// "404" and 404 compare equal. Objects, arrays and null have no such form.
+func LooseString(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
+
+// isScalar reports whether decoded JSON value is safe to compare with ==
+func isScalar(v any) bool {
+	switch v.(type) {
+	case string, float64, bool, nil:
+		return true
+	}
+	return false
+}
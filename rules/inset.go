@@ -0,0 +1,112 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSetFileDir is where the inset op of default ops reads set files
+// from, relative to the working directory
+const DefaultSetFileDir = "sets"
+
+const (
+	// setFileRecheck is how often set files are checked for changes, a stat
+	// on every line would cost more than the lookup
+	setFileRecheck = time.Second
+	maxSetFileSize = 16 << 20
+)
+
+type setFile struct {
+	values  map[string]bool
+	size    int64
+	modTime time.Time
+	checked time.Time
+}
+
+var (
+	setFileCache   = map[string]*setFile{}
+	setFileCacheMu sync.Mutex
+)
+
+// loadSetFile returns values of a newline-delimited regular file of up to
+// maxSetFileSize bytes, surrounding whitespace and empty lines are ignored.
+// Sets are cached and read again once size or modification time of the file
+// changes.
+func loadSetFile(fp string) (map[string]bool, error) {
+	setFileCacheMu.Lock()
+	defer setFileCacheMu.Unlock()
+	c, ok := setFileCache[fp]
+	if ok && time.Since(c.checked) < setFileRecheck {
+		return c.values, nil
+	}
+	st, err := os.Stat(fp)
+	if err != nil {
+		return nil, err
+	}
+	if !st.Mode().IsRegular() {
+		return nil, errors.New("not a regular file")
+	}
+	if ok && st.Size() == c.size && st.ModTime().Equal(c.modTime) {
+		c.checked = time.Now()
+		return c.values, nil
+	}
+	f, err := os.Open(fp)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// the file can grow after the stat
+	b, err := io.ReadAll(io.LimitReader(f, maxSetFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxSetFileSize {
+		return nil, fmt.Errorf("larger than %d bytes", maxSetFileSize)
+	}
+	values := map[string]bool{}
+	for _, l := range strings.Split(string(b), "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			values[l] = true
+		}
+	}
+	setFileCache[fp] = &setFile{values: values, size: st.Size(), modTime: st.ModTime(), checked: time.Now()}
+	return values, nil
+}
+
+// InSetOp makes the inset op reading set files from dir. It matches when a
+// field is one of the values listed in a file, Data is {"Field":
+// "request_id", "File": "badids.txt"} with File relative to dir. Rules can
+// come with requests, so File can't be absolute or lead out of dir, though
+// symlinks in dir are followed. Values are compared in their string forms
+// like eq does, missing fields and non-scalar values never match.
+func InSetOp(dir string) OpFn {
+	return func(ops Ops, data, arg any) (bool, error) {
+		obj, field, err := dataObject("inset", data)
+		if err != nil {
+			return false, err
+		}
+		name, ok := obj["File"].(string)
+		if !ok || name == "" {
+			return false, errors.New("rule inset: File is not a non-empty string")
+		}
+		if !filepath.IsLocal(name) {
+			return false, errors.New("rule inset: File is not inside the set file directory")
+		}
+		set, err := loadSetFile(filepath.Join(dir, name))
+		if err != nil {
+			return false, fmt.Errorf("rule inset: loading set file: %w", err)
+		}
+		v, ok := lineField(arg, field)
+		if !ok {
+			return false, nil
+		}
+		s, ok := LooseString(v)
+		return ok && set[s], nil
+	}
+}
@@ -0,0 +1,91 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// inSetOps are default ops with inset reading from a temporary directory
+// holding ids.txt
+func inSetOps(t *testing.T) (Ops, string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ids.txt"), []byte("a1\n  b2 \n\n404\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ops := DefaultOps()
+	ops["inset"] = InSetOp(dir)
+	return ops, dir
+}
+
+func TestInSet(t *testing.T) {
+	ops, _ := inSetOps(t)
+	testOp(t, ops, "inset", []opCase{
+		{"member", `{"Field":"id","File":"ids.txt"}`, `{"id":"a1"}`, true, false},
+		{"trimmed member", `{"Field":"id","File":"ids.txt"}`, `{"id":"b2"}`, true, false},
+		{"number member", `{"Field":"id","File":"ids.txt"}`, `{"id":404}`, true, false},
+		{"not a member", `{"Field":"id","File":"ids.txt"}`, `{"id":"c3"}`, false, false},
+		{"empty is not a member", `{"Field":"id","File":"ids.txt"}`, `{"id":""}`, false, false},
+		{"missing field", `{"Field":"id","File":"ids.txt"}`, `{"other":"a1"}`, false, false},
+		{"object field", `{"Field":"id","File":"ids.txt"}`, `{"id":{"a1":true}}`, false, false},
+		{"nested field", `{"Field":"req.id","File":"ids.txt"}`, `{"req":{"id":"a1"}}`, true, false},
+		{"missing file", `{"Field":"id","File":"nope.txt"}`, `{"id":"a1"}`, false, true},
+		{"no file", `{"Field":"id"}`, `{"id":"a1"}`, false, true},
+	})
+}
+
+// TestInSetFileConfined checks rules can't read or probe files outside the
+// set file directory
+func TestInSetFileConfined(t *testing.T) {
+	ops, dir := inSetOps(t)
+	outside := filepath.Join(filepath.Dir(dir), "outside.txt")
+	if err := os.WriteFile(outside, []byte("a1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(outside) })
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{outside, "../outside.txt", "sub/../../outside.txt", "/dev/zero", "/etc/passwd", "../nope.txt", "sub", "."} {
+		t.Run(name, func(t *testing.T) {
+			r := Rule{Op: "inset", Data: map[string]any{"Field": "id", "File": name}}
+			got, err := r.Run(ops, NewLine(`{"id":"a1"}`))
+			if err == nil || got {
+				t.Fatalf("got %v, %v", got, err)
+			}
+		})
+	}
+	// files outside exist or don't, the error is the same
+	errFor := func(name string) string {
+		_, err := Rule{Op: "inset", Data: map[string]any{"Field": "id", "File": name}}.Run(ops, NewLine(`{"id":"a1"}`))
+		return err.Error()
+	}
+	if a, b := errFor("../outside.txt"), errFor("../nope.txt"); a != b {
+		t.Errorf("errors tell files apart: %q and %q", a, b)
+	}
+}
+
+func TestInSetFileSize(t *testing.T) {
+	ops, dir := inSetOps(t)
+	big := filepath.Join(dir, "big.txt")
+	if err := os.WriteFile(big, []byte("a1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(big, maxSetFileSize+1); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Rule{Op: "inset", Data: map[string]any{"Field": "id", "File": "big.txt"}}.Run(ops, NewLine(`{"id":"a1"}`))
+	if err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("got %v", err)
+	}
+	if err := os.Truncate(big, maxSetFileSize); err != nil {
+		t.Fatal(err)
+	}
+	// the cache remembers nothing of the failed load
+	got, err := Rule{Op: "inset", Data: map[string]any{"Field": "id", "File": "big.txt"}}.Run(ops, NewLine(`{"id":"a1"}`))
+	if err != nil || !got {
+		t.Errorf("file at the limit: got %v, %v", got, err)
+	}
+}
@@ -0,0 +1,33 @@
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// IsJSONObject tells whether the line decodes into a message, which is what
+// the viewer does with it. Anything else, including valid JSON that is not
+// an object, is shown as plain text. null decodes into an empty message.
+func IsJSONObject(line string) bool {
+	b := bytes.TrimLeft([]byte(line), " \t\r\n")
+	if len(b) == 0 || (b[0] != '{' && b[0] != 'n') {
+		return false
+	}
+	return json.Valid(b)
+}
+
+// opInvalidJSON matches lines that fail to parse into a message, Data is
+// ignored. Already parsed messages never match.
+func opInvalidJSON(ops Ops, data, arg any) (bool, error) {
+	switch a := arg.(type) {
+	case string:
+		return !IsJSONObject(a), nil
+	case *Line:
+		// null parses into no message yet isn't shown as plain text
+		if a.parsed && a.msg != nil {
+			return false, nil
+		}
+		return !IsJSONObject(a.Raw), nil
+	}
+	return false, nil
+}
@@ -0,0 +1,53 @@
+package rules
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestInvalidJSON(t *testing.T) {
+	testOp(t, DefaultOps(), "invalidjson", []opCase{
+		{"object", `null`, `{"a":1}`, false, false},
+		{"nested object", `null`, `{"a":{"b":[1,{"c":null}]}}`, false, false},
+		{"empty object", `null`, `{}`, false, false},
+		{"leading space", `null`, " \t{\"a\":1}", false, false},
+		{"trailing space", `null`, "{\"a\":1} \r", false, false},
+		{"null", `null`, `null`, false, false},
+		{"truncated", `null`, `{"a":`, true, false},
+		{"trailing garbage", `null`, `{"a":1} x`, true, false},
+		{"two objects", `null`, `{"a":1}{"b":2}`, true, false},
+		{"single quotes", `null`, `{'a':1}`, true, false},
+		{"trailing comma", `null`, `{"a":1,}`, true, false},
+		{"array", `null`, `[{"a":1}]`, true, false},
+		{"string", `null`, `"text"`, true, false},
+		{"number", `null`, `42`, true, false},
+		{"true", `null`, `true`, true, false},
+		{"starts with n", `null`, `nope`, true, false},
+		{"plain text", `null`, `connection reset by peer`, true, false},
+		{"empty", `null`, ``, true, false},
+		{"invalid utf-8 in string", `null`, "{\"a\":\"\xff\"}", false, false},
+		{"data ignored", `{"anything":1}`, `{"a":`, true, false},
+	})
+}
+
+// TestInvalidJSONAgreesWithParse checks the op agrees with itself on lines an
+// earlier op already parsed, and with how the viewer decodes lines
+func TestInvalidJSONAgreesWithParse(t *testing.T) {
+	parseFirst := And(Rule{Op: "exists", Data: "x"}, Rule{Op: "never"})
+	for _, line := range []string{`{"a":1}`, `null`, `[1]`, `"s"`, `{"a":`, `plain`, ``} {
+		l := NewLine(line)
+		_, _ = parseFirst.Run(DefaultOps(), l)
+		if !l.parsed {
+			t.Fatal("line was not parsed")
+		}
+		got, _ := Rule{Op: "invalidjson"}.Run(DefaultOps(), l)
+		want, _ := Rule{Op: "invalidjson"}.Run(DefaultOps(), NewLine(line))
+		if got != want {
+			t.Errorf("%q: got %v parsed and %v unparsed", line, got, want)
+		}
+		var msg map[string]any
+		if decodeErr := json.Unmarshal([]byte(line), &msg); (decodeErr != nil) != want {
+			t.Errorf("%q: invalidjson %v, decoding error %v", line, want, decodeErr)
+		}
+	}
+}
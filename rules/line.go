@@ -0,0 +1,27 @@
+package rules
+
+import "encoding/json"
+
+// Line is a raw line that is parsed at most once no matter how many ops look
+// at its fields. String ops see Raw as is, field ops the parsed message.
+type Line struct {
+	Raw    string
+	parsed bool
+	msg    map[string]any
+}
+
+func NewLine(raw string) *Line {
+	return &Line{Raw: raw}
+}
+
+// Message is the line parsed as JSON object, false for anything else
+func (l *Line) Message() (map[string]any, bool) {
+	if !l.parsed {
+		l.parsed = true
+		msg := map[string]any{}
+		if json.Unmarshal([]byte(l.Raw), &msg) == nil {
+			l.msg = msg
+		}
+	}
+	return l.msg, l.msg != nil
+}
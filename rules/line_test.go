@@ -0,0 +1,43 @@
+package rules
+
+import (
+	"strconv"
+	"testing"
+)
+
+// BenchmarkLineParsing compares a raw string, which every field op parses
+// on its own, to a Line parsed once for the whole rule
+func BenchmarkLineParsing(b *testing.B) {
+	const line = `{"time":"2024-03-01T10:00:00Z","level":"error","message":"request failed","service":"api",` +
+		`"req":{"method":"GET","path":"/api/view/app","status":502,"took_ms":1234},"user":{"id":42,"name":"bob"}}`
+	fieldOps := []Rule{
+		{Op: "eq", Data: map[string]any{"Field": "level", "Value": "error"}},
+		{Op: "fieldcontains", Data: map[string]any{"Field": "req.path", "Value": "/api"}},
+		{Op: "exists", Data: "user.id"},
+		{Op: "eq", Data: map[string]any{"Field": "req.status", "Value": 502.0}},
+		{Op: "fieldcontains", Data: map[string]any{"Field": "service", "Value": "ap"}},
+		{Op: "existsall", Data: []any{"time", "message"}},
+	}
+	ops := DefaultOps()
+	for _, n := range []int{1, 3, 6} {
+		// a string op first, it never needs the parsed message
+		r := And(append([]Rule{{Op: "contains", Data: "failed"}}, fieldOps[:n]...)...)
+		for _, c := range []struct {
+			name string
+			arg  func() any
+		}{
+			{"string", func() any { return line }},
+			{"Line", func() any { return NewLine(line) }},
+		} {
+			b.Run(c.name+"/"+strconv.Itoa(n), func(b *testing.B) {
+				b.ReportAllocs()
+				for range b.N {
+					ok, err := r.Run(ops, c.arg())
+					if err != nil || !ok {
+						b.Fatal(ok, err)
+					}
+				}
+			})
+		}
+	}
+}
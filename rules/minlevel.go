@@ -0,0 +1,85 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DefaultLevelOrder is severity from lowest to highest the minlevel op of
+// default ops uses
+var DefaultLevelOrder = []string{"trace", "debug", "info", "warn", "error", "fatal", "panic"}
+
+// levelAliases are spellings of levels found in the wild
+var levelAliases = map[string]string{
+	"warning": "warn",
+	"err":     "error",
+}
+
+// MinLevelOp makes the minlevel op for the order of levels, lowest first.
+// Data is the lowest level to match like "warn", or {"Level": "warn"} with
+// optional "Field" ("level" by default) and "Order" replacing the order for
+// that rule. Levels compare case-insensitively, lines with missing or
+// unknown levels never match.
+func MinLevelOp(order []string) OpFn {
+	return func(ops Ops, data, arg any) (bool, error) {
+		field, order := "level", order
+		var level string
+		switch d := data.(type) {
+		case string:
+			level = d
+		case map[string]any:
+			var ok bool
+			level, ok = d["Level"].(string)
+			if !ok {
+				return false, errors.New("rule minlevel: Level is not string")
+			}
+			if f, ok := d["Field"]; ok {
+				field, ok = f.(string)
+				if !ok {
+					return false, errors.New("rule minlevel: Field is not string")
+				}
+			}
+			if o, ok := d["Order"]; ok {
+				els, ok := o.([]any)
+				if !ok {
+					return false, errors.New("rule minlevel: Order is not array")
+				}
+				order = make([]string, len(els))
+				for i, el := range els {
+					order[i], ok = el.(string)
+					if !ok {
+						return false, fmt.Errorf("rule minlevel: Order %d is not string", i)
+					}
+				}
+			}
+		default:
+			return false, errors.New("rule minlevel: data is not string or object")
+		}
+		lowest := levelIndex(order, level)
+		if lowest < 0 {
+			return false, errors.New("rule minlevel: Level is not in the order")
+		}
+		v, ok := lineField(arg, field)
+		if !ok {
+			return false, nil
+		}
+		s, ok := v.(string)
+		if !ok {
+			return false, nil
+		}
+		return levelIndex(order, s) >= lowest, nil
+	}
+}
+
+func levelIndex(order []string, level string) int {
+	find := func(level string) int {
+		return slices.IndexFunc(order, func(l string) bool { return strings.EqualFold(l, level) })
+	}
+	i := find(level)
+	if a, ok := levelAliases[strings.ToLower(level)]; ok && i < 0 {
+		i = find(a)
+	}
+	return i
+}
@@ -0,0 +1,55 @@
+package rules
+
+import "testing"
+
+func TestMinLevel(t *testing.T) {
+	testOp(t, DefaultOps(), "minlevel", []opCase{
+		{"below", `"warn"`, `{"level":"info"}`, false, false},
+		{"at", `"warn"`, `{"level":"warn"}`, true, false},
+		{"above", `"warn"`, `{"level":"panic"}`, true, false},
+		{"lowest", `"trace"`, `{"level":"trace"}`, true, false},
+		{"case", `"WARN"`, `{"level":"Error"}`, true, false},
+		{"alias in line", `"error"`, `{"level":"err"}`, true, false},
+		{"alias in data", `"warning"`, `{"level":"warn"}`, true, false},
+		{"unknown level", `"trace"`, `{"level":"verbose"}`, false, false},
+		{"number level", `"trace"`, `{"level":30}`, false, false},
+		{"missing", `"trace"`, `{"msg":"x"}`, false, false},
+		{"plain line", `"trace"`, `error: x`, false, false},
+		{"object", `{"Level":"error"}`, `{"level":"fatal"}`, true, false},
+		{"field", `{"Level":"error","Field":"severity"}`, `{"severity":"error","level":"info"}`, true, false},
+		{"nested field", `{"Level":"error","Field":"log.level"}`, `{"log":{"level":"fatal"}}`, true, false},
+		{"field ignores level", `{"Level":"error","Field":"severity"}`, `{"level":"error"}`, false, false},
+		{"unknown data level", `"loud"`, `{"level":"error"}`, false, true},
+		{"data level not string", `{"Level":3}`, `{"level":"error"}`, false, true},
+		{"data number", `3`, `{"level":"error"}`, false, true},
+	})
+}
+
+// TestMinLevelOverride covers orders given per rule and ops made with their
+// own order
+func TestMinLevelOverride(t *testing.T) {
+	order := `["debug","notice","alert","emerg"]`
+	testOp(t, DefaultOps(), "minlevel", []opCase{
+		{"own level above", `{"Level":"notice","Order":` + order + `}`, `{"level":"alert"}`, true, false},
+		{"own level below", `{"Level":"alert","Order":` + order + `}`, `{"level":"notice"}`, false, false},
+		{"default level not in order", `{"Level":"debug","Order":` + order + `}`, `{"level":"error"}`, false, false},
+		{"data level not in order", `{"Level":"warn","Order":` + order + `}`, `{"level":"emerg"}`, false, true},
+		{"reversed", `{"Level":"info","Order":["error","info","debug"]}`, `{"level":"debug"}`, true, false},
+		{"reversed below", `{"Level":"info","Order":["error","info","debug"]}`, `{"level":"error"}`, false, false},
+		{"aliases still apply", `{"Level":"warn","Order":["info","warn","error"]}`, `{"level":"warning"}`, true, false},
+		{"order not array", `{"Level":"warn","Order":"info,warn"}`, `{"level":"warn"}`, false, true},
+		{"order element not string", `{"Level":"warn","Order":["warn",1]}`, `{"level":"warn"}`, false, true},
+	})
+
+	ops := DefaultOps()
+	ops["minlevel"] = MinLevelOp([]string{"low", "mid", "high"})
+	testOp(t, ops, "minlevel", []opCase{
+		{"ops order", `"mid"`, `{"level":"high"}`, true, false},
+		{"ops order below", `"mid"`, `{"level":"low"}`, false, false},
+		{"default levels unknown", `"low"`, `{"level":"error"}`, false, false},
+		{"rule order wins", `{"Level":"error","Order":["info","error"]}`, `{"level":"error"}`, true, false},
+	})
+	if got, _ := (Rule{Op: "minlevel", Data: "warn"}).Match(`{"level":"error"}`); !got {
+		t.Error("ops order leaked into default ops")
+	}
+}
@@ -0,0 +1,72 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalize brings s to the NFC (composed) or NFD (decomposed) form
+func Normalize(s, form string) string {
+	if form == "NFD" {
+		return norm.NFD.String(s)
+	}
+	return norm.NFC.String(s)
+}
+
+// normalizeData normalizes every string in rule data, nested rules included
+func normalizeData(data any, form string) any {
+	switch d := data.(type) {
+	case string:
+		return Normalize(d, form)
+	case []any:
+		ret := make([]any, len(d))
+		for i, el := range d {
+			ret[i] = normalizeData(el, form)
+		}
+		return ret
+	case map[string]any:
+		ret := make(map[string]any, len(d))
+		for k, v := range d {
+			ret[Normalize(k, form)] = normalizeData(v, form)
+		}
+		return ret
+	case Rule:
+		return Rule{Op: d.Op, Data: normalizeData(d.Data, form)}
+	case *Rule:
+		return &Rule{Op: d.Op, Data: normalizeData(d.Data, form)}
+	}
+	return data
+}
+
+// opNormalize runs a rule with the line and every string of the rule's data
+// brought to the same Unicode normalization form, so text composed
+// differently by different sources still matches. Data is {"Form": "NFC",
+// "Rule": {...}}, Form is "NFC" (the default) or "NFD". Wrapping a rule
+// set's rule in it turns normalization on for that rule set only.
+func opNormalize(ops Ops, data, arg any) (bool, error) {
+	obj, ok := data.(map[string]any)
+	if !ok {
+		return false, errors.New("rule normalize: data is not object")
+	}
+	form := "NFC"
+	if f, ok := obj["Form"]; ok {
+		form, ok = f.(string)
+		if !ok || (form != "NFC" && form != "NFD") {
+			return false, errors.New("rule normalize: Form is not NFC or NFD")
+		}
+	}
+	r, err := DataToRule(obj["Rule"])
+	if err != nil {
+		return false, fmt.Errorf("rule normalize: Rule is not rule: %w", err)
+	}
+	r.Data = normalizeData(r.Data, form)
+	switch a := arg.(type) {
+	case *Line:
+		arg = NewLine(Normalize(a.Raw, form))
+	default:
+		arg = normalizeData(a, form)
+	}
+	return r.Run(ops, arg)
+}
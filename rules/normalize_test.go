@@ -0,0 +1,70 @@
+package rules
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		in       string
+		nfc, nfd string
+	}{
+		{"ascii", "plain text", "plain text", "plain text"},
+		{"empty", "", "", ""},
+		{"composed", "caf\u00e9", "caf\u00e9", "cafe\u0301"},
+		{"decomposed", "cafe\u0301", "caf\u00e9", "cafe\u0301"},
+		{"mixed", "\u00e9e\u0301", "\u00e9\u00e9", "e\u0301e\u0301"},
+		{"two marks composed", "\u1ec7", "\u1ec7", "e\u0323\u0302"},
+		{"two marks decomposed", "e\u0323\u0302", "\u1ec7", "e\u0323\u0302"},
+		{"marks out of order", "e\u0302\u0323", "\u1ec7", "e\u0323\u0302"},
+		{"latin extended additional", "\u1e9b", "\u1e9b", "\u017f\u0307"},
+		{"greek", "\u03b1\u0301", "\u03ac", "\u03b1\u0301"},
+		{"cyrillic", "\u0438\u0306", "\u0439", "\u0438\u0306"},
+		{"hangul composed", "\ud55c", "\ud55c", "\u1112\u1161\u11ab"},
+		{"hangul decomposed", "\u1112\u1161\u11ab", "\ud55c", "\u1112\u1161\u11ab"},
+		{"lone mark", "\u0301x", "\u0301x", "\u0301x"},
+		{"no composition", "q\u0301", "q\u0301", "q\u0301"},
+		{"singleton", "\u212b", "\u00c5", "A\u030a"},
+		{"invalid utf8", "caf\xff", "caf\xff", "caf\xff"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Normalize(tc.in, "NFC"); got != tc.nfc {
+				t.Errorf("NFC(%+q) = %+q, want %+q", tc.in, got, tc.nfc)
+			}
+			if got := Normalize(tc.in, "NFD"); got != tc.nfd {
+				t.Errorf("NFD(%+q) = %+q, want %+q", tc.in, got, tc.nfd)
+			}
+		})
+	}
+}
+
+func TestNormalizeOp(t *testing.T) {
+	wrap := func(form, contains string) string {
+		f := ""
+		if form != "" {
+			f = `"Form":"` + form + `",`
+		}
+		return `{` + f + `"Rule":{"Op":"contains","Data":"` + contains + `"}}`
+	}
+	const (
+		composed   = "caf\u00e9"
+		decomposed = "cafe\u0301"
+	)
+	testOp(t, DefaultOps(), "normalize", []opCase{
+		{"composed data, composed line", wrap("", composed), composed + " au lait", true, false},
+		{"composed data, decomposed line", wrap("", composed), decomposed + " au lait", true, false},
+		{"decomposed data, composed line", wrap("", decomposed), composed + " au lait", true, false},
+		{"decomposed data, decomposed line", wrap("", decomposed), decomposed + " au lait", true, false},
+		{"nfd composed data, decomposed line", wrap("NFD", composed), decomposed + " au lait", true, false},
+		{"nfd decomposed data, composed line", wrap("NFD", decomposed), composed + " au lait", true, false},
+		{"two marks", wrap("", "Vie\u0302\u0323t"), "Vi\u1ec7t Nam", true, false},
+		{"hangul", wrap("NFD", "\ud55c"), "\u1112\u1161\u11ab\uae00", true, false},
+		{"base letter differs", wrap("", composed), "cafe au lait", false, false},
+		{"nfd bare base matches", wrap("NFD", "cafe"), composed, true, false},
+		{"nfc bare base does not match", wrap("NFC", "cafe"), decomposed, false, false},
+		{"json line", `{"Rule":{"Op":"fieldcontains","Data":{"Field":"name","Value":"` + composed + `"}}}`,
+			`{"name":"` + decomposed + `"}`, true, false},
+		{"bad form", wrap("NFKC", "x"), "x", false, true},
+		{"no rule", `{"Form":"NFC"}`, "x", false, true},
+		{"data not object", `"NFC"`, "x", false, true},
+	})
+}
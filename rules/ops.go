@@ -0,0 +1,309 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	defaultOps = Ops{
+		// always and never ignore Data, they let tooling express "no filter"
+		// as a rule instead of nil
+		"always": func(ops Ops, data, arg any) (bool, error) {
+			return true, nil
+		},
+		"never": func(ops Ops, data, arg any) (bool, error) {
+			return false, nil
+		},
+		"not": func(ops Ops, data, arg any) (bool, error) {
+			d, err := DataToRule(data)
+			if err != nil {
+				return false, fmt.Errorf("rule not: data is not rule: %w", err)
+			}
+			ret, err := d.Run(ops, arg)
+			return !ret, err
+		},
+		"or": func(ops Ops, data, arg any) (bool, error) {
+			els, ok := data.([]any)
+			if !ok {
+				return false, errors.New("rule or: data is not array")
+			}
+			for i, el := range els {
+				d, err := DataToRule(el)
+				if err != nil {
+					return false, fmt.Errorf("rule or: data %d is not rule: %w", i, err)
+				}
+				ret, err := d.Run(ops, arg)
+				if err != nil {
+					return ret, fmt.Errorf("running or rule %d: %w", i, err)
+				}
+				if ret {
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+		"and": func(ops Ops, data, arg any) (bool, error) {
+			els, ok := data.([]any)
+			if !ok {
+				return false, errors.New("rule and: data is not array")
+			}
+			for i, el := range els {
+				d, err := DataToRule(el)
+				if err != nil {
+					return false, fmt.Errorf("rule and: data %d is not rule: %w", i, err)
+				}
+				ret, err := d.Run(ops, arg)
+				if err != nil {
+					return ret, fmt.Errorf("running and rule %d: %w", i, err)
+				}
+				if !ret {
+					return false, nil
+				}
+			}
+			return true, nil
+		},
+		"contains": func(ops Ops, data, arg any) (bool, error) {
+			d, ok := argString(arg)
+			if !ok {
+				return false, errors.New("rule contains: arg is not string")
+			}
+			check, ok := data.(string)
+			if !ok {
+				return false, errors.New("rule contains: data is not string")
+			}
+			return strings.Contains(d, check), nil
+		},
+		// ncontains looks at the raw line same as contains, so non-JSON lines
+		// are matched as plain text. Non-string args can't contain anything
+		// and therefore always match.
+		"ncontains": func(ops Ops, data, arg any) (bool, error) {
+			check, ok := data.(string)
+			if !ok {
+				return false, errors.New("rule ncontains: data is not string")
+			}
+			d, ok := argString(arg)
+			if !ok {
+				return true, nil
+			}
+			return !strings.Contains(d, check), nil
+		},
+		// bytescontains matches raw bytes of the line, Data is written with
+		// Go string escapes so that invalid UTF-8 like "\\xff" can be
+		// expressed in JSON config
+		"bytescontains": func(ops Ops, data, arg any) (bool, error) {
+			check, ok := data.(string)
+			if !ok {
+				return false, errors.New("rule bytescontains: data is not string")
+			}
+			pattern, err := strconv.Unquote(`"` + check + `"`)
+			if err != nil {
+				return false, fmt.Errorf("rule bytescontains: unquoting data: %w", err)
+			}
+			d, ok := argString(arg)
+			if !ok {
+				return false, errors.New("rule bytescontains: arg is not string")
+			}
+			return strings.Contains(d, pattern), nil
+		},
+		"fieldcontains": func(ops Ops, data, arg any) (bool, error) {
+			obj, field, err := dataObject("fieldcontains", data)
+			if err != nil {
+				return false, err
+			}
+			check, ok := obj["Value"].(string)
+			if !ok {
+				return false, errors.New("rule fieldcontains: Value is not string")
+			}
+			v, ok := lineField(arg, field)
+			if !ok {
+				return false, nil
+			}
+			s, ok := v.(string)
+			if !ok {
+				return false, nil
+			}
+			return strings.Contains(s, check), nil
+		},
+		// eq is deliberately lenient about types: field and Value are compared
+		// in their string forms, so "404" matches 404 and "true" matches true.
+		// Missing fields and non-scalar values never match.
+		"eq": func(ops Ops, data, arg any) (bool, error) {
+			obj, field, err := dataObject("eq", data)
+			if err != nil {
+				return false, err
+			}
+			check, ok := LooseString(obj["Value"])
+			if !ok {
+				return false, errors.New("rule eq: Value is not scalar")
+			}
+			v, ok := lineField(arg, field)
+			if !ok {
+				return false, nil
+			}
+			s, ok := LooseString(v)
+			return ok && s == check, nil
+		},
+		// contains-value matches when array field has an element equal to
+		// Value, unlike eq types have to be the same
+		"contains-value": func(ops Ops, data, arg any) (bool, error) {
+			obj, field, err := dataObject("contains-value", data)
+			if err != nil {
+				return false, err
+			}
+			check := obj["Value"]
+			if !isScalar(check) {
+				return false, errors.New("rule contains-value: Value is not scalar")
+			}
+			v, ok := lineField(arg, field)
+			if !ok {
+				return false, nil
+			}
+			arr, ok := v.([]any)
+			if !ok {
+				return false, nil
+			}
+			for _, el := range arr {
+				if isScalar(el) && el == check {
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+		// notinset matches when a field exists and is none of Values, Data is
+		// {"Field": "status", "Values": [200, 204, 304]}. Values compare in
+		// their string forms like eq does. Missing fields never match, fields
+		// that are null, objects or arrays are not members and match.
+		"notinset": func(ops Ops, data, arg any) (bool, error) {
+			obj, field, err := dataObject("notinset", data)
+			if err != nil {
+				return false, err
+			}
+			values, ok := obj["Values"].([]any)
+			if !ok {
+				return false, errors.New("rule notinset: Values is not array")
+			}
+			v, ok := lineField(arg, field)
+			if !ok {
+				return false, nil
+			}
+			s, scalar := LooseString(v)
+			for i, el := range values {
+				check, ok := LooseString(el)
+				if !ok {
+					return false, fmt.Errorf("rule notinset: Values %d is not scalar", i)
+				}
+				if scalar && s == check {
+					return false, nil
+				}
+			}
+			return true, nil
+		},
+		// dupkeys matches JSON lines with an object repeating a key, which
+		// parsing hides by keeping the last one. Data is ignored. Parsed
+		// messages have lost duplicates already and never match.
+		"dupkeys": func(ops Ops, data, arg any) (bool, error) {
+			switch a := arg.(type) {
+			case string:
+				return hasDuplicateKeys(a), nil
+			case *Line:
+				return hasDuplicateKeys(a.Raw), nil
+			}
+			return false, nil
+		},
+		// exists and isnull take a field path as Data. A field set to null
+		// exists, isnull only matches such fields and not absent ones.
+		"exists": func(ops Ops, data, arg any) (bool, error) {
+			field, ok := data.(string)
+			if !ok {
+				return false, errors.New("rule exists: data is not string")
+			}
+			_, ok = lineField(arg, field)
+			return ok, nil
+		},
+		"isnull": func(ops Ops, data, arg any) (bool, error) {
+			field, ok := data.(string)
+			if !ok {
+				return false, errors.New("rule isnull: data is not string")
+			}
+			v, ok := lineField(arg, field)
+			return ok && v == nil, nil
+		},
+		// existsany and existsall take a list of field paths and match when
+		// any or all of them exist
+		"existsany": func(ops Ops, data, arg any) (bool, error) {
+			fields, err := dataFields("existsany", data)
+			if err != nil {
+				return false, err
+			}
+			for _, f := range fields {
+				if _, ok := lineField(arg, f); ok {
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+		"existsall": func(ops Ops, data, arg any) (bool, error) {
+			fields, err := dataFields("existsall", data)
+			if err != nil {
+				return false, err
+			}
+			for _, f := range fields {
+				if _, ok := lineField(arg, f); !ok {
+					return false, nil
+				}
+			}
+			return len(fields) > 0, nil
+		},
+		"regexset":      opRegexSet,
+		"countcontains": opCountContains,
+		"fieldjson":     opFieldJSON,
+		"semver":        opSemver,
+		"invalidjson":   opInvalidJSON,
+		"inset":         InSetOp(DefaultSetFileDir),
+		"timeofday":     timeOfDayOp(nil),
+		"fieldhash":     opFieldHash,
+		"errchain":      opErrChain,
+		"normalize":     opNormalize,
+		// repeat matches on how many times a deduplicated line repeated,
+		// Data is {"Min": 10} with optional "Max" and "Field" (_repeat by
+		// default). Lines that weren't deduplicated count as a single one.
+		"repeat": func(ops Ops, data, arg any) (bool, error) {
+			obj, ok := data.(map[string]any)
+			if !ok {
+				return false, errors.New("rule repeat: data is not object")
+			}
+			field := "_repeat"
+			if f, ok := obj["Field"]; ok {
+				field, ok = f.(string)
+				if !ok {
+					return false, errors.New("rule repeat: Field is not string")
+				}
+			}
+			lo, hasMin := obj["Min"].(float64)
+			hi, hasMax := obj["Max"].(float64)
+			if !hasMin && !hasMax {
+				return false, errors.New("rule repeat: neither Min nor Max is a number")
+			}
+			n := 1.0
+			if v, ok := lineField(arg, field); ok {
+				s, ok := LooseString(v)
+				if !ok {
+					return false, nil
+				}
+				var err error
+				n, err = strconv.ParseFloat(s, 64)
+				if err != nil {
+					return false, nil
+				}
+			}
+			return (!hasMin || n >= lo) && (!hasMax || n <= hi), nil
+		},
+		"recent":   RecentOp(time.Now),
+		"delta":    opNeedsScan("delta"),
+		"minlevel": MinLevelOp(DefaultLevelOrder),
+	}
+)
@@ -0,0 +1,61 @@
+package rules
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"testing"
+)
+
+// FuzzOps runs every default op with arbitrary JSON as Data against
+// arbitrary lines, ops have to return errors on malformed data and never
+// panic
+func FuzzOps(f *testing.F) {
+	for _, seed := range []struct{ data, line string }{
+		{`null`, `{"a":1}`},
+		{`"a"`, `{"a":null}`},
+		{`["a","b"]`, `{"a":{"b":[1,2]}}`},
+		{`{"Op":"contains","Data":"a"}`, `a`},
+		{`[{"Op":"contains","Data":"a"},{"Op":"not","Data":{"Op":"always"}}]`, `ab`},
+		{`{"Field":"msg","Value":"err"}`, `{"msg":"error"}`},
+		{`{"Field":"code","Value":404}`, `{"code":"404"}`},
+		{`{"Field":"code","Values":[200,"204",null]}`, `{"code":204}`},
+		{`{"Value":"retry","Op":"gte","Count":2}`, `retry retry`},
+		{`{"Field":"req","Pattern":"\"method\""}`, `{"req":{"method":"GET"}}`},
+		{`{"Field":"v","Op":"lt","Value":"1.4.0-rc.1+build"}`, `{"v":"1.3.9"}`},
+		{`{"Field":"t","From":"22:00","To":"06:00","TZ":"Europe/Berlin"}`, `{"t":"2024-03-01T23:00:00Z"}`},
+		{`{"Field":"user","Hash":"#abc"}`, `{"user":"bob"}`},
+		{`{"Field":"id","File":"../../../../etc/passwd"}`, `{"id":"root"}`},
+		{`{"Contains":"refused","Field":"errors"}`, `{"errors":[{"msg":"refused","cause":{"msg":"x"}}]}`},
+		{`{"Form":"NFD","Rule":{"Op":"contains","Data":"é"}}`, "café"},
+		{`{"Min":3,"Max":"x"}`, `{"_repeat":5}`},
+		{`{"Field":"t","Within":"1h"}`, `{"t":1700000000}`},
+		{`{"Level":"warn","Field":["x"]}`, `{"level":"ERROR"}`},
+		{`{"Field":"n","Threshold":-1}`, `{"n":1e308}`},
+		{`{"Op":"not"}`, "\xff\xfe"},
+		{`[[[]]]`, `{"a":`},
+		{`{"":{"":[null,true,1.5,"x"]}}`, `[1]`},
+	} {
+		f.Add(seed.data, seed.line)
+	}
+	ops := DefaultOps()
+	// inset can't read anything outside the set file directory, which is
+	// empty
+	ops["inset"] = InSetOp(f.TempDir())
+	names := slices.Sorted(maps.Keys(ops))
+	f.Fuzz(func(t *testing.T, data, line string) {
+		var d any
+		if json.Unmarshal([]byte(data), &d) != nil {
+			return
+		}
+		var msg map[string]any
+		_ = json.Unmarshal([]byte(line), &msg)
+		scanOps := ops.ForScan()
+		for _, op := range names {
+			r := Rule{Op: op, Data: d}
+			for _, arg := range []any{NewLine(line), line, msg, nil} {
+				_, _ = r.Run(scanOps, arg)
+			}
+		}
+	})
+}
@@ -0,0 +1,311 @@
+package rules
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// opCase runs Op with Data given as JSON, the way rules come from configs
+type opCase struct {
+	name string
+	data string
+	line string
+	want bool
+	err  bool
+}
+
+// testOp runs every case against both a lazily parsed Line and the raw
+// string, ops have to agree on them
+func testOp(t *testing.T, ops Ops, op string, cases []opCase) {
+	t.Helper()
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var data any
+			if err := json.Unmarshal([]byte(c.data), &data); err != nil {
+				t.Fatalf("bad test data %s: %v", c.data, err)
+			}
+			r := Rule{Op: op, Data: data}
+			for _, arg := range []any{NewLine(c.line), c.line} {
+				got, err := r.Run(ops, arg)
+				if (err != nil) != c.err {
+					t.Fatalf("%T: got error %v, want error %v", arg, err, c.err)
+				}
+				if got != c.want {
+					t.Errorf("%T: got %v, want %v", arg, got, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFieldContains(t *testing.T) {
+	testOp(t, DefaultOps(), "fieldcontains", []opCase{
+		{"substring", `{"Field":"msg","Value":"time"}`, `{"msg":"request timeout"}`, true, false},
+		{"no substring", `{"Field":"msg","Value":"time"}`, `{"msg":"ok"}`, false, false},
+		{"key name is not value", `{"Field":"msg","Value":"timeout"}`, `{"msg":"ok","timeout":"timeout"}`, false, false},
+		{"nested", `{"Field":"http.path","Value":"/api"}`, `{"http":{"path":"/api/view"}}`, true, false},
+		{"missing", `{"Field":"msg","Value":"x"}`, `{"other":"x"}`, false, false},
+		{"number", `{"Field":"code","Value":"40"}`, `{"code":404}`, false, false},
+		{"null", `{"Field":"msg","Value":""}`, `{"msg":null}`, false, false},
+		{"object", `{"Field":"msg","Value":"a"}`, `{"msg":{"a":"a"}}`, false, false},
+		{"not json", `{"Field":"msg","Value":"msg"}`, `msg=timeout`, false, false},
+		{"empty value", `{"Field":"msg","Value":""}`, `{"msg":""}`, true, false},
+		{"value not string", `{"Field":"msg","Value":1}`, `{"msg":"1"}`, false, true},
+		{"field not string", `{"Field":1,"Value":"x"}`, `{"msg":"x"}`, false, true},
+	})
+}
+
+func TestEq(t *testing.T) {
+	testOp(t, DefaultOps(), "eq", []opCase{
+		{"string", `{"Field":"user","Value":"bob"}`, `{"user":"bob"}`, true, false},
+		{"string differs", `{"Field":"user","Value":"bob"}`, `{"user":"bobby"}`, false, false},
+		{"string value number field", `{"Field":"status","Value":"404"}`, `{"status":404}`, true, false},
+		{"number value string field", `{"Field":"status","Value":404}`, `{"status":"404"}`, true, false},
+		{"number", `{"Field":"status","Value":404}`, `{"status":404}`, true, false},
+		{"float forms", `{"Field":"ratio","Value":0.5}`, `{"ratio":5e-1}`, true, false},
+		{"float string is not reformatted", `{"Field":"ratio","Value":"0.50"}`, `{"ratio":0.5}`, false, false},
+		{"bool string", `{"Field":"ok","Value":"true"}`, `{"ok":true}`, true, false},
+		{"bool", `{"Field":"ok","Value":false}`, `{"ok":"false"}`, true, false},
+		{"nested", `{"Field":"http.status","Value":"500"}`, `{"http":{"status":500}}`, true, false},
+		{"missing", `{"Field":"status","Value":""}`, `{"code":""}`, false, false},
+		{"null field", `{"Field":"status","Value":"null"}`, `{"status":null}`, false, false},
+		{"array field", `{"Field":"status","Value":"404"}`, `{"status":[404]}`, false, false},
+		{"object field", `{"Field":"status","Value":"404"}`, `{"status":{"code":404}}`, false, false},
+		{"array value", `{"Field":"status","Value":[404]}`, `{"status":404}`, false, true},
+		{"null value", `{"Field":"status","Value":null}`, `{"status":null}`, false, true},
+	})
+}
+
+// Rule data can have values substituted from the environment, errors about
+// bad data must not show it
+func TestOpErrorsDoNotQuoteData(t *testing.T) {
+	const secret = "s3cr3t"
+	for _, data := range []string{
+		`{"Op":"or","Data":"s3cr3t"}`,
+		`{"Op":"and","Data":{"x":"s3cr3t"}}`,
+		`{"Op":"not","Data":"s3cr3t"}`,
+		`{"Op":"eq","Data":{"Field":"a","Value":["s3cr3t"]}}`,
+		`{"Op":"fieldcontains","Data":{"Field":["s3cr3t"],"Value":"x"}}`,
+		`{"Op":"regexset","Data":["(s3cr3t"]}`,
+		`{"Op":"regexset","Data":{"Field":"a","Patterns":["s3cr3t[" ]}}`,
+		`{"Op":"fieldjson","Data":{"Field":"a","Pattern":"s3cr3t)"}}`,
+		`{"Op":"timeofday","Data":{"Field":"t","From":"s3cr3t","To":"10:00"}}`,
+		`{"Op":"timeofday","Data":{"Field":"t","From":"09:00","To":"10:00","TZ":"s3cr3t"}}`,
+		`{"Op":"recent","Data":{"Field":"t","Within":"s3cr3t"}}`,
+		`{"Op":"semver","Data":{"Field":"v","Op":"s3cr3t","Value":"1.0.0"}}`,
+		`{"Op":"semver","Data":{"Field":"v","Op":"lt","Value":"s3cr3t"}}`,
+		`{"Op":"countcontains","Data":{"Field":"a","Value":"x","Op":"s3cr3t","Count":1}}`,
+		`{"Op":"countcontains","Data":{"Field":"a","Value":"x","Op":"eq","Count":"s3cr3t"}}`,
+		`{"Op":"minlevel","Data":"s3cr3t"}`,
+		`{"Op":"minlevel","Data":{"Level":"info","Field":["s3cr3t"]}}`,
+		`{"Op":"normalize","Data":{"Form":"s3cr3t","Rule":{"Op":"always"}}}`,
+		`{"Op":"repeat","Data":{"Field":["s3cr3t"]}}`,
+		`{"Op":"errchain","Data":{"Field":["s3cr3t"]}}`,
+	} {
+		t.Run(data, func(t *testing.T) {
+			// Unmarshal would validate, ops have to hold up on their own
+			var raw struct {
+				Op   string
+				Data any
+			}
+			if err := json.Unmarshal([]byte(data), &raw); err != nil {
+				t.Fatal(err)
+			}
+			r := Rule{Op: raw.Op, Data: raw.Data}
+			_, err := r.Run(DefaultOps(), NewLine(`{"a":"b","t":"2024-01-01T10:00:00Z","v":"1.0.0"}`))
+			if err == nil {
+				t.Fatal("no error")
+			}
+			if strings.Contains(err.Error(), secret) {
+				t.Errorf("error %q has data", err)
+			}
+		})
+	}
+}
+
+func TestContainsValue(t *testing.T) {
+	testOp(t, DefaultOps(), "contains-value", []opCase{
+		{"string", `{"Field":"tags","Value":"db"}`, `{"tags":["http","db"]}`, true, false},
+		{"string absent", `{"Field":"tags","Value":"db"}`, `{"tags":["http","dbx"]}`, false, false},
+		{"number", `{"Field":"codes","Value":500}`, `{"codes":[404,500]}`, true, false},
+		{"number does not match string", `{"Field":"codes","Value":500}`, `{"codes":["500"]}`, false, false},
+		{"string does not match number", `{"Field":"codes","Value":"500"}`, `{"codes":[500]}`, false, false},
+		{"bool", `{"Field":"flags","Value":true}`, `{"flags":[false,true]}`, true, false},
+		{"null", `{"Field":"flags","Value":null}`, `{"flags":[1,null]}`, true, false},
+		{"mixed types", `{"Field":"mixed","Value":"x"}`, `{"mixed":[1,null,{"x":"x"},["x"],true,"x"]}`, true, false},
+		{"nested values are not elements", `{"Field":"mixed","Value":"x"}`, `{"mixed":[{"x":"x"},["x"]]}`, false, false},
+		{"empty array", `{"Field":"tags","Value":"db"}`, `{"tags":[]}`, false, false},
+		{"not array", `{"Field":"tags","Value":"db"}`, `{"tags":"db"}`, false, false},
+		{"missing", `{"Field":"tags","Value":"db"}`, `{}`, false, false},
+		{"nested path", `{"Field":"req.tags","Value":"db"}`, `{"req":{"tags":["db"]}}`, true, false},
+		{"object value", `{"Field":"tags","Value":{"a":1}}`, `{"tags":[{"a":1}]}`, false, true},
+		{"array value", `{"Field":"tags","Value":["db"]}`, `{"tags":[["db"]]}`, false, true},
+	})
+}
+
+// There is no dedup yet, lines here carry _repeat the way deduplicated
+// lines would
+func TestRepeat(t *testing.T) {
+	testOp(t, DefaultOps(), "repeat", []opCase{
+		{"above min", `{"Min":10}`, `{"msg":"flood","_repeat":25}`, true, false},
+		{"at min", `{"Min":10}`, `{"_repeat":10}`, true, false},
+		{"below min", `{"Min":10}`, `{"_repeat":9}`, false, false},
+		{"missing is one", `{"Min":1}`, `{"msg":"once"}`, true, false},
+		{"missing below min", `{"Min":2}`, `{"msg":"once"}`, false, false},
+		{"max", `{"Max":5}`, `{"_repeat":5}`, true, false},
+		{"above max", `{"Max":5}`, `{"_repeat":6}`, false, false},
+		{"range", `{"Min":2,"Max":5}`, `{"_repeat":3}`, true, false},
+		{"string count", `{"Min":2}`, `{"_repeat":"3"}`, true, false},
+		{"not a number", `{"Min":1}`, `{"_repeat":"many"}`, false, false},
+		{"object count", `{"Min":1}`, `{"_repeat":{"n":3}}`, false, false},
+		{"custom field", `{"Min":3,"Field":"dup.count"}`, `{"dup":{"count":4},"_repeat":1}`, true, false},
+		{"custom field ignores _repeat", `{"Min":3,"Field":"count"}`, `{"_repeat":4}`, false, false},
+		{"plain line", `{"Min":1}`, `connection reset`, true, false},
+		{"no bounds", `{"Field":"n"}`, `{"n":1}`, false, true},
+		{"min not number", `{"Min":"10"}`, `{"_repeat":20}`, false, true},
+		{"data not object", `10`, `{"_repeat":20}`, false, true},
+	})
+}
+
+func TestIsNull(t *testing.T) {
+	testOp(t, DefaultOps(), "isnull", []opCase{
+		{"null", `"user"`, `{"user":null}`, true, false},
+		{"absent", `"user"`, `{"id":1}`, false, false},
+		{"empty string", `"user"`, `{"user":""}`, false, false},
+		{"null string", `"user"`, `{"user":"null"}`, false, false},
+		{"zero", `"user"`, `{"user":0}`, false, false},
+		{"false", `"user"`, `{"user":false}`, false, false},
+		{"empty object", `"user"`, `{"user":{}}`, false, false},
+		{"nested null", `"req.user"`, `{"req":{"user":null}}`, true, false},
+		{"null parent", `"req.user"`, `{"req":null}`, false, false},
+		{"not json", `"user"`, `user=null`, false, false},
+		{"data not string", `["user"]`, `{"user":null}`, false, true},
+	})
+}
+
+func TestExists(t *testing.T) {
+	testOp(t, DefaultOps(), "exists", []opCase{
+		{"null", `"user"`, `{"user":null}`, true, false},
+		{"absent", `"user"`, `{"id":1}`, false, false},
+		{"empty string", `"user"`, `{"user":""}`, true, false},
+		{"nested", `"req.user"`, `{"req":{"user":"bob"}}`, true, false},
+		{"null parent", `"req.user"`, `{"req":null}`, false, false},
+		{"not json", `"user"`, `user=bob`, false, false},
+		{"data not string", `1`, `{"user":null}`, false, true},
+	})
+}
+
+// TestPresence covers every presence and value case with exists and isnull
+// together
+func TestPresence(t *testing.T) {
+	exists := Rule{Op: "exists", Data: "user"}
+	isnull := Rule{Op: "isnull", Data: "user"}
+	for _, c := range []struct {
+		line string
+		rule Rule
+	}{
+		{`{"id":1}`, Not(exists)},
+		{`{"user":null}`, isnull},
+		{`{"user":""}`, And(exists, Not(isnull), Rule{Op: "eq", Data: map[string]any{"Field": "user", "Value": ""}})},
+		{`{"user":"bob"}`, And(exists, Not(isnull), Not(Rule{Op: "eq", Data: map[string]any{"Field": "user", "Value": ""}}))},
+	} {
+		for _, other := range []string{`{"id":1}`, `{"user":null}`, `{"user":""}`, `{"user":"bob"}`} {
+			got, err := c.rule.Run(DefaultOps(), NewLine(other))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != (other == c.line) {
+				t.Errorf("rule for %s on %s: got %v", c.line, other, got)
+			}
+		}
+	}
+}
+
+func TestExistsAnyAll(t *testing.T) {
+	const line = `{"a":1,"req":{"retry":{"count":0},"auth":null},"list":[{"x":1}]}`
+	for _, c := range []struct {
+		name     string
+		data     string
+		line     string
+		any, all bool
+	}{
+		{"all present", `["a","req.retry.count"]`, line, true, true},
+		{"mixed", `["a","b"]`, line, true, false},
+		{"mixed nested", `["req.retry.count","req.retry.reason"]`, line, true, false},
+		{"none present", `["b","req.timeout"]`, line, false, false},
+		{"null counts", `["req.auth"]`, line, true, true},
+		{"object counts", `["req.retry"]`, line, true, true},
+		{"through null", `["req.auth.user"]`, line, false, false},
+		{"through number", `["a.b"]`, line, false, false},
+		{"into array", `["list.0.x"]`, line, false, false},
+		{"single", `["req"]`, line, true, true},
+		{"duplicates", `["a","a"]`, line, true, true},
+		{"empty list", `[]`, line, false, false},
+		{"empty path", `[""]`, line, false, false},
+		{"dotted key is a path", `["x.y"]`, `{"x.y":1}`, false, false},
+		{"not json", `["a"]`, `a=1`, false, false},
+		{"array line", `["a"]`, `[{"a":1}]`, false, false},
+	} {
+		testOp(t, DefaultOps(), "existsany", []opCase{{c.name, c.data, c.line, c.any, false}})
+		testOp(t, DefaultOps(), "existsall", []opCase{{c.name, c.data, c.line, c.all, false}})
+	}
+	for _, op := range []string{"existsany", "existsall"} {
+		testOp(t, DefaultOps(), op, []opCase{
+			{"data string", `"a"`, line, false, true},
+			{"element not string", `["a",1]`, line, false, true},
+			{"data null", `null`, line, false, true},
+		})
+	}
+}
+
+func TestNotInSet(t *testing.T) {
+	const normal = `{"Field":"status","Values":[200,204,301,302,304]}`
+	testOp(t, DefaultOps(), "notinset", []opCase{
+		{"member", normal, `{"status":200}`, false, false},
+		{"last member", normal, `{"status":304}`, false, false},
+		{"not a member", normal, `{"status":500}`, true, false},
+		{"missing", normal, `{"code":500}`, false, false},
+		{"missing nested", `{"Field":"http.status","Values":[200]}`, `{"http":{}}`, false, false},
+		{"nested not a member", `{"Field":"http.status","Values":[200]}`, `{"http":{"status":404}}`, true, false},
+		{"string member", normal, `{"status":"204"}`, false, false},
+		{"string values", `{"Field":"status","Values":["200"]}`, `{"status":200}`, false, false},
+		{"float form member", normal, `{"status":2e2}`, false, false},
+		{"null is not a member", normal, `{"status":null}`, true, false},
+		{"object is not a member", normal, `{"status":{"code":200}}`, true, false},
+		{"array is not a member", normal, `{"status":[200]}`, true, false},
+		{"empty values", `{"Field":"status","Values":[]}`, `{"status":200}`, true, false},
+		{"empty values missing", `{"Field":"status","Values":[]}`, `{}`, false, false},
+		{"bool member", `{"Field":"ok","Values":[true]}`, `{"ok":true}`, false, false},
+		{"plain line", normal, `status=500`, false, false},
+		{"values not array", `{"Field":"status","Values":200}`, `{"status":500}`, false, true},
+		{"value not scalar", `{"Field":"status","Values":[200,[500]]}`, `{"status":500}`, false, true},
+	})
+}
+
+// TestNotInSetComplementsMembers checks notinset is the negation of being a
+// member only for lines having the field
+func TestNotInSetComplementsMembers(t *testing.T) {
+	notIn := Rule{Op: "notinset", Data: map[string]any{"Field": "status", "Values": []any{200.0, 304.0}}}
+	member := Or(
+		Rule{Op: "eq", Data: map[string]any{"Field": "status", "Value": 200.0}},
+		Rule{Op: "eq", Data: map[string]any{"Field": "status", "Value": 304.0}},
+	)
+	for _, line := range []string{`{"status":200}`, `{"status":304}`, `{"status":500}`, `{"status":"200"}`, `{}`, `x`} {
+		n, err := notIn.Match(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := member.Match(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		has, err := Rule{Op: "exists", Data: "status"}.Match(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != (has && !m) {
+			t.Errorf("%s: notinset %v, member %v, has field %v", line, n, m, has)
+		}
+	}
+}
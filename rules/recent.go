@@ -0,0 +1,41 @@
+package rules
+
+import (
+	"errors"
+	"time"
+)
+
+// RecentOp makes the recent op with the given clock, default ops use
+// time.Now. Data is {"Field": "time", "Within": "5m"} with optional
+// "Layout" for string times (RFC3339 or the directory's layouts by default,
+// see WithTimeLayouts), numeric times are Unix seconds or milliseconds as
+// ParseTime reads them. Lines with missing or unparseable time never match.
+func RecentOp(now func() time.Time) OpFn {
+	return recentOp(now, nil)
+}
+
+func recentOp(now func() time.Time, layouts []string) OpFn {
+	return func(ops Ops, data, arg any) (bool, error) {
+		obj, field, err := dataObject("recent", data)
+		if err != nil {
+			return false, err
+		}
+		within, ok := obj["Within"].(string)
+		if !ok {
+			return false, errors.New("rule recent: Within is not string")
+		}
+		d, err := time.ParseDuration(within)
+		if err != nil {
+			return false, errors.New("rule recent: Within is not a duration")
+		}
+		v, ok := lineField(arg, field)
+		if !ok {
+			return false, nil
+		}
+		t, ok := ParseTime(v, timeLayouts(obj, layouts))
+		if !ok {
+			return false, nil
+		}
+		return !t.Before(now().Add(-d)), nil
+	}
+}
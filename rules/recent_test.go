@@ -0,0 +1,52 @@
+package rules
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecent(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	ops := DefaultOps()
+	ops["recent"] = RecentOp(func() time.Time { return now })
+	testOp(t, ops, "recent", []opCase{
+		{"inside", `{"Field":"time","Within":"5m"}`, `{"time":"2024-03-01T11:57:00Z"}`, true, false},
+		{"at the edge", `{"Field":"time","Within":"5m"}`, `{"time":"2024-03-01T11:55:00Z"}`, true, false},
+		{"just outside", `{"Field":"time","Within":"5m"}`, `{"time":"2024-03-01T11:54:59.999Z"}`, false, false},
+		{"other offset", `{"Field":"time","Within":"5m"}`, `{"time":"2024-03-01T13:58:00+02:00"}`, true, false},
+		{"clock skew ahead", `{"Field":"time","Within":"5m"}`, `{"time":"2024-03-01T12:00:02Z"}`, true, false},
+		{"hours", `{"Field":"time","Within":"24h"}`, `{"time":"2024-02-29T12:00:01Z"}`, true, false},
+		{"epoch seconds", `{"Field":"ts","Within":"1m"}`, `{"ts":1709294370}`, true, false},
+		{"epoch millis", `{"Field":"ts","Within":"1m"}`, `{"ts":1709294370000}`, true, false},
+		{"old epoch", `{"Field":"ts","Within":"1m"}`, `{"ts":1709290000}`, false, false},
+		{"layout", `{"Field":"time","Within":"5m","Layout":"2006-01-02 15:04:05"}`, `{"time":"2024-03-01 11:58:00"}`, true, false},
+		{"layout mismatch", `{"Field":"time","Within":"5m","Layout":"2006-01-02 15:04:05"}`, `{"time":"2024-03-01T11:58:00Z"}`, false, false},
+		{"nested field", `{"Field":"meta.time","Within":"5m"}`, `{"meta":{"time":"2024-03-01T11:58:00Z"}}`, true, false},
+		{"missing", `{"Field":"time","Within":"5m"}`, `{"ts":"2024-03-01T11:58:00Z"}`, false, false},
+		{"unparseable", `{"Field":"time","Within":"5m"}`, `{"time":"a minute ago"}`, false, false},
+		{"not json", `{"Field":"time","Within":"5m"}`, `2024-03-01T11:58:00Z started`, false, false},
+		{"bad duration", `{"Field":"time","Within":"5 minutes"}`, `{"time":"2024-03-01T11:58:00Z"}`, false, true},
+		{"duration not string", `{"Field":"time","Within":300}`, `{"time":"2024-03-01T11:58:00Z"}`, false, true},
+	})
+}
+
+func TestRecentFollowsClock(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	ops := DefaultOps()
+	ops["recent"] = RecentOp(func() time.Time { return now })
+	r := Rule{Op: "recent", Data: map[string]any{"Field": "time", "Within": "5m"}}
+	line := NewLine(`{"time":"2024-03-01T11:58:00Z"}`)
+	for _, c := range []struct {
+		after time.Duration
+		want  bool
+	}{{0, true}, {3 * time.Minute, true}, {3*time.Minute + time.Second, false}} {
+		now = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC).Add(c.after)
+		got, err := r.Run(ops, line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("%v later: got %v, want %v", c.after, got, c.want)
+		}
+	}
+}
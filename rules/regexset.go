@@ -0,0 +1,140 @@
+package rules
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"sync"
+)
+
+// regexSetCacheSize bounds how many compiled sets are kept. Patterns come
+// from ad-hoc rules too, so the cache can't grow with every request.
+const regexSetCacheSize = 256
+
+var (
+	regexSetCache   = map[string]*list.Element{}
+	regexSetLRU     = list.New() // of *regexSetEntry, most recently used first
+	regexSetCacheMu sync.Mutex
+)
+
+type regexSetEntry struct {
+	key string
+	re  *regexp.Regexp
+}
+
+// compileRegexSet combines patterns into a single alternation so the line is
+// scanned once no matter how many patterns there are. Compiled sets are
+// cached since rule data is the same for every line, least recently used
+// ones are dropped past regexSetCacheSize.
+func compileRegexSet(patterns []any) (*regexp.Regexp, error) {
+	// built on every line to look the set up, so in a single allocation
+	n := 0
+	for i, p := range patterns {
+		s, ok := p.(string)
+		if !ok {
+			return nil, fmt.Errorf("pattern %d is not string", i)
+		}
+		n += len(s) + len("|(?:)")
+	}
+	var sb strings.Builder
+	sb.Grow(n)
+	for i, p := range patterns {
+		if i > 0 {
+			sb.WriteByte('|')
+		}
+		// groups keep flags like (?i) scoped to their own pattern
+		sb.WriteString("(?:")
+		sb.WriteString(p.(string))
+		sb.WriteByte(')')
+	}
+	key := sb.String()
+	regexSetCacheMu.Lock()
+	if el, ok := regexSetCache[key]; ok {
+		regexSetLRU.MoveToFront(el)
+		regexSetCacheMu.Unlock()
+		return el.Value.(*regexSetEntry).re, nil
+	}
+	regexSetCacheMu.Unlock()
+	re, err := regexp.Compile(key)
+	if err != nil {
+		// compile patterns one by one to tell which of them is broken
+		for i, p := range patterns {
+			if _, err := regexp.Compile(p.(string)); err != nil {
+				return nil, fmt.Errorf("pattern %d: %w", i, patternError(err))
+			}
+		}
+		return nil, patternError(err)
+	}
+	regexSetCacheMu.Lock()
+	defer regexSetCacheMu.Unlock()
+	if el, ok := regexSetCache[key]; ok {
+		regexSetLRU.MoveToFront(el)
+		return el.Value.(*regexSetEntry).re, nil
+	}
+	regexSetCache[key] = regexSetLRU.PushFront(&regexSetEntry{key: key, re: re})
+	if regexSetLRU.Len() > regexSetCacheSize {
+		oldest := regexSetLRU.Remove(regexSetLRU.Back()).(*regexSetEntry)
+		delete(regexSetCache, oldest.key)
+	}
+	return re, nil
+}
+
+// patternError drops the pattern from regexp errors, with placeholders
+// resolved patterns can have values that must not be shown
+func patternError(err error) error {
+	var se *syntax.Error
+	if errors.As(err, &se) {
+		return fmt.Errorf("invalid pattern: %s", se.Code)
+	}
+	return errors.New("invalid pattern")
+}
+
+// opRegexSet matches when any of the patterns matches. Data is either an
+// array of patterns matched against the raw line or an object with Field
+// and Patterns to match a string field.
+func opRegexSet(ops Ops, data, arg any) (bool, error) {
+	var patterns []any
+	field := ""
+	switch d := data.(type) {
+	case []any:
+		patterns = d
+	case map[string]any:
+		obj, f, err := dataObject("regexset", data)
+		if err != nil {
+			return false, err
+		}
+		patterns, _ = obj["Patterns"].([]any)
+		if patterns == nil {
+			return false, errors.New("rule regexset: Patterns is not array")
+		}
+		field = f
+	default:
+		return false, errors.New("rule regexset: data is not array or object")
+	}
+	if len(patterns) == 0 {
+		return false, nil
+	}
+	re, err := compileRegexSet(patterns)
+	if err != nil {
+		return false, fmt.Errorf("rule regexset: %w", err)
+	}
+	if field == "" {
+		s, ok := argString(arg)
+		if !ok {
+			return false, errors.New("rule regexset: arg is not string")
+		}
+		return re.MatchString(s), nil
+	}
+	v, ok := lineField(arg, field)
+	if !ok {
+		return false, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return false, nil
+	}
+	return re.MatchString(s), nil
+}
@@ -0,0 +1,92 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRegexSet(t *testing.T) {
+	testOp(t, DefaultOps(), "regexset", []opCase{
+		{"any pattern", `["^GET ","timeout$"]`, `read timeout`, true, false},
+		{"no pattern", `["^GET ","timeout$"]`, `POST /`, false, false},
+		{"flags stay in their pattern", `["(?i)error","^WARN"]`, `warn: disk`, false, false},
+		{"flags apply to their pattern", `["(?i)error","^WARN"]`, `ERROR: disk`, true, false},
+		{"anchors stay in their pattern", `["^a|b$","c"]`, `xbx`, false, false},
+		{"empty set", `[]`, `anything`, false, false},
+		{"field", `{"Field":"msg","Patterns":["^conn"]}`, `{"msg":"connection reset","x":"conn"}`, true, false},
+		{"field is not the line", `{"Field":"msg","Patterns":["^\\{"]}`, `{"msg":"ok"}`, false, false},
+		{"field missing", `{"Field":"msg","Patterns":["."]}`, `{"other":"x"}`, false, false},
+		{"field not string", `{"Field":"msg","Patterns":["1"]}`, `{"msg":1}`, false, false},
+		{"invalid pattern", `["ok","(broken"]`, `ok`, false, true},
+		{"pattern not string", `["ok",1]`, `ok`, false, true},
+		{"patterns not array", `{"Field":"msg","Patterns":"x"}`, `{"msg":"x"}`, false, true},
+		{"data not array or object", `"x"`, `x`, false, true},
+	})
+	_, err := Rule{Op: "regexset", Data: []any{"ok", "(broken"}}.Match("ok")
+	if err == nil || !strings.Contains(err.Error(), "pattern 1") {
+		t.Errorf("got %v, want the broken pattern named by index", err)
+	}
+}
+
+func TestRegexSetCacheBounded(t *testing.T) {
+	hot := []any{"hot"}
+	if _, err := compileRegexSet(hot); err != nil {
+		t.Fatal(err)
+	}
+	for i := range regexSetCacheSize * 2 {
+		if _, err := compileRegexSet([]any{fmt.Sprintf("ad-hoc %d", i)}); err != nil {
+			t.Fatal(err)
+		}
+		// keeps the hot set recently used
+		if _, err := compileRegexSet(hot); err != nil {
+			t.Fatal(err)
+		}
+	}
+	regexSetCacheMu.Lock()
+	defer regexSetCacheMu.Unlock()
+	if len(regexSetCache) != regexSetCacheSize || regexSetLRU.Len() != regexSetCacheSize {
+		t.Errorf("cache has %d sets in map and %d in list, want %d", len(regexSetCache), regexSetLRU.Len(), regexSetCacheSize)
+	}
+	if _, ok := regexSetCache["(?:hot)"]; !ok {
+		t.Error("recently used set was dropped")
+	}
+	if _, ok := regexSetCache["(?:ad-hoc 0)"]; ok {
+		t.Error("least recently used set was kept")
+	}
+}
+
+// BenchmarkRegexSet compares one regexset to the or of single pattern
+// regexsets people would write otherwise
+func BenchmarkRegexSet(b *testing.B) {
+	lines := []*Line{
+		NewLine(`{"level":"error","msg":"dial tcp 10.0.0.1:5432: connect: connection refused"}`),
+		NewLine(`{"level":"info","msg":"GET /api/view 200 12ms"}`),
+		NewLine(`{"level":"warn","msg":"slow query took 2.5s"}`),
+	}
+	for _, n := range []int{5, 20, 50} {
+		patterns := make([]any, n)
+		ors := make([]Rule, n)
+		for i := range n {
+			patterns[i] = fmt.Sprintf(`signature-%d: \w+ failed`, i)
+			ors[i] = Rule{Op: "regexset", Data: []any{patterns[i]}}
+		}
+		// the last pattern matches, so or can't stop early
+		patterns[n-1] = `connection (refused|reset)`
+		ors[n-1] = Rule{Op: "regexset", Data: []any{patterns[n-1]}}
+		for name, r := range map[string]Rule{
+			"regexset": {Op: "regexset", Data: patterns},
+			"or":       Or(ors...),
+		} {
+			b.Run(fmt.Sprintf("%s/patterns=%d", name, n), func(b *testing.B) {
+				ops := DefaultOps()
+				b.ReportAllocs()
+				for i := range b.N {
+					if _, err := r.Run(ops, lines[i%len(lines)]); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package rules
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+)
+
+// defaultOpCost is the cost of ops missing from cost tables, like ones
+// registered by embedders
+const defaultOpCost = 5
+
+// DefaultOpCosts are rough relative costs of evaluating ops on a line. Ops
+// looking at the raw line are the cheapest, field ops pay for parsing the
+// line once between all of them, regular expressions and ops decoding or
+// walking JSON on their own are the most expensive.
+var DefaultOpCosts = map[string]int{
+	"always":         0,
+	"never":          0,
+	"contains":       1,
+	"ncontains":      1,
+	"bytescontains":  1,
+	"countcontains":  2,
+	"exists":         3,
+	"isnull":         3,
+	"existsany":      3,
+	"existsall":      3,
+	"eq":             3,
+	"fieldcontains":  3,
+	"contains-value": 3,
+	"notinset":       3,
+	"minlevel":       3,
+	"repeat":         3,
+	"recent":         4,
+	"timeofday":      4,
+	"inset":          4,
+	"semver":         4,
+	"invalidjson":    4,
+	"fieldhash":      4,
+	"errchain":       4,
+	"normalize":      5,
+	"dupkeys":        6,
+	"fieldjson":      8,
+	"regexset":       10,
+}
+
+// Reorder returns the rule with children of and and or sorted cheapest
+// first, so short-circuiting skips expensive ops more often. Costs override
+// DefaultOpCosts, and, or and not cost as much as their children together
+// and normalize as much as its rule plus its own cost. Sorting is stable,
+// children of equal cost keep the order they were written in.
+//
+// Matching is not affected as long as ops have no side effects. Stateful
+// ops like delta do, they remember lines they were evaluated on, so lists
+// with one of them anywhere below are left in order. Errors may change:
+// a broken child that used to be skipped may now be evaluated and the other
+// way around, and error messages number children in the new order.
+func Reorder(r Rule, costs map[string]int) Rule {
+	ret, _ := reorder(r, costs)
+	return ret
+}
+
+// reorder returns the reordered rule with its cost and whether it has a
+// stateful op anywhere in it
+func reorder(r Rule, costs map[string]int) (Rule, ruleCost) {
+	switch r.Op {
+	case "not":
+		d, err := DataToRule(r.Data)
+		if err != nil {
+			return r, ruleCost{cost: opCost(r.Op, costs)}
+		}
+		d, c := reorder(d, costs)
+		return Rule{Op: r.Op, Data: d}, c
+	case "normalize":
+		obj, ok := r.Data.(map[string]any)
+		if !ok {
+			return r, ruleCost{cost: opCost(r.Op, costs)}
+		}
+		d, err := DataToRule(obj["Rule"])
+		if err != nil {
+			return r, ruleCost{cost: opCost(r.Op, costs)}
+		}
+		d, c := reorder(d, costs)
+		data := maps.Clone(obj)
+		data["Rule"] = d
+		c.cost += opCost(r.Op, costs)
+		return Rule{Op: r.Op, Data: data}, c
+	case "and", "or":
+		els, ok := r.Data.([]any)
+		if !ok {
+			return r, ruleCost{cost: opCost(r.Op, costs)}
+		}
+		children := make([]Rule, len(els))
+		childCosts := make([]ruleCost, len(els))
+		total := ruleCost{}
+		for i, el := range els {
+			d, err := DataToRule(el)
+			if err != nil {
+				return r, ruleCost{cost: opCost(r.Op, costs)}
+			}
+			children[i], childCosts[i] = reorder(d, costs)
+			total.cost += childCosts[i].cost
+			total.stateful = total.stateful || childCosts[i].stateful
+		}
+		order := make([]int, len(els))
+		for i := range order {
+			order[i] = i
+		}
+		if !total.stateful {
+			slices.SortStableFunc(order, func(a, b int) int {
+				return cmp.Compare(childCosts[a].cost, childCosts[b].cost)
+			})
+		}
+		data := make([]any, len(els))
+		for i, j := range order {
+			data[i] = children[j]
+		}
+		return Rule{Op: r.Op, Data: data}, total
+	}
+	_, stateful := statefulOps[r.Op]
+	return r, ruleCost{cost: opCost(r.Op, costs), stateful: stateful}
+}
+
+type ruleCost struct {
+	cost     int
+	stateful bool
+}
+
+func opCost(op string, costs map[string]int) int {
+	if c, ok := costs[op]; ok {
+		return c
+	}
+	if c, ok := DefaultOpCosts[op]; ok {
+		return c
+	}
+	return defaultOpCost
+}
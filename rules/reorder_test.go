@@ -0,0 +1,149 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestReorder(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		rule  string
+		costs map[string]int
+		want  string
+	}{
+		{"cheapest first",
+			`{"Op":"and","Data":[{"Op":"regexset","Data":["a"]},{"Op":"eq","Data":{"Field":"a","Value":1}},{"Op":"contains","Data":"a"}]}`, nil,
+			`{"Op":"and","Data":[{"Op":"contains","Data":"a"},{"Op":"eq","Data":{"Field":"a","Value":1}},{"Op":"regexset","Data":["a"]}]}`},
+		{"stable for equal costs",
+			`{"Op":"or","Data":[{"Op":"contains","Data":"b"},{"Op":"ncontains","Data":"a"},{"Op":"contains","Data":"a"}]}`, nil,
+			`{"Op":"or","Data":[{"Op":"contains","Data":"b"},{"Op":"ncontains","Data":"a"},{"Op":"contains","Data":"a"}]}`},
+		{"nested lists cost their children",
+			`{"Op":"and","Data":[{"Op":"or","Data":[{"Op":"eq","Data":{"Field":"a","Value":1}},{"Op":"contains","Data":"a"}]},{"Op":"exists","Data":"b"}]}`, nil,
+			`{"Op":"and","Data":[{"Op":"exists","Data":"b"},{"Op":"or","Data":[{"Op":"contains","Data":"a"},{"Op":"eq","Data":{"Field":"a","Value":1}}]}]}`},
+		{"not and normalize are reordered inside",
+			`{"Op":"not","Data":{"Op":"normalize","Data":{"Form":"NFD","Rule":{"Op":"and","Data":[{"Op":"fieldjson","Data":{}},{"Op":"contains","Data":"a"}]}}}}`, nil,
+			`{"Op":"not","Data":{"Op":"normalize","Data":{"Form":"NFD","Rule":{"Op":"and","Data":[{"Op":"contains","Data":"a"},{"Op":"fieldjson","Data":{}}]}}}}`},
+		{"costs override defaults",
+			`{"Op":"and","Data":[{"Op":"contains","Data":"a"},{"Op":"regexset","Data":["a"]}]}`, map[string]int{"contains": 20},
+			`{"Op":"and","Data":[{"Op":"regexset","Data":["a"]},{"Op":"contains","Data":"a"}]}`},
+		{"unknown ops have the default cost",
+			`{"Op":"and","Data":[{"Op":"regexset","Data":["a"]},{"Op":"custom"},{"Op":"eq","Data":{}}]}`, nil,
+			`{"Op":"and","Data":[{"Op":"eq","Data":{}},{"Op":"custom","Data":null},{"Op":"regexset","Data":["a"]}]}`},
+		{"stateful ops keep their list in order",
+			`{"Op":"and","Data":[{"Op":"regexset","Data":["a"]},{"Op":"or","Data":[{"Op":"delta","Data":{}},{"Op":"contains","Data":"a"}]}]}`, nil,
+			`{"Op":"and","Data":[{"Op":"regexset","Data":["a"]},{"Op":"or","Data":[{"Op":"delta","Data":{}},{"Op":"contains","Data":"a"}]}]}`},
+		{"lists next to stateful ones are still sorted",
+			`{"Op":"or","Data":[{"Op":"delta","Data":{}},{"Op":"and","Data":[{"Op":"regexset","Data":["a"]},{"Op":"contains","Data":"a"}]}]}`, nil,
+			`{"Op":"or","Data":[{"Op":"delta","Data":{}},{"Op":"and","Data":[{"Op":"contains","Data":"a"},{"Op":"regexset","Data":["a"]}]}]}`},
+		{"leaf", `{"Op":"regexset","Data":["a"]}`, nil, `{"Op":"regexset","Data":["a"]}`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var r Rule
+			if err := json.Unmarshal([]byte(c.rule), &r); err != nil {
+				t.Fatal(err)
+			}
+			b, err := json.Marshal(Reorder(r, c.costs))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != c.want {
+				t.Errorf("got  %s\nwant %s", b, c.want)
+			}
+		})
+	}
+}
+
+func TestReorderBrokenDataLeftAsIs(t *testing.T) {
+	for _, r := range []Rule{
+		{Op: "and", Data: "not a list"},
+		{Op: "or", Data: []any{"not a rule", map[string]any{"Op": "contains", "Data": "a"}}},
+		{Op: "not", Data: 1.0},
+		{Op: "normalize", Data: map[string]any{"Rule": "not a rule"}},
+	} {
+		got := Reorder(r, nil)
+		a, _ := json.Marshal(r)
+		b, _ := json.Marshal(got)
+		if string(a) != string(b) {
+			t.Errorf("got %s, want %s", b, a)
+		}
+	}
+}
+
+// TestReorderKeepsResults runs original and reordered rules over lines
+func TestReorderKeepsResults(t *testing.T) {
+	r := Or(
+		And(Rule{Op: "regexset", Data: []any{`"status":5\d\d`}}, Not(Rule{Op: "contains", Data: "healthz"})),
+		And(Rule{Op: "minlevel", Data: "warn"}, Rule{Op: "eq", Data: map[string]any{"Field": "user", "Value": "bob"}}),
+	)
+	reordered := Reorder(r, nil)
+	for _, line := range []string{
+		`{"status":500,"path":"/api"}`,
+		`{"status":503,"path":"/healthz"}`,
+		`{"status":200,"level":"error","user":"bob"}`,
+		`{"status":200,"level":"error","user":"alice"}`,
+		`{"level":"info","user":"bob"}`,
+		`plain text`,
+	} {
+		want, err := r.Match(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := reordered.Match(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s: reordered got %v, want %v", line, got, want)
+		}
+	}
+}
+
+// BenchmarkReorder runs a rule written expensive ops first over a mix of
+// lines, as written and reordered
+func BenchmarkReorder(b *testing.B) {
+	rule := And(
+		Rule{Op: "regexset", Data: []any{`(?i)timeout|refused|reset by peer`, `status=5\d\d`, `panic: .*`}},
+		Or(
+			Rule{Op: "minlevel", Data: "warn"},
+			Rule{Op: "eq", Data: map[string]any{"Field": "status", "Value": 500.0}},
+		),
+		Rule{Op: "eq", Data: map[string]any{"Field": "service", "Value": "api"}},
+		Rule{Op: "contains", Data: `"env":"prod"`},
+	)
+	levels := []string{"debug", "info", "info", "info", "warn", "error"}
+	services := []string{"api", "worker", "cron"}
+	messages := []string{"request done", "dial tcp: connection refused", "read: connection reset by peer", "cache miss", "context deadline exceeded (timeout)"}
+	envs := []string{"prod", "prod", "staging"}
+	lines := make([]string, 1000)
+	for i := range lines {
+		lines[i] = fmt.Sprintf(`{"time":"2024-03-01T10:00:00Z","level":%q,"service":%q,"env":%q,"status":%d,"message":%q,"req":{"path":"/api/view/%d"}}`,
+			levels[i%len(levels)], services[i%len(services)], envs[i%len(envs)], []int{200, 200, 404, 500}[i%4], messages[i%len(messages)], i)
+	}
+	ops := DefaultOps()
+	for _, c := range []struct {
+		name string
+		rule Rule
+	}{
+		{"as written", rule},
+		{"reordered", Reorder(rule, nil)},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			b.ReportAllocs()
+			matched := 0
+			for range b.N {
+				for _, l := range lines {
+					ok, err := c.rule.Run(ops, NewLine(l))
+					if err != nil {
+						b.Fatal(err)
+					}
+					if ok {
+						matched++
+					}
+				}
+			}
+			b.ReportMetric(float64(matched)/float64(b.N), "matched/op")
+		})
+	}
+}
@@ -0,0 +1,185 @@
+// Package rules is the filtering engine of the log viewer. A rule is a tree of
+// {Op, Data} objects as found in saved.json, evaluated by looking Op up in a
+// set of op functions.
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+)
+
+type Rule struct {
+	Op   string
+	Data any
+}
+
+// MarshalJSON encodes the rule in the {Op, Data} form saved.json uses.
+// Nested rules of not, and and or come out with Op first whether they were
+// built with Not, And and Or or decoded into maps, so rules round-trip to
+// the same bytes.
+func (r Rule) MarshalJSON() ([]byte, error) {
+	type plain Rule
+	data := r.Data
+	switch r.Op {
+	case "not":
+		if d, err := DataToRule(data); err == nil {
+			data = d
+		}
+	case "and", "or":
+		if els, ok := data.([]any); ok {
+			nested := make([]any, len(els))
+			for i, el := range els {
+				nested[i] = el
+				if d, err := DataToRule(el); err == nil {
+					nested[i] = d
+				}
+			}
+			data = nested
+		}
+	}
+	return json.Marshal(plain{Op: r.Op, Data: data})
+}
+
+// UnmarshalJSON checks that Op is set and that not, and and or have nested
+// rules of the right shape all the way down. Data is otherwise kept loosely
+// typed as ops expect it.
+func (r *Rule) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Op   string
+		Data any
+	}
+	err := json.Unmarshal(b, &raw)
+	if err != nil {
+		return err
+	}
+	ret := Rule{Op: raw.Op, Data: raw.Data}
+	err = ret.validate()
+	if err != nil {
+		return err
+	}
+	*r = ret
+	return nil
+}
+
+func (r Rule) validate() error {
+	if r.Op == "" {
+		return errors.New("rule: Op is empty")
+	}
+	switch r.Op {
+	case "not":
+		d, err := DataToRule(r.Data)
+		if err != nil {
+			return fmt.Errorf("rule not: %w", err)
+		}
+		return d.validate()
+	case "and", "or":
+		els, ok := r.Data.([]any)
+		if !ok {
+			return fmt.Errorf("rule %s: data is not array", r.Op)
+		}
+		for i, el := range els {
+			d, err := DataToRule(el)
+			if err != nil {
+				return fmt.Errorf("rule %s: data %d: %w", r.Op, i, err)
+			}
+			err = d.validate()
+			if err != nil {
+				return fmt.Errorf("rule %s: data %d: %w", r.Op, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+func Not(r Rule) Rule {
+	return Rule{Op: "not", Data: r}
+}
+
+func And(rs ...Rule) Rule {
+	return Rule{Op: "and", Data: ruleList(rs)}
+}
+
+func Or(rs ...Rule) Rule {
+	return Rule{Op: "or", Data: ruleList(rs)}
+}
+
+// ruleList is rs in the []any form and and or ops expect
+func ruleList(rs []Rule) []any {
+	ret := make([]any, len(rs))
+	for i, r := range rs {
+		ret[i] = r
+	}
+	return ret
+}
+
+// Run evaluates the rule against arg, which is a *Line, a raw line or a
+// parsed message. A *Line is parsed once for all field ops of the rule while
+// a plain string is parsed again by every one of them.
+func (r Rule) Run(ops Ops, arg any) (bool, error) {
+	op, ok := ops[r.Op]
+	if !ok {
+		return false, fmt.Errorf("run rule op %q not found", r.Op)
+	}
+	return op(ops, r.Data, arg)
+}
+
+// Match evaluates the rule against a raw line with the default ops
+func (r Rule) Match(line string) (bool, error) {
+	return r.Run(defaultOps, NewLine(line))
+}
+
+// MatchMap evaluates the rule against already parsed message with the
+// default ops. Ops looking at the raw line see the message encoded back to
+// JSON, which may differ from the original line in formatting.
+func (r Rule) MatchMap(msg map[string]any) (bool, error) {
+	return r.Run(defaultOps, msg)
+}
+
+// DataToRule converts loosely typed rule data (as decoded from JSON) to a
+// Rule, rules built in code with Not, And and Or are taken as is
+func DataToRule(data any) (ret Rule, err error) {
+	switch d := data.(type) {
+	case Rule:
+		return d, nil
+	case *Rule:
+		if d == nil {
+			return ret, errors.New("data to rule: nil rule")
+		}
+		return *d, nil
+	}
+	obj, ok := data.(map[string]any)
+	if !ok {
+		return ret, errors.New("data to rule: not an object")
+	}
+	ret.Op, ok = obj["Op"].(string)
+	if !ok {
+		return ret, errors.New("data to rule: Op not a string")
+	}
+	ret.Data = obj["Data"]
+	return ret, nil
+}
+
+// OpFn evaluates op with its Data against arg, ops are passed along so that
+// ops can run nested rules. Data comes from hand-edited config and arg from
+// arbitrary log lines, so ops must never panic: data of unexpected type is an
+// error and args without what the op looks at simply don't match.
+type OpFn func(ops Ops, data, arg any) (bool, error)
+
+type Ops map[string]OpFn
+
+// DefaultOps returns a copy of built-in ops that can be extended with
+// Register without affecting anyone else
+func DefaultOps() Ops {
+	return maps.Clone(defaultOps)
+}
+
+// Register adds an op, refusing to replace an existing one
+func (o Ops) Register(name string, fn OpFn) error {
+	if _, ok := o[name]; ok {
+		return fmt.Errorf("op %q is already registered", name)
+	}
+	o[name] = fn
+	return nil
+}
@@ -0,0 +1,341 @@
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDefaultOps has a line each default op matches and one it doesn't,
+// empty for always and never. New ops have to be added here.
+func TestDefaultOps(t *testing.T) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, c := range []struct {
+		op      string
+		data    string
+		match   string
+		noMatch string
+	}{
+		{"always", `null`, `x`, ``},
+		{"never", `null`, ``, `x`},
+		{"not", `{"Op":"contains","Data":"a"}`, `b`, `a`},
+		{"or", `[{"Op":"contains","Data":"a"},{"Op":"contains","Data":"b"}]`, `b`, `c`},
+		{"and", `[{"Op":"contains","Data":"a"},{"Op":"contains","Data":"b"}]`, `ab`, `a`},
+		{"contains", `"err"`, `{"msg":"error"}`, `{"msg":"ok"}`},
+		{"ncontains", `"err"`, `{"msg":"ok"}`, `{"msg":"error"}`},
+		{"bytescontains", `"\\xff"`, "a\xffb", `ab`},
+		{"fieldcontains", `{"Field":"msg","Value":"err"}`, `{"msg":"error"}`, `{"err":"ok"}`},
+		{"eq", `{"Field":"code","Value":"404"}`, `{"code":404}`, `{"code":200}`},
+		{"contains-value", `{"Field":"tags","Value":"db"}`, `{"tags":["db"]}`, `{"tags":["http"]}`},
+		{"notinset", `{"Field":"code","Values":[200,204]}`, `{"code":500}`, `{"code":"204"}`},
+		{"dupkeys", `null`, `{"a":1,"a":2}`, `{"a":1,"b":2}`},
+		{"exists", `"a.b"`, `{"a":{"b":null}}`, `{"a":{}}`},
+		{"isnull", `"a"`, `{"a":null}`, `{"a":0}`},
+		{"existsany", `["a","b"]`, `{"b":1}`, `{"c":1}`},
+		{"existsall", `["a","b"]`, `{"a":1,"b":1}`, `{"b":1}`},
+		{"regexset", `["^GET ","timeout$"]`, `read timeout`, `POST /`},
+		{"countcontains", `{"Value":"retry","Op":"gte","Count":2}`, `retry retry`, `retry`},
+		{"fieldjson", `{"Field":"req","Pattern":"\"method\":\"GET\""}`, `{"req":{"method":"GET"}}`, `{"req":{"method":"PUT"}}`},
+		{"semver", `{"Field":"v","Op":"lt","Value":"1.4.0"}`, `{"v":"1.3.9"}`, `{"v":"1.4.0"}`},
+		{"invalidjson", `null`, `{"a":`, `{"a":1}`},
+		{"timeofday", `{"Field":"t","From":"22:00","To":"06:00"}`, `{"t":"2024-03-01T23:00:00Z"}`, `{"t":"2024-03-01T12:00:00Z"}`},
+		{"fieldhash", `{"Field":"user","Hash":"#` + ValueHash("bob") + `"}`, `{"user":"bob"}`, `{"user":"alice"}`},
+		{"errchain", `{"Contains":"refused"}`, `{"errors":[{"msg":"dial"},{"msg":"connection refused"}]}`, `{"errors":[{"msg":"dial"}]}`},
+		{"normalize", `{"Rule":{"Op":"contains","Data":"café"}}`, "café", `cafe`},
+		{"repeat", `{"Min":3}`, `{"_repeat":5}`, `{"msg":"once"}`},
+		{"recent", `{"Field":"t","Within":"1h"}`, `{"t":"` + now + `"}`, `{"t":"2000-01-01T00:00:00Z"}`},
+		{"minlevel", `"warn"`, `{"level":"ERROR"}`, `{"level":"info"}`},
+	} {
+		t.Run(c.op, func(t *testing.T) {
+			var data any
+			if err := json.Unmarshal([]byte(c.data), &data); err != nil {
+				t.Fatal(err)
+			}
+			r := Rule{Op: c.op, Data: data}
+			for line, want := range map[string]bool{c.match: true, c.noMatch: false} {
+				if line == "" {
+					continue
+				}
+				got, err := r.Run(DefaultOps(), NewLine(line))
+				if err != nil {
+					t.Fatalf("%s: %v", line, err)
+				}
+				if got != want {
+					t.Errorf("%s: got %v, want %v", line, got, want)
+				}
+			}
+		})
+	}
+	t.Run("covered", func(t *testing.T) {
+		// inset reads files and delta needs a scan, both have their own tests
+		want := []string{"always", "and", "bytescontains", "contains", "contains-value", "countcontains", "delta", "dupkeys",
+			"eq", "errchain", "exists", "existsall", "existsany", "fieldcontains", "fieldhash", "fieldjson", "inset", "invalidjson",
+			"isnull", "minlevel", "ncontains", "never", "normalize", "not", "notinset", "or", "recent", "regexset", "repeat",
+			"semver", "timeofday"}
+		if got := slices.Sorted(maps.Keys(DefaultOps())); !slices.Equal(got, want) {
+			t.Errorf("default ops are %q, update the table", got)
+		}
+	})
+}
+
+func TestRegister(t *testing.T) {
+	ops := DefaultOps()
+	always := func(ops Ops, data, arg any) (bool, error) { return true, nil }
+	if err := ops.Register("contains", always); err == nil {
+		t.Error("replaced a built-in op")
+	}
+	if err := ops.Register("custom", always); err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Register("custom", always); err == nil {
+		t.Error("registered the same op twice")
+	}
+	if _, ok := DefaultOps()["custom"]; ok {
+		t.Error("op registered in a copy leaked into default ops")
+	}
+	r := Rule{Op: "custom"}
+	if got, err := r.Run(ops, "x"); err != nil || !got {
+		t.Errorf("got %v, %v", got, err)
+	}
+	if _, err := r.Run(DefaultOps(), "x"); err == nil {
+		t.Error("unregistered op ran")
+	}
+	if _, err := r.Match("x"); err == nil {
+		t.Error("Match ran an op missing from default ops")
+	}
+}
+
+// TestLineParsesOnce checks that field ops of one rule share the message
+func TestLineParsesOnce(t *testing.T) {
+	ops := DefaultOps()
+	// marks the parsed message so that later ops see whether it was reused
+	err := ops.Register("mark", func(ops Ops, data, arg any) (bool, error) {
+		l, ok := arg.(*Line)
+		if !ok {
+			return false, errors.New("mark: arg is not *Line")
+		}
+		msg, ok := l.Message()
+		if !ok {
+			return false, nil
+		}
+		msg["marked"] = true
+		return true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := And(Rule{Op: "mark"}, Rule{Op: "exists", Data: "marked"}, Rule{Op: "contains", Data: `"a":1`})
+	line := NewLine(`{"a":1}`)
+	got, err := r.Run(ops, line)
+	if err != nil || !got {
+		t.Fatalf("got %v, %v, want the mark seen by exists", got, err)
+	}
+	if line.Raw != `{"a":1}` {
+		t.Errorf("raw line changed to %s", line.Raw)
+	}
+	// ops get a plain string as it is, there is no Line to share
+	got, err = r.Run(ops, `{"a":1}`)
+	if err == nil {
+		t.Errorf("got %v for a string arg, want mark to refuse it", got)
+	}
+
+	l := NewLine(`{"a":1}`)
+	first, ok := l.Message()
+	second, _ := l.Message()
+	if !ok || reflect.ValueOf(first).Pointer() != reflect.ValueOf(second).Pointer() {
+		t.Error("Message parsed the line again")
+	}
+	for _, raw := range []string{``, `[1]`, `"s"`, `{"a":`, `plain`} {
+		if msg, ok := NewLine(raw).Message(); ok {
+			t.Errorf("%q parsed into %v", raw, msg)
+		}
+	}
+}
+
+func TestMatchMap(t *testing.T) {
+	r := And(Rule{Op: "eq", Data: map[string]any{"Field": "a", "Value": 1.0}}, Rule{Op: "contains", Data: `"b":"x"`})
+	got, err := r.MatchMap(map[string]any{"a": 1.0, "b": "x"})
+	if err != nil || !got {
+		t.Errorf("got %v, %v", got, err)
+	}
+	got, err = r.Match(`{"b": "x", "a": 1}`)
+	if err != nil || got {
+		t.Errorf("raw line with other formatting: got %v, %v", got, err)
+	}
+}
+
+func TestDataToRule(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		data any
+		want Rule
+		err  bool
+	}{
+		{"map", map[string]any{"Op": "contains", "Data": "x"}, Rule{Op: "contains", Data: "x"}, false},
+		{"map without data", map[string]any{"Op": "always"}, Rule{Op: "always"}, false},
+		{"rule", Rule{Op: "never"}, Rule{Op: "never"}, false},
+		{"rule pointer", &Rule{Op: "never"}, Rule{Op: "never"}, false},
+		{"nil rule pointer", (*Rule)(nil), Rule{}, true},
+		{"string", "contains", Rule{}, true},
+		{"op not string", map[string]any{"Op": 1}, Rule{}, true},
+		{"nil", nil, Rule{}, true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := DataToRule(c.data)
+			if (err != nil) != c.err {
+				t.Fatalf("got error %v, want error %v", err, c.err)
+			}
+			if !c.err && !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestForScanIsolatesState(t *testing.T) {
+	r := Rule{Op: "delta", Data: map[string]any{"Field": "n", "Threshold": 10.0}}
+	if _, err := r.Run(DefaultOps(), NewLine(`{"n":1}`)); err == nil || !strings.Contains(err.Error(), "scanning") {
+		t.Errorf("delta outside a scan: got %v", err)
+	}
+	a, b := DefaultOps().ForScan(), DefaultOps().ForScan()
+	run := func(ops Ops, line string) bool {
+		t.Helper()
+		got, err := r.Run(ops, NewLine(line))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	run(a, `{"n":0}`)
+	if run(b, `{"n":100}`) {
+		t.Error("second scan saw the value of the first one")
+	}
+	if !run(a, `{"n":100}`) {
+		t.Error("scan lost its previous value")
+	}
+	c := a.ForScan()
+	if run(c, `{"n":500}`) {
+		t.Error("ForScan of scan ops kept their state")
+	}
+}
+
+// deepRule nests not, and and or depth levels down
+func deepRule(depth int) Rule {
+	leaf := Rule{Op: "fieldcontains", Data: map[string]any{"Field": "msg", "Value": fmt.Sprint("v", depth)}}
+	if depth == 0 {
+		return leaf
+	}
+	switch depth % 3 {
+	case 0:
+		return Not(deepRule(depth - 1))
+	case 1:
+		return And(deepRule(depth-1), leaf, Rule{Op: "always"})
+	default:
+		return Or(leaf, deepRule(depth-1), Rule{Op: "eq", Data: map[string]any{"Field": "n", "Value": float64(depth)}})
+	}
+}
+
+func TestRuleRoundTrip(t *testing.T) {
+	for _, depth := range []int{0, 1, 2, 3, 10, 50} {
+		t.Run(fmt.Sprint(depth), func(t *testing.T) {
+			built := deepRule(depth)
+			b, err := json.Marshal(built)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded Rule
+			if err := json.Unmarshal(b, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			again, err := json.Marshal(decoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(again) != string(b) {
+				t.Fatalf("built and decoded rules encode differently:\n%s\n%s", b, again)
+			}
+			var redecoded Rule
+			if err := json.Unmarshal(again, &redecoded); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decoded, redecoded) {
+				t.Errorf("decoding again changed the rule:\n%#v\n%#v", decoded, redecoded)
+			}
+			for _, line := range []string{`{"msg":"v0","n":2}`, `{"msg":"v1"}`, `{"msg":"x"}`} {
+				want, err := built.Run(DefaultOps(), NewLine(line))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := decoded.Run(DefaultOps(), NewLine(line))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != want {
+					t.Errorf("%s: decoded rule got %v, built got %v", line, got, want)
+				}
+			}
+		})
+	}
+	t.Run("field order", func(t *testing.T) {
+		b, err := json.Marshal(Rule{Op: "and", Data: []any{map[string]any{"Data": "x", "Op": "contains"}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `{"Op":"and","Data":[{"Op":"contains","Data":"x"}]}`; string(b) != want {
+			t.Errorf("got %s, want %s", b, want)
+		}
+	})
+}
+
+func TestRuleUnmarshalValidates(t *testing.T) {
+	valid := `{"Op":"contains","Data":"x"}`
+	nest := func(s string, depth int) string {
+		for i := range depth {
+			if i%2 == 0 {
+				s = `{"Op":"not","Data":` + s + `}`
+			} else {
+				s = `{"Op":"and","Data":[` + valid + `,` + s + `]}`
+			}
+		}
+		return s
+	}
+	for _, c := range []struct {
+		name string
+		json string
+	}{
+		{"empty op", `{"Op":"","Data":"x"}`},
+		{"no op", `{"Data":"x"}`},
+		{"op not string", `{"Op":1}`},
+		{"not without rule", `{"Op":"not","Data":"x"}`},
+		{"not of nothing", `{"Op":"not"}`},
+		{"and not array", `{"Op":"and","Data":` + valid + `}`},
+		{"or element not rule", `{"Op":"or","Data":[` + valid + `,"x"]}`},
+		{"or element without op", `{"Op":"or","Data":[` + valid + `,{"Data":"x"}]}`},
+		{"deep empty op", nest(`{"Op":""}`, 20)},
+		{"deep bad and", nest(`{"Op":"and","Data":{}}`, 21)},
+		{"not json", `{"Op":`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			r := Rule{Op: "never"}
+			if err := json.Unmarshal([]byte(c.json), &r); err == nil {
+				t.Fatalf("decoded into %v", r)
+			}
+			if r.Op != "never" {
+				t.Errorf("rule changed to %v on error", r)
+			}
+		})
+	}
+	var r Rule
+	if err := json.Unmarshal([]byte(nest(valid, 21)), &r); err != nil {
+		t.Errorf("valid deep rule: %v", err)
+	}
+	if err := (Rule{Op: "and", Data: []any{Rule{Op: "always"}, Not(Rule{})}}).validate(); err == nil {
+		t.Error("validate passed a built rule with an empty op")
+	}
+}
@@ -0,0 +1,151 @@
+package rules
+
+import (
+	"cmp"
+	"errors"
+	"strings"
+)
+
+// semver is a parsed semantic version, build metadata is dropped as it
+// doesn't take part in comparisons
+type semver struct {
+	core [3]string // numeric, without leading zeros
+	pre  []string
+}
+
+// parseSemver accepts MAJOR.MINOR.PATCH with optional pre-release and build
+// metadata as in semver.org, and an optional leading "v"
+func parseSemver(s string) (semver, bool) {
+	ret := semver{}
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		if !validIdentifiers(s[i+1:], false) {
+			return ret, false
+		}
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		if !validIdentifiers(s[i+1:], true) {
+			return ret, false
+		}
+		ret.pre = strings.Split(s[i+1:], ".")
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return ret, false
+	}
+	for i, p := range parts {
+		if !isNumericIdentifier(p) {
+			return ret, false
+		}
+		ret.core[i] = p
+	}
+	return ret, true
+}
+
+// validIdentifiers checks dot-separated identifiers, numeric pre-release
+// ones can't have leading zeros
+func validIdentifiers(s string, pre bool) bool {
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return false
+		}
+		for _, c := range id {
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-') {
+				return false
+			}
+		}
+		if pre && isDigits(id) && !isNumericIdentifier(id) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+func isNumericIdentifier(s string) bool {
+	return isDigits(s) && (s == "0" || s[0] != '0')
+}
+
+// compareNumeric compares digit strings without leading zeros of any length
+func compareNumeric(a, b string) int {
+	return cmp.Or(cmp.Compare(len(a), len(b)), strings.Compare(a, b))
+}
+
+func compareSemver(a, b semver) int {
+	for i := range a.core {
+		if c := compareNumeric(a.core[i], b.core[i]); c != 0 {
+			return c
+		}
+	}
+	// a pre-release comes before its release
+	switch {
+	case len(a.pre) == 0 && len(b.pre) == 0:
+		return 0
+	case len(a.pre) == 0:
+		return 1
+	case len(b.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(a.pre) && i < len(b.pre); i++ {
+		x, y := a.pre[i], b.pre[i]
+		var c int
+		switch xn, yn := isDigits(x), isDigits(y); {
+		case xn && yn:
+			c = compareNumeric(x, y)
+		case xn:
+			c = -1
+		case yn:
+			c = 1
+		default:
+			c = strings.Compare(x, y)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(a.pre), len(b.pre))
+}
+
+// opSemver compares a version field, Data is {"Field": "version", "Op":
+// "lt", "Value": "1.4.0"}. Missing fields and values that are not semantic
+// versions don't match.
+func opSemver(ops Ops, data, arg any) (bool, error) {
+	obj, field, err := dataObject("semver", data)
+	if err != nil {
+		return false, err
+	}
+	cmpName, ok := obj["Op"].(string)
+	if !ok {
+		return false, errors.New("rule semver: Op is not string")
+	}
+	compare, ok := comparisons[cmpName]
+	if !ok {
+		return false, errors.New("rule semver: unknown Op")
+	}
+	value, ok := obj["Value"].(string)
+	if !ok {
+		return false, errors.New("rule semver: Value is not string")
+	}
+	check, ok := parseSemver(value)
+	if !ok {
+		return false, errors.New("rule semver: Value is not a semantic version")
+	}
+	v, ok := lineField(arg, field)
+	if !ok {
+		return false, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return false, nil
+	}
+	ver, ok := parseSemver(s)
+	if !ok {
+		return false, nil
+	}
+	return compare(compareSemver(ver, check)), nil
+}
@@ -0,0 +1,93 @@
+package rules
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseSemver(t *testing.T) {
+	for _, c := range []struct {
+		s  string
+		ok bool
+	}{
+		{"1.2.3", true},
+		{"v1.2.3", true},
+		{"0.0.0", true},
+		{"1.2.3-alpha", true},
+		{"1.2.3-alpha.1", true},
+		{"1.2.3-0.3.7", true},
+		{"1.2.3-x-y-z.--", true},
+		{"1.2.3+build", true},
+		{"1.2.3+build.001", true},
+		{"1.2.3-rc.1+build.5", true},
+		{"1.2.3+exp.sha.5114f85", true},
+		{"1.2.3-rc.1+build+5", false},
+		{"1.2.3-01", false},
+		{"1.2.3-rc.01", false},
+		{"1.2.3-", false},
+		{"1.2.3+", false},
+		{"1.2.3-rc..1", false},
+		{"1.2.3+build..1", false},
+		{"1.2.3-rc_1", false},
+		{"1.2.3+bü", false},
+		{"01.2.3", false},
+		{"1.2", false},
+		{"1.2.3.4", false},
+		{"1.2.x", false},
+		{"", false},
+		{"vv1.2.3", false},
+	} {
+		if _, ok := parseSemver(c.s); ok != c.ok {
+			t.Errorf("%q: got %v, want %v", c.s, ok, c.ok)
+		}
+	}
+}
+
+// TestSemverPrecedence uses the ordering example of semver.org
+func TestSemverPrecedence(t *testing.T) {
+	order := []string{
+		"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11",
+		"1.0.0-rc.1", "1.0.0", "1.0.1-0", "1.0.1", "1.2.0", "1.10.0", "2.0.0-rc.1", "2.0.0", "10.0.0",
+		"18446744073709551616.0.0",
+	}
+	for i, a := range order {
+		for j, b := range order {
+			va, _ := parseSemver(a)
+			vb, _ := parseSemver(b)
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			if got := compareSemver(va, vb); got != want {
+				t.Errorf("%s vs %s: got %d, want %d", a, b, got, want)
+			}
+		}
+	}
+}
+
+func TestSemver(t *testing.T) {
+	data := func(op, v string) string { return fmt.Sprintf(`{"Field":"v","Op":%q,"Value":%q}`, op, v) }
+	line := func(v string) string { return fmt.Sprintf(`{"v":%q}`, v) }
+	testOp(t, DefaultOps(), "semver", []opCase{
+		{"pre-release before release", data("lt", "1.4.0"), line("1.4.0-rc.1"), true, false},
+		{"release after pre-release", data("gt", "1.4.0-rc.1"), line("1.4.0"), true, false},
+		{"pre-release after previous release", data("gt", "1.3.9"), line("1.4.0-alpha"), true, false},
+		{"pre-release numeric", data("lt", "1.4.0-rc.10"), line("1.4.0-rc.9"), true, false},
+		{"pre-release numeric before alpha", data("lt", "1.4.0-rc.a"), line("1.4.0-rc.1"), true, false},
+		{"build ignored in field", data("eq", "1.4.0"), line("1.4.0+build.7"), true, false},
+		{"build ignored in value", data("eq", "1.4.0+build.1"), line("1.4.0+build.2"), true, false},
+		{"build ignored with pre-release", data("eq", "1.4.0-rc.1+a"), line("1.4.0-rc.1+b"), true, false},
+		{"build not compared", data("gt", "1.4.0+1"), line("1.4.0+2"), false, false},
+		{"v prefix", data("gte", "v1.4.0"), line("v1.4.0"), true, false},
+		{"lte", data("lte", "1.4.0"), line("1.4.0"), true, false},
+		{"invalid field", data("lt", "1.4.0"), line("1.4"), false, false},
+		{"invalid pre-release in field", data("lt", "1.4.0"), line("1.3.0-01"), false, false},
+		{"number field", data("lt", "1.4.0"), `{"v":1.3}`, false, false},
+		{"missing", data("lt", "1.4.0"), `{"version":"1.0.0"}`, false, false},
+		{"invalid value", data("lt", "1.4"), line("1.0.0"), false, true},
+		{"invalid build in value", data("lt", "1.4.0+"), line("1.0.0"), false, true},
+		{"unknown op", data("ne", "1.4.0"), line("1.0.0"), false, true},
+	})
+}
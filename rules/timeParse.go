@@ -0,0 +1,64 @@
+package rules
+
+import (
+	"maps"
+	"math"
+	"time"
+)
+
+var defaultTimeLayouts = []string{time.RFC3339Nano}
+
+// epochMillisFrom is where numeric times are taken as Unix milliseconds
+// rather than seconds, 1e12 seconds is tens of thousands of years away while
+// 1e12 milliseconds is 2001
+const epochMillisFrom = 1e12
+
+// ParseTime interprets value of a time field. Strings are parsed with
+// layouts tried in order (RFC3339 if there are none), numbers are Unix
+// seconds or, from 1e12 on, Unix milliseconds. Returns false if nothing fits.
+func ParseTime(v any, layouts []string) (time.Time, bool) {
+	switch v := v.(type) {
+	case string:
+		if len(layouts) == 0 {
+			layouts = defaultTimeLayouts
+		}
+		for _, l := range layouts {
+			t, err := time.Parse(l, v)
+			if err == nil {
+				return t, true
+			}
+		}
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return time.Time{}, false
+		}
+		if math.Abs(v) >= epochMillisFrom {
+			return time.UnixMilli(int64(v)).Add(time.Duration(math.Mod(v, 1) * 1e6)), true
+		}
+		sec, frac := math.Modf(v)
+		return time.Unix(int64(sec), int64(frac*1e9)), true
+	}
+	return time.Time{}, false
+}
+
+// timeLayouts are layouts a time op parses string times with, the op's own
+// "Layout" wins over the directory's layouts
+func timeLayouts(obj map[string]any, layouts []string) []string {
+	if l, ok := obj["Layout"].(string); ok {
+		return []string{l}
+	}
+	return layouts
+}
+
+// WithTimeLayouts returns ops whose time ops (recent, timeofday) parse
+// string times with layouts when rules don't give a Layout of their own,
+// so they read times the same way as views of the directory do
+func (o Ops) WithTimeLayouts(layouts []string) Ops {
+	if len(layouts) == 0 {
+		return o
+	}
+	ret := maps.Clone(o)
+	ret["recent"] = recentOp(time.Now, layouts)
+	ret["timeofday"] = timeOfDayOp(layouts)
+	return ret
+}
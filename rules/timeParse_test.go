@@ -0,0 +1,70 @@
+package rules
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	custom := []string{"2006-01-02 15:04:05.000", "02/Jan/2006:15:04:05 -0700"}
+	for _, c := range []struct {
+		name    string
+		v       any
+		layouts []string
+		want    time.Time
+		ok      bool
+	}{
+		{"rfc3339", "2024-03-01T10:20:30Z", nil, time.Date(2024, 3, 1, 10, 20, 30, 0, time.UTC), true},
+		{"rfc3339 nano offset", "2024-03-01T12:20:30.5+02:00", nil, time.Date(2024, 3, 1, 10, 20, 30, 5e8, time.UTC), true},
+		{"epoch seconds", 1709288430.0, nil, time.Date(2024, 3, 1, 10, 20, 30, 0, time.UTC), true},
+		{"epoch seconds fraction", 1709288430.25, nil, time.Date(2024, 3, 1, 10, 20, 30, 25e7, time.UTC), true},
+		{"epoch millis", 1709288430123.0, nil, time.Date(2024, 3, 1, 10, 20, 30, 123e6, time.UTC), true},
+		{"custom first layout", "2024-03-01 10:20:30.000", custom, time.Date(2024, 3, 1, 10, 20, 30, 0, time.UTC), true},
+		{"custom second layout", "01/Mar/2024:12:20:30 +0200", custom, time.Date(2024, 3, 1, 10, 20, 30, 0, time.UTC), true},
+		{"custom layouts replace rfc3339", "2024-03-01T10:20:30Z", custom, time.Time{}, false},
+		{"unparseable", "yesterday", nil, time.Time{}, false},
+		{"nan", math.NaN(), nil, time.Time{}, false},
+		{"infinity", math.Inf(1), nil, time.Time{}, false},
+		{"bool", true, nil, time.Time{}, false},
+		{"missing", nil, nil, time.Time{}, false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			got, ok := ParseTime(c.v, c.layouts)
+			if ok != c.ok || !got.Equal(c.want) {
+				t.Errorf("got %v, %v, want %v, %v", got, ok, c.want, c.ok)
+			}
+		})
+	}
+}
+
+func TestTimeOpsWithTimeLayouts(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+	ops := DefaultOps().WithTimeLayouts([]string{"2006-01-02 15:04:05"})
+	ops["recent"] = recentOp(func() time.Time { return now }, []string{"2006-01-02 15:04:05"})
+	line := NewLine(`{"time":"2024-03-01 10:20:30"}`)
+	for _, c := range []struct {
+		name string
+		rule Rule
+		want bool
+	}{
+		{"recent with directory layout", Rule{Op: "recent", Data: map[string]any{"Field": "time", "Within": "15m"}}, true},
+		{"timeofday with directory layout", Rule{Op: "timeofday", Data: map[string]any{"Field": "time", "From": "10:00", "To": "11:00"}}, true},
+		{"rule layout wins", Rule{Op: "timeofday", Data: map[string]any{"Field": "time", "From": "10:00", "To": "11:00", "Layout": time.RFC3339}}, false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := c.rule.Run(ops, line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != c.want {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+
+	got, err := Rule{Op: "timeofday", Data: map[string]any{"Field": "time", "From": "10:00", "To": "11:00"}}.Run(DefaultOps(), line)
+	if err != nil || got {
+		t.Errorf("default ops read a non-RFC3339 time: %v, %v", got, err)
+	}
+}
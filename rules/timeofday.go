@@ -0,0 +1,95 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	locationCache   = map[string]*time.Location{}
+	locationCacheMu sync.Mutex
+)
+
+// loadLocation is time.LoadLocation cached, loading reads zoneinfo files
+func loadLocation(name string) (*time.Location, error) {
+	locationCacheMu.Lock()
+	defer locationCacheMu.Unlock()
+	if loc, ok := locationCache[name]; ok {
+		return loc, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locationCache[name] = loc
+	return loc, nil
+}
+
+// parseClock parses "15:04" or "15:04:05" into time since midnight
+func parseClock(s string) (time.Duration, error) {
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second, nil
+		}
+	}
+	return 0, errors.New("not a HH:MM clock time")
+}
+
+// timeOfDayOp makes the timeofday op, which matches when clock time of a
+// time field falls in a window whatever the date. Data is {"Field": "time",
+// "From": "22:00", "To": "06:00"} with optional "TZ" (UTC by default) and
+// "Layout" like recent. From is inclusive and To exclusive, From later than
+// To makes the window cross midnight. Lines with missing or unparseable time
+// never match.
+func timeOfDayOp(layouts []string) OpFn {
+	return func(ops Ops, data, arg any) (bool, error) {
+		obj, field, err := dataObject("timeofday", data)
+		if err != nil {
+			return false, err
+		}
+		fromS, ok := obj["From"].(string)
+		if !ok {
+			return false, errors.New("rule timeofday: From is not string")
+		}
+		toS, ok := obj["To"].(string)
+		if !ok {
+			return false, errors.New("rule timeofday: To is not string")
+		}
+		from, err := parseClock(fromS)
+		if err != nil {
+			return false, fmt.Errorf("rule timeofday: From: %w", err)
+		}
+		to, err := parseClock(toS)
+		if err != nil {
+			return false, fmt.Errorf("rule timeofday: To: %w", err)
+		}
+		if from == to {
+			return false, errors.New("rule timeofday: From and To are the same, window is empty")
+		}
+		loc := time.UTC
+		if tz, ok := obj["TZ"].(string); ok && tz != "" {
+			loc, err = loadLocation(tz)
+			if err != nil {
+				return false, errors.New("rule timeofday: TZ is not a known time zone")
+			}
+		}
+		v, ok := lineField(arg, field)
+		if !ok {
+			return false, nil
+		}
+		t, ok := ParseTime(v, timeLayouts(obj, layouts))
+		if !ok {
+			return false, nil
+		}
+		t = t.In(loc)
+		clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+			time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+		if from < to {
+			return clock >= from && clock < to, nil
+		}
+		return clock >= from || clock < to, nil
+	}
+}
@@ -0,0 +1,51 @@
+package rules
+
+import (
+	"fmt"
+	"testing"
+	_ "time/tzdata" // zones don't depend on the system having them
+)
+
+func TestTimeOfDay(t *testing.T) {
+	night := `{"Field":"t","From":"22:00","To":"06:00"}`
+	line := func(ts string) string { return fmt.Sprintf(`{"t":%q}`, ts) }
+	testOp(t, DefaultOps(), "timeofday", []opCase{
+		{"inside", `{"Field":"t","From":"09:00","To":"17:00"}`, line("2024-03-01T12:00:00Z"), true, false},
+		{"before", `{"Field":"t","From":"09:00","To":"17:00"}`, line("2024-03-01T08:59:59Z"), false, false},
+		{"from inclusive", `{"Field":"t","From":"09:00","To":"17:00"}`, line("2024-03-01T09:00:00Z"), true, false},
+		{"to exclusive", `{"Field":"t","From":"09:00","To":"17:00"}`, line("2024-03-01T17:00:00Z"), false, false},
+		{"just before to", `{"Field":"t","From":"09:00","To":"17:00"}`, line("2024-03-01T16:59:59.999Z"), true, false},
+		{"seconds in window", `{"Field":"t","From":"09:00:30","To":"09:01"}`, line("2024-03-01T09:00:29Z"), false, false},
+
+		{"midnight span evening", night, line("2024-03-01T23:30:00Z"), true, false},
+		{"midnight span at midnight", night, line("2024-03-02T00:00:00Z"), true, false},
+		{"midnight span morning", night, line("2024-03-02T05:59:59Z"), true, false},
+		{"midnight span to exclusive", night, line("2024-03-02T06:00:00Z"), false, false},
+		{"midnight span from inclusive", night, line("2024-03-01T22:00:00Z"), true, false},
+		{"midnight span day", night, line("2024-03-01T12:00:00Z"), false, false},
+		{"midnight span just before from", night, line("2024-03-01T21:59:59Z"), false, false},
+		{"ends at midnight", `{"Field":"t","From":"18:00","To":"00:00"}`, line("2024-03-01T23:59:59Z"), true, false},
+		{"ends at midnight excludes it", `{"Field":"t","From":"18:00","To":"00:00"}`, line("2024-03-02T00:00:00Z"), false, false},
+		{"starts at midnight", `{"Field":"t","From":"00:00","To":"06:00"}`, line("2024-03-02T00:00:00Z"), true, false},
+
+		{"offset in time", `{"Field":"t","From":"09:00","To":"17:00"}`, line("2024-03-01T12:00:00+08:00"), false, false},
+		{"offset in time to UTC", `{"Field":"t","From":"04:00","To":"05:00"}`, line("2024-03-01T12:00:00+08:00"), true, false},
+		{"TZ", `{"Field":"t","From":"09:00","To":"17:00","TZ":"Europe/Berlin"}`, line("2024-01-15T08:30:00Z"), true, false},
+		{"TZ outside", `{"Field":"t","From":"09:00","To":"17:00","TZ":"Europe/Berlin"}`, line("2024-01-15T16:30:00Z"), false, false},
+		{"TZ summer time", `{"Field":"t","From":"09:00","To":"17:00","TZ":"Europe/Berlin"}`, line("2024-07-15T07:30:00Z"), true, false},
+		{"TZ winter time", `{"Field":"t","From":"09:00","To":"17:00","TZ":"Europe/Berlin"}`, line("2024-01-15T07:30:00Z"), false, false},
+		{"TZ half hour", `{"Field":"t","From":"00:00","To":"01:00","TZ":"Asia/Kolkata"}`, line("2024-03-01T18:45:00Z"), true, false},
+		{"TZ moves across midnight", `{"Field":"t","From":"22:00","To":"06:00","TZ":"America/New_York"}`, line("2024-03-01T12:00:00Z"), false, false},
+		{"TZ night in UTC afternoon", `{"Field":"t","From":"22:00","To":"06:00","TZ":"Asia/Tokyo"}`, line("2024-03-01T14:00:00Z"), true, false},
+		{"TZ empty is UTC", `{"Field":"t","From":"09:00","To":"17:00","TZ":""}`, line("2024-03-01T12:00:00Z"), true, false},
+		{"unix seconds", `{"Field":"t","From":"09:00","To":"17:00"}`, `{"t":1709294400}`, true, false},
+
+		{"missing", night, `{"time":"2024-03-01T23:00:00Z"}`, false, false},
+		{"unparseable", night, line("yesterday at 11pm"), false, false},
+		{"not json", night, `2024-03-01T23:00:00Z`, false, false},
+		{"unknown TZ", `{"Field":"t","From":"09:00","To":"17:00","TZ":"Mars/Olympus"}`, line("2024-03-01T12:00:00Z"), false, true},
+		{"bad From", `{"Field":"t","From":"9am","To":"17:00"}`, line("2024-03-01T12:00:00Z"), false, true},
+		{"bad To", `{"Field":"t","From":"09:00","To":"24:00"}`, line("2024-03-01T12:00:00Z"), false, true},
+		{"empty window", `{"Field":"t","From":"09:00","To":"09:00"}`, line("2024-03-01T09:00:00Z"), false, true},
+	})
+}
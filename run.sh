@@ -1,3 +1,3 @@
 #!/bin/bash
 
-templ generate && go build -v && ./main "$@"
+templ generate && go build -v -o main && ./main "$@"
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+const defaultScanCacheTTL = 30 * time.Second
+
+type scanCacheEntry struct {
+	res     dirResult
+	created time.Time
+	used    time.Time
+}
+
+// scanCache keeps results of view scans. Keys include sizes and modification
+// times of the scanned files, so appends and rotation miss the cache right
+// away, TTL bounds how stale results of remote sources and time-dependent
+// rules like recent can get.
+var (
+	scanCache   = map[string]*scanCacheEntry{}
+	scanCacheMu sync.Mutex
+
+	scanCacheHits, scanCacheMisses, scanCacheEvictions int
+)
+
+func (s Settings) scanCacheTTL() time.Duration {
+	if s.ScanCacheTTL <= 0 {
+		return defaultScanCacheTTL
+	}
+	return time.Duration(s.ScanCacheTTL) * time.Second
+}
+
+type fileStamp struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// scanCacheKey is everything the result of the scan depends on
+func scanCacheKey(p viewParams, opts scanOptions, rule *rules.Rule) (string, error) {
+	files, err := logFiles(p.Dir, opts)
+	if err != nil {
+		return "", err
+	}
+	stamps := []fileStamp{}
+	for _, fp := range files {
+		if isRemote(fp) {
+			stamps = append(stamps, fileStamp{Path: fp})
+			continue
+		}
+		st, err := os.Stat(fp)
+		if err != nil {
+			return "", err
+		}
+		stamps = append(stamps, fileStamp{Path: fp, Size: st.Size(), ModTime: st.ModTime()})
+	}
+	b, err := json.Marshal(struct {
+		Dir           string
+		Opts          scanOptions
+		Rule          *rules.Rule
+		Limit, Offset int
+		Files         []fileStamp
+	}{p.Dir, opts, rule, p.Limit, p.Offset, stamps})
+	return string(b), err
+}
+
+// processView is processDir for view parameters going through the scan
+// cache when it is enabled. Cached results are shared and must not be
+// modified.
+func (s SavedStuff) processView(ctx context.Context, p viewParams, rule *rules.Rule) (dirResult, error) {
+	opts := s.scanOptions(p)
+	size := s.Settings.ScanCacheSize
+	if size <= 0 {
+		return processDir(ctx, p.Dir, opts, rule, p.Limit, p.Offset)
+	}
+	key, err := scanCacheKey(p, opts, rule)
+	if err != nil {
+		return dirResult{}, err
+	}
+	ttl := s.Settings.scanCacheTTL()
+	scanCacheMu.Lock()
+	e, ok := scanCache[key]
+	if ok && time.Since(e.created) < ttl {
+		scanCacheHits++
+		e.used = time.Now()
+		scanCacheMu.Unlock()
+		return e.res, nil
+	}
+	scanCacheMisses++
+	scanCacheMu.Unlock()
+
+	res, err := processDir(ctx, p.Dir, opts, rule, p.Limit, p.Offset)
+	if err != nil || res.TimedOut {
+		return res, err
+	}
+	scanCacheMu.Lock()
+	defer scanCacheMu.Unlock()
+	now := time.Now()
+	for k, e := range scanCache {
+		if now.Sub(e.created) >= ttl {
+			delete(scanCache, k)
+		}
+	}
+	for len(scanCache) >= size {
+		evictScanCache()
+	}
+	scanCache[key] = &scanCacheEntry{res: res, created: now, used: now}
+	return res, nil
+}
+
+// evictScanCache drops the least recently used entry
+func evictScanCache() {
+	oldest := ""
+	for k, e := range scanCache {
+		if oldest == "" || e.used.Before(scanCache[oldest].used) {
+			oldest = k
+		}
+	}
+	delete(scanCache, oldest)
+	scanCacheEvictions++
+}
+
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	scanCacheMu.Lock()
+	hits, misses, evictions, entries := scanCacheHits, scanCacheMisses, scanCacheEvictions, len(scanCache)
+	scanCacheMu.Unlock()
+	w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+	fmt.Fprintln(w, "# TYPE logviewer_scan_cache_hits counter")
+	fmt.Fprintf(w, "logviewer_scan_cache_hits_total %d\n", hits)
+	fmt.Fprintln(w, "# TYPE logviewer_scan_cache_misses counter")
+	fmt.Fprintf(w, "logviewer_scan_cache_misses_total %d\n", misses)
+	fmt.Fprintln(w, "# TYPE logviewer_scan_cache_evictions counter")
+	fmt.Fprintf(w, "logviewer_scan_cache_evictions_total %d\n", evictions)
+	fmt.Fprintln(w, "# TYPE logviewer_scan_cache_entries gauge")
+	fmt.Fprintf(w, "logviewer_scan_cache_entries %d\n", entries)
+	fmt.Fprintln(w, "# EOF")
+}
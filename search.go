@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/a-h/templ"
+)
+
+const (
+	defaultSearchTailLines = 5000
+	searchSamplesPerDir    = 50
+	searchTimeCap          = 10 * time.Second
+)
+
+type searchDirResult struct {
+	Dir          string
+	MessageField string
+	Matched      int
+	Samples      []map[string]any // newest first, at most searchSamplesPerDir
+	Err          string
+	TimedOut     bool // searchTimeCap was hit while the directory was searched
+}
+
+type searchResult struct {
+	Query     string
+	Dirs      []searchDirResult
+	TimedOut  bool // search took too long, some lines or directories were skipped
+	TailLines int
+}
+
+// searchDirs looks for the substring in tails of every file of every
+// configured directory, stopping at searchTimeCap
+func searchDirs(saved SavedStuff, q string) searchResult {
+	ret := searchResult{Query: q, TailLines: saved.Settings.SearchTailLines}
+	if ret.TailLines <= 0 {
+		ret.TailLines = defaultSearchTailLines
+	}
+	deadline := time.Now().Add(searchTimeCap)
+	for _, dir := range slices.Sorted(maps.Keys(saved.LogDirs)) {
+		if time.Now().After(deadline) {
+			ret.TimedOut = true
+			break
+		}
+		d := searchDir(dir, saved.dirSettings(dir), saved.dirScanOptions(dir), q, ret.TailLines, deadline)
+		ret.Dirs = append(ret.Dirs, d)
+		if d.TimedOut {
+			ret.TimedOut = true
+			break
+		}
+	}
+	return ret
+}
+
+// searchDir matches q against redacted lines, so that searching for a
+// redacted value does not reveal which lines had it. The deadline is checked
+// every 1024 lines, one big directory can't hold the search past it, nor can
+// a stalled remote source. Files are read as opts say, see dirScanOptions.
+func searchDir(dir string, ds DirSettings, opts scanOptions, q string, tailLines int, deadline time.Time) searchDirResult {
+	ret := searchDirResult{Dir: dir, MessageField: ds.messageField()}
+	red, err := ds.Redact.compile()
+	if err != nil {
+		ret.Err = err.Error()
+		return ret
+	}
+	files, err := logFiles(dir, scanOptions{})
+	if err != nil {
+		ret.Err = err.Error()
+		return ret
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	opts = opts.withContext(ctx)
+	buf := NewLogBuffer(searchSamplesPerDir)
+	n := 0
+files:
+	for _, fp := range files {
+		lines, err := tailFile(fp, tailLines, opts)
+		if err != nil {
+			ret.Err = err.Error()
+			return ret
+		}
+		for _, l := range lines {
+			if n%1024 == 0 && time.Now().After(deadline) {
+				ret.TimedOut = true
+				break files
+			}
+			n++
+			if strings.Contains(red.line(l), q) {
+				ret.Matched++
+				buf.Push(l)
+			}
+		}
+	}
+	samples, err := buf.Get(0, searchSamplesPerDir)
+	if err != nil {
+		ret.Err = err.Error()
+		return ret
+	}
+	for _, l := range slices.Backward(samples) {
+		m := parseMessage(l, ret.MessageField)
+		red.message(m)
+		ret.Samples = append(ret.Samples, m)
+	}
+	return ret
+}
+
+func handleSearch(w http.ResponseWriter, r *http.Request) {
+	saved, err := loadSaved()
+	if err != nil {
+		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
+		return
+	}
+	q := r.URL.Query().Get("q")
+	res := searchResult{Query: q}
+	if q != "" {
+		res = searchDirs(saved, q)
+	}
+	templ.Handler(tPage(tSearch(res))).ServeHTTP(w, r)
+}
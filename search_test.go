@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearchDirRedacted(t *testing.T) {
+	dir := writeLogDir(t, map[string]string{
+		"a.log": "{\"message\":\"login\",\"token\":\"s3cr3t\"}\n{\"message\":\"mail to bob@example.com\"}\n{\"message\":\"s3cr3t in text\"}\n",
+	})
+	ds := DirSettings{Redact: &Redaction{Fields: []string{"token"}, Patterns: []string{`[a-z]+@example\.com`}}}
+	deadline := time.Now().Add(time.Minute)
+	for _, c := range []struct {
+		q    string
+		want int
+	}{
+		{"s3cr3t", 1},
+		{"bob@example.com", 0},
+		{"example", 0},
+		{redactedMask, 2},
+		{"login", 1},
+	} {
+		t.Run(c.q, func(t *testing.T) {
+			res := searchDir(dir, ds, scanOptions{}, c.q, 100, deadline)
+			if res.Err != "" {
+				t.Fatal(res.Err)
+			}
+			if res.Matched != c.want {
+				t.Errorf("got %d matched, want %d", res.Matched, c.want)
+			}
+			for _, m := range res.Samples {
+				if m["token"] == "s3cr3t" || strings.Contains(m["message"].(string), "bob@") {
+					t.Errorf("sample not redacted: %v", m)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchDirDeadline(t *testing.T) {
+	dir := writeLogDir(t, map[string]string{
+		"a.log": strings.Repeat("{\"message\":\"hit\"}\n", 3000),
+	})
+	res := searchDir(dir, DirSettings{}, scanOptions{}, "hit", 5000, time.Now().Add(-time.Second))
+	if !res.TimedOut {
+		t.Error("past deadline did not stop the search")
+	}
+	if res.Matched >= 3000 {
+		t.Errorf("got %d matched, search was not stopped inside the file", res.Matched)
+	}
+	res = searchDir(dir, DirSettings{}, scanOptions{}, "hit", 5000, time.Now().Add(time.Minute))
+	if res.TimedOut || res.Matched != 3000 {
+		t.Errorf("got %d matched, timed out %v, want 3000 and no timeout", res.Matched, res.TimedOut)
+	}
+}
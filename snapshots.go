@@ -0,0 +1,299 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+const (
+	// maxSnapshotSignatures bounds signatures kept per scan, lines of kinds
+	// past it are only counted as Untracked
+	maxSnapshotSignatures = 10000
+	// maxSignatureMessage is how much of a signature's text is kept, the
+	// hash is still of the whole text
+	maxSignatureMessage = 1000
+)
+
+var (
+	errSnapshotsDisabled = errors.New("snapshots are disabled, set Settings.SnapshotDir to enable them")
+	snapshotNameRe       = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+)
+
+// signature is a kind of message in a view, messages with the same text
+// (redacted, message field only) share it whatever their times and other
+// fields are. Lines without the message field are taken whole. Hash is
+// rules.ValueHash of the text.
+type signature struct {
+	Hash    string `json:"hash"`
+	Message string `json:"message"`
+	Count   int    `json:"count"`
+}
+
+// snapshot is the set of signatures of a view at some point, like before a
+// deploy. The view is stored along so diffs look at the same thing again.
+type snapshot struct {
+	Name       string       `json:"name"`
+	Created    time.Time    `json:"created"`
+	Dir        string       `json:"dir"`
+	RuleSet    string       `json:"ruleset,omitempty"`
+	Rule       string       `json:"rule,omitempty"`
+	NoDefault  bool         `json:"nodefault,omitempty"`
+	Files      string       `json:"files,omitempty"`
+	Exclude    string       `json:"exclude,omitempty"`
+	MaxScan    int          `json:"maxscan,omitempty"`
+	Scanned    int          `json:"scanned"`
+	Untracked  int          `json:"untracked,omitempty"` // matched lines past maxSnapshotSignatures kinds
+	Signatures []*signature `json:"signatures,omitempty"`
+}
+
+func (s snapshot) viewParams() viewParams {
+	return viewParams{
+		Dir:       s.Dir,
+		RuleSet:   s.RuleSet,
+		Rule:      s.Rule,
+		NoDefault: s.NoDefault,
+		Files:     s.Files,
+		Exclude:   s.Exclude,
+		MaxScan:   s.MaxScan,
+	}
+}
+
+// viewSignatures counts signatures of every line the view's effective rule
+// matches, most frequent first. Lines of new kinds past
+// maxSnapshotSignatures are not kept, only counted as untracked.
+func (s SavedStuff) viewSignatures(p viewParams, rule *rules.Rule) (sigs []*signature, scanned, untracked int, err error) {
+	opts := s.scanOptions(p)
+	if opts.MaxLines <= 0 {
+		opts.MaxLines = diffDefaultMaxScan
+	}
+	red, err := opts.Redact.compile()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	match := ruleMatcher(rule, opts)
+	found := map[string]*signature{}
+	_, err = scanDir(p.Dir, opts, func(fp, line string) error {
+		scanned++
+		if match != nil {
+			ok, err := match(fp, line)
+			if err != nil || !ok {
+				return err
+			}
+		}
+		m := opts.parseMessage(line)
+		red.message(m)
+		text := line
+		if v, ok := m[opts.messageField()]; ok {
+			if text, ok = v.(string); !ok {
+				b, _ := json.Marshal(v)
+				text = string(b)
+			}
+		}
+		h := rules.ValueHash(text)
+		if sig, ok := found[h]; ok {
+			sig.Count++
+		} else if len(found) < maxSnapshotSignatures {
+			found[h] = &signature{Hash: h, Message: linePreview(text, maxSignatureMessage), Count: 1}
+		} else {
+			untracked++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, scanned, untracked, err
+	}
+	sigs = slices.SortedFunc(maps.Values(found), func(a, b *signature) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), strings.Compare(a.Hash, b.Hash))
+	})
+	return sigs, scanned, untracked, nil
+}
+
+func (s Settings) snapshotPath(name string) (string, error) {
+	if s.SnapshotDir == "" {
+		return "", errSnapshotsDisabled
+	}
+	if !snapshotNameRe.MatchString(name) {
+		return "", fmt.Errorf("snapshot name %q has to be letters, digits, dots, dashes and underscores", name)
+	}
+	return filepath.Join(s.SnapshotDir, name+".json"), nil
+}
+
+func loadSnapshot(fp string) (*snapshot, error) {
+	b, err := os.ReadFile(fp)
+	if err != nil {
+		return nil, err
+	}
+	ret := &snapshot{}
+	return ret, json.Unmarshal(b, ret)
+}
+
+// handleSnapshotCreate saves signatures of the view (same path and
+// parameters as /view) under the name parameter, replacing a snapshot
+// with the same name. It writes to disk, so it wants the debug token.
+func handleSnapshotCreate(w http.ResponseWriter, r *http.Request) {
+	saved, err := loadSaved()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err := saved.Settings.debugAuthorized(r); err != nil {
+		writeJSONError(w, http.StatusForbidden, err)
+		return
+	}
+	fp, err := saved.Settings.snapshotPath(r.URL.Query().Get("name"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	p := parseViewParams(r)
+	snap := snapshot{
+		Name:      r.URL.Query().Get("name"),
+		Created:   time.Now(),
+		Dir:       p.Dir,
+		RuleSet:   p.RuleSet,
+		Rule:      p.Rule,
+		NoDefault: p.NoDefault,
+		Files:     p.Files,
+		Exclude:   p.Exclude,
+		MaxScan:   p.MaxScan,
+	}
+	rule, err := saved.effectiveRule(p)
+	if err != nil {
+		writeJSONError(w, ruleErrorStatus(err), err)
+		return
+	}
+	snap.Signatures, snap.Scanned, snap.Untracked, err = saved.viewSignatures(p, rule)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	b, err := json.MarshalIndent(snap, "", "\t")
+	if err == nil {
+		err = writeFileAtomic(fp, b)
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, snap)
+}
+
+// handleSnapshots lists snapshots without their signatures, newest first
+func handleSnapshots(w http.ResponseWriter, r *http.Request) {
+	saved, err := loadSaved()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if saved.Settings.SnapshotDir == "" {
+		writeJSONError(w, http.StatusNotFound, errSnapshotsDisabled)
+		return
+	}
+	fps, err := filepath.Glob(filepath.Join(saved.Settings.SnapshotDir, "*.json"))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	ret := []snapshot{}
+	for _, fp := range fps {
+		snap, err := loadSnapshot(fp)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("snapshot %q: %w", filepath.Base(fp), err))
+			return
+		}
+		snap.Signatures = nil
+		ret = append(ret, *snap)
+	}
+	slices.SortFunc(ret, func(a, b snapshot) int { return b.Created.Compare(a.Created) })
+	writeJSON(w, http.StatusOK, ret)
+}
+
+type signatureDiff struct {
+	Hash    string `json:"hash"`
+	Message string `json:"message"`
+	Before  int    `json:"before"`
+	Now     int    `json:"now"`
+}
+
+type apiSnapshotDiffResponse struct {
+	Snapshot string    `json:"snapshot"`
+	Created  time.Time `json:"created"`
+	Scanned  int       `json:"scanned"`
+	// Untracked lines of the snapshot or the view now are of kinds past
+	// maxSnapshotSignatures, their signatures may be missing from New and Gone
+	Untracked int             `json:"untracked,omitempty"`
+	New       []signatureDiff `json:"new"`  // in the view now, not in the snapshot
+	Gone      []signatureDiff `json:"gone"` // in the snapshot, not in the view now
+}
+
+// handleSnapshotDiff runs the snapshot's view again and reports signatures
+// that appeared or disappeared since, like errors new after a deploy
+func handleSnapshotDiff(w http.ResponseWriter, r *http.Request) {
+	saved, err := loadSaved()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	fp, err := saved.Settings.snapshotPath(r.PathValue("name"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	snap, err := loadSnapshot(fp)
+	if errors.Is(err, os.ErrNotExist) {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("no snapshot %q", r.PathValue("name")))
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	p := snap.viewParams()
+	rule, err := saved.effectiveRule(p)
+	if err != nil {
+		writeJSONError(w, ruleErrorStatus(err), err)
+		return
+	}
+	now, scanned, untracked, err := saved.viewSignatures(p, rule)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	ret := apiSnapshotDiffResponse{
+		Snapshot:  snap.Name,
+		Created:   snap.Created,
+		Scanned:   scanned,
+		Untracked: snap.Untracked + untracked,
+		New:       []signatureDiff{},
+		Gone:      []signatureDiff{},
+	}
+	before := map[string]*signature{}
+	for _, sig := range snap.Signatures {
+		before[sig.Hash] = sig
+	}
+	seen := map[string]bool{}
+	for _, sig := range now {
+		seen[sig.Hash] = true
+		if _, ok := before[sig.Hash]; !ok {
+			ret.New = append(ret.New, signatureDiff{Hash: sig.Hash, Message: sig.Message, Now: sig.Count})
+		}
+	}
+	for _, sig := range snap.Signatures {
+		if !seen[sig.Hash] {
+			ret.Gone = append(ret.Gone, signatureDiff{Hash: sig.Hash, Message: sig.Message, Before: sig.Count})
+		}
+	}
+	writeJSON(w, http.StatusOK, ret)
+}
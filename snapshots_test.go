@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+func postSnapshot(t *testing.T, path, token string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	newHandler().ServeHTTP(rec, req)
+	return rec.Code, rec.Body.String()
+}
+
+func TestSnapshotCreate(t *testing.T) {
+	dir := writeLogDir(t, map[string]string{
+		"a.log": `{"message":"started"}` + "\n" + `{"message":"started"}` + "\n" + `{"message":"failed"}` + "\n",
+	})
+	snapDir := t.TempDir()
+	withSaved(t, SavedStuff{
+		LogDirs:  map[string]map[string]*rules.Rule{dir: {}},
+		Settings: Settings{SnapshotDir: snapDir, DebugToken: "tok"},
+	})
+	path := "/api/snapshots/" + url.PathEscape(dir) + "?name=before"
+
+	for _, token := range []string{"", "wrong"} {
+		if code, body := postSnapshot(t, path, token); code != http.StatusForbidden {
+			t.Errorf("token %q: got %d %s", token, code, body)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(snapDir, "before.json")); err == nil {
+		t.Fatal("snapshot written without the token")
+	}
+
+	code, body := postSnapshot(t, "/api/snapshots/"+url.PathEscape(dir)+"/missing?name=before", "tok")
+	if code != http.StatusNotFound {
+		t.Errorf("missing rule set: got %d %s", code, body)
+	}
+
+	code, body = postSnapshot(t, path, "tok")
+	if code != http.StatusOK {
+		t.Fatalf("got %d %s", code, body)
+	}
+	snap := snapshot{}
+	if err := json.Unmarshal([]byte(body), &snap); err != nil {
+		t.Fatal(err)
+	}
+	if snap.Scanned != 3 || len(snap.Signatures) != 2 || snap.Signatures[0].Message != "started" || snap.Signatures[0].Count != 2 {
+		t.Errorf("got %+v", snap)
+	}
+
+	code, body = get(t, "/api/snapshots/before/diff")
+	if code != http.StatusOK || !strings.Contains(body, `"new":[]`) || !strings.Contains(body, `"gone":[]`) {
+		t.Errorf("diff against itself: got %d %s", code, body)
+	}
+}
+
+func TestSnapshotSignaturesBounded(t *testing.T) {
+	var lines strings.Builder
+	for i := range maxSnapshotSignatures + 5 {
+		fmt.Fprintf(&lines, `{"message":"kind %d %s"}`+"\n", i, strings.Repeat("x", maxSignatureMessage))
+	}
+	dir := writeLogDir(t, map[string]string{"a.log": lines.String()})
+	saved := SavedStuff{LogDirs: map[string]map[string]*rules.Rule{dir: {}}}
+	sigs, scanned, untracked, err := saved.viewSignatures(viewParams{Dir: dir}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scanned != maxSnapshotSignatures+5 || len(sigs) != maxSnapshotSignatures || untracked != 5 {
+		t.Errorf("got %d signatures and %d untracked of %d scanned", len(sigs), untracked, scanned)
+	}
+	if n := len([]rune(sigs[0].Message)); n > maxSignatureMessage+20 {
+		t.Errorf("kept %d characters of a message", n)
+	}
+}
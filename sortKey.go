@@ -0,0 +1,92 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+// sortedLine is a matched line with its sort key, key is a float64 for
+// numbers, a string for other scalars and nil when the field is missing
+type sortedLine struct {
+	line, file string
+	key        any
+	seq        int
+}
+
+// sortedLines keeps the first n matched lines in sort order. Lines are
+// sorted and cut down to n whenever twice as many have piled up, so memory
+// stays bounded by the page however many lines match.
+type sortedLines struct {
+	field string
+	asc   bool
+	n     int
+	lines []sortedLine
+	seq   int
+}
+
+func newSortedLines(opts scanOptions, n int) *sortedLines {
+	return &sortedLines{field: opts.SortField, asc: opts.SortAsc, n: n}
+}
+
+func (s *sortedLines) push(fp, line string) {
+	var key any
+	msg, _ := rules.NewLine(line).Message()
+	if v, ok := rules.LookupField(msg, s.field); ok {
+		if str, ok := rules.LooseString(v); ok {
+			key = str
+			if f, err := strconv.ParseFloat(str, 64); err == nil {
+				key = f
+			}
+		}
+	}
+	s.seq++
+	s.lines = append(s.lines, sortedLine{line: line, file: fp, key: key, seq: s.seq})
+	if len(s.lines) >= 2*s.n {
+		s.sort()
+		s.lines = s.lines[:s.n]
+	}
+}
+
+// sort puts numbers before strings and lines without the field last in
+// either direction, ties keep newest first like unsorted views
+func (s *sortedLines) sort() {
+	rank := func(k any) int {
+		switch k.(type) {
+		case float64:
+			return 0
+		case string:
+			return 1
+		}
+		return 2
+	}
+	slices.SortFunc(s.lines, func(a, b sortedLine) int {
+		if c := cmp.Compare(rank(a.key), rank(b.key)); c != 0 {
+			return c
+		}
+		c := 0
+		switch ak := a.key.(type) {
+		case float64:
+			c = cmp.Compare(ak, b.key.(float64))
+		case string:
+			c = cmp.Compare(ak, b.key.(string))
+		}
+		if !s.asc {
+			c = -c
+		}
+		return cmp.Or(c, cmp.Compare(b.seq, a.seq))
+	})
+}
+
+// page returns lines and their files in display order
+func (s *sortedLines) page(offset, limit int) (lines, files []string) {
+	s.sort()
+	lines, files = []string{}, []string{}
+	for _, l := range s.lines[min(offset, len(s.lines)):min(offset+limit, len(s.lines))] {
+		lines = append(lines, l.line)
+		files = append(files, l.file)
+	}
+	return lines, files
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// stackGroup is a run of lines of a stack trace as the view shows it
+type stackGroup struct {
+	Lines     []string
+	Collapsed bool // lines matched DirSettings.StackCollapse, shown folded
+	Repeat    int  // how many times the single line repeated in a row
+}
+
+// compileStackCollapse compiles collapse patterns of the directory, invalid
+// ones are skipped here and reported by config validation
+func compileStackCollapse(patterns []string) []*regexp.Regexp {
+	ret := []*regexp.Regexp{}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			log.Warn().Err(err).Str("pattern", p).Msg("skipping stack collapse pattern")
+			continue
+		}
+		ret = append(ret, re)
+	}
+	return ret
+}
+
+func validStackCollapse(patterns []string) error {
+	for _, p := range patterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("stack collapse pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// collapseStack splits a trace into lines, runs of identical lines become a
+// single line with a repeat count and runs of lines matching any of the
+// patterns (vendored or framework frames) become one collapsed group
+func collapseStack(trace string, patterns []*regexp.Regexp) []stackGroup {
+	ret := []stackGroup{}
+	for _, l := range strings.Split(strings.TrimRight(trace, "\n"), "\n") {
+		collapsed := false
+		for _, re := range patterns {
+			if re.MatchString(l) {
+				collapsed = true
+				break
+			}
+		}
+		last := len(ret) - 1
+		switch {
+		case last >= 0 && !collapsed && !ret[last].Collapsed && ret[last].Lines[0] == l:
+			ret[last].Repeat++
+		case last >= 0 && collapsed && ret[last].Collapsed:
+			ret[last].Lines = append(ret[last].Lines, l)
+		default:
+			ret = append(ret, stackGroup{Lines: []string{l}, Collapsed: collapsed, Repeat: 1})
+		}
+	}
+	return ret
+}
+
+// stackFieldValues are stack fields of the message that are strings, in
+// the order of the fields
+func stackFieldValues(msg map[string]any, fields []string) (ret [][2]string) {
+	for _, f := range fields {
+		if s, ok := msg[f].(string); ok && s != "" {
+			ret = append(ret, [2]string{f, s})
+		}
+	}
+	return ret
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/a-h/templ"
+)
+
+const (
+	defaultStatusRefresh = 30 // seconds
+	defaultStaleAfter    = time.Hour
+	// statusTTL bounds how often a directory is looked at however many
+	// status pages are open, level counts have their own levelCountsTTL
+	statusTTL = 15 * time.Second
+)
+
+// dirStatus is the health of a directory as the status page shows it, every
+// part of it comes from tails and stats of files, never from full scans
+type dirStatus struct {
+	Dir    string
+	Latest *apiLatestResponse // nil when it couldn't be told
+	Levels *levelCounts
+	Size   int64 // total size of files, -1 for remote sources
+	Files  int
+	Errors []string // what failed while looking at the directory
+	At     time.Time
+}
+
+var (
+	statusCache   = map[string]*dirStatus{}
+	statusCacheMu sync.Mutex
+)
+
+func (s Settings) statusRefresh() int {
+	if s.StatusRefresh == 0 {
+		return defaultStatusRefresh
+	}
+	return max(0, s.StatusRefresh)
+}
+
+func (s Settings) staleAfter() time.Duration {
+	if s.StaleAfter <= 0 {
+		return defaultStaleAfter
+	}
+	return time.Duration(s.StaleAfter) * time.Second
+}
+
+func (s SavedStuff) dirStatus(dir string) *dirStatus {
+	statusCacheMu.Lock()
+	c, ok := statusCache[dir]
+	statusCacheMu.Unlock()
+	if ok && time.Since(c.At) < statusTTL {
+		return c
+	}
+	c = &dirStatus{Dir: dir, At: time.Now()}
+	latest, err := s.latestTime(viewParams{Dir: dir})
+	if err != nil {
+		c.Errors = append(c.Errors, fmt.Sprintf("latest time: %s", err))
+	} else {
+		c.Latest = &latest
+	}
+	c.Levels, err = getLevelCounts(dir, s.Settings.IndexTailLines, s.dirScanOptions(dir))
+	if err != nil {
+		c.Errors = append(c.Errors, fmt.Sprintf("level counts: %s", err))
+	}
+	files, err := logFiles(dir, scanOptions{})
+	if err != nil {
+		c.Errors = append(c.Errors, fmt.Sprintf("listing files: %s", err))
+	}
+	c.Files = len(files)
+	for _, fp := range files {
+		if isRemote(fp) {
+			c.Size = -1
+			break
+		}
+		st, err := os.Stat(fp)
+		if err != nil {
+			c.Errors = append(c.Errors, fmt.Sprintf("size: %s", err))
+			break
+		}
+		c.Size += st.Size()
+	}
+	statusCacheMu.Lock()
+	statusCache[dir] = c
+	statusCacheMu.Unlock()
+	return c
+}
+
+// health is "bad" for directories with errors logged recently or that
+// couldn't be looked at, "stale" for ones that didn't log for staleAfter
+// and "ok" otherwise
+func (d *dirStatus) health(staleAfter time.Duration) string {
+	switch {
+	case len(d.Errors) > 0 || (d.Levels != nil && d.Levels.Errors > 0):
+		return "bad"
+	case d.Latest == nil || time.Since(d.Latest.Time) > staleAfter:
+		return "stale"
+	}
+	return "ok"
+}
+
+func (d *dirStatus) age() string {
+	if d.Latest == nil {
+		return "unknown"
+	}
+	return time.Since(d.Latest.Time).Round(time.Second).String() + " ago"
+}
+
+func (d *dirStatus) sizeText() string {
+	if d.Size < 0 {
+		return "remote"
+	}
+	const unit = 1024
+	if d.Size < unit {
+		return strconv.FormatInt(d.Size, 10) + " B"
+	}
+	div, exp := int64(unit), 0
+	for n := d.Size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(d.Size)/float64(div), "KMGTPE"[exp])
+}
+
+// handleStatus lists health of every configured directory, the page
+// refreshes itself every StatusRefresh seconds
+func handleStatus(w http.ResponseWriter, r *http.Request) {
+	saved, err := loadSaved()
+	if err != nil {
+		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
+		return
+	}
+	dirs := []*dirStatus{}
+	for _, k := range slices.Sorted(maps.Keys(saved.LogDirs)) {
+		dirs = append(dirs, saved.dirStatus(k))
+	}
+	if refresh := saved.Settings.statusRefresh(); refresh > 0 {
+		w.Header().Set("Refresh", strconv.Itoa(refresh))
+	}
+	templ.Handler(tPage(tStatus(dirs, saved.Settings.staleAfter()))).ServeHTTP(w, r)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const themeCookie = "theme"
+
+// themes are body classes defined in style.css, first one is the default
+var themes = []string{"dark", "light", "high-contrast"}
+
+type themeCtxKey struct{}
+
+// withTheme passes theme chosen in the cookie to templates through the
+// request context so tPage doesn't need every handler to pass it along
+func withTheme(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie(themeCookie); err == nil && slices.Contains(themes, c.Value) {
+			r = r.WithContext(context.WithValue(r.Context(), themeCtxKey{}, c.Value))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func pageTheme(ctx context.Context) string {
+	if t, ok := ctx.Value(themeCtxKey{}).(string); ok {
+		return t
+	}
+	return themes[0]
+}
+
+// handleTheme remembers the theme and sends user back where they came from
+func handleTheme(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !slices.Contains(themes, name) {
+		http.NotFound(w, r)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     themeCookie,
+		Value:    name,
+		Path:     "/",
+		MaxAge:   int((365 * 24 * time.Hour).Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	back := r.Referer()
+	if back == "" {
+		back = "/"
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
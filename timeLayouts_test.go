@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+func TestViewRulesUseDirTimeLayouts(t *testing.T) {
+	dir := writeLogDir(t, map[string]string{
+		"a.log": `{"time":"2024-03-01 03:00:00.000","message":"night"}` + "\n" +
+			`{"time":"2024-03-01 13:00:00.000","message":"day"}` + "\n",
+	})
+	saved := SavedStuff{DirSettings: map[string]*DirSettings{
+		dir: {TimeLayouts: []string{"2006-01-02 15:04:05.000"}},
+	}}
+	rule := &rules.Rule{Op: "timeofday", Data: map[string]any{"Field": "time", "From": "22:00", "To": "06:00"}}
+	res, err := processDir(context.Background(), dir, saved.scanOptions(viewParams{Dir: dir}), rule, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Messages) != 1 || res.Messages[0]["message"] != "night" {
+		t.Errorf("got %v, want only the night message", res.Messages)
+	}
+}
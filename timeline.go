@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/a-h/templ"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+const (
+	defaultTimelineGap         = time.Minute
+	defaultTimelineBurst       = 20
+	defaultTimelineBurstWindow = time.Second
+)
+
+// timelineThresholds decide what is marked on the timeline, a gap is a
+// silence longer than Gap and a burst is at least Burst messages within
+// BurstWindow
+type timelineThresholds struct {
+	Gap         time.Duration
+	Burst       int
+	BurstWindow time.Duration
+}
+
+type timelineEntry struct {
+	Msg     map[string]any
+	Time    time.Time
+	HasTime bool
+	Gap     time.Duration // silence between this message and the newer one above
+	Burst   bool
+}
+
+type timeline struct {
+	Entries    []timelineEntry // newest first like the view
+	NoTime     int             // messages without parseable time, not marked
+	Bursts     int
+	Gaps       int
+	Thresh     timelineThresholds // thresholds it was built with
+	Scanned    int
+	Matched    int
+	CapHit     bool
+	OutOfOrder bool // times are not in order, gaps may be meaningless
+}
+
+// buildTimeline marks gaps and bursts in newest first messages
+func buildTimeline(msgs []map[string]any, layouts []string, th timelineThresholds) timeline {
+	ret := timeline{Thresh: th, Entries: make([]timelineEntry, len(msgs))}
+	timed := []int{} // indices of entries with time, newest first
+	for i, msg := range msgs {
+		e := &ret.Entries[i]
+		e.Msg = msg
+		e.Time, e.HasTime = rules.ParseTime(msg["time"], layouts)
+		if !e.HasTime {
+			ret.NoTime++
+			continue
+		}
+		if len(timed) > 0 {
+			newer := ret.Entries[timed[len(timed)-1]].Time
+			e.Gap = newer.Sub(e.Time)
+			if e.Gap < 0 {
+				ret.OutOfOrder = true
+			}
+			if e.Gap > th.Gap {
+				ret.Gaps++
+			}
+		}
+		timed = append(timed, i)
+	}
+	// window over timed entries, everything in a window holding at least
+	// Burst messages is part of a burst
+	if th.Burst > 0 {
+		lo := 0
+		for hi := range timed {
+			for ret.Entries[timed[lo]].Time.Sub(ret.Entries[timed[hi]].Time) > th.BurstWindow {
+				lo++
+			}
+			if hi-lo+1 >= th.Burst {
+				for _, j := range timed[lo : hi+1] {
+					if !ret.Entries[j].Burst {
+						ret.Entries[j].Burst = true
+						ret.Bursts++
+					}
+				}
+			}
+		}
+	}
+	return ret
+}
+
+func queryDuration(r *http.Request, name string, def time.Duration) time.Duration {
+	ret, err := time.ParseDuration(r.URL.Query().Get(name))
+	if err != nil || ret <= 0 {
+		return def
+	}
+	return ret
+}
+
+func handleTimeline(w http.ResponseWriter, r *http.Request) {
+	saved, err := loadSaved()
+	if err != nil {
+		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
+		return
+	}
+	p := parseViewParams(r)
+	// gaps and bursts are found between neighbours in time
+	p = p.withSort("", false)
+	p.Limit = min(p.Limit, saved.Settings.maxRenderRows())
+	th := timelineThresholds{
+		Gap:         queryDuration(r, "gap", defaultTimelineGap),
+		Burst:       queryInt(r, "burst", defaultTimelineBurst),
+		BurstWindow: queryDuration(r, "window", defaultTimelineBurstWindow),
+	}
+	rule, err := saved.effectiveRule(p)
+	if err != nil {
+		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
+		return
+	}
+	ctx, cancel := saved.Settings.scanContext(r)
+	defer cancel()
+	res, err := saved.processView(ctx, p, rule)
+	if err != nil {
+		templ.Handler(tPage(tMessage(err.Error()))).ServeHTTP(w, r)
+		return
+	}
+	tl := buildTimeline(res.Messages, saved.dirSettings(p.Dir).TimeLayouts, th)
+	tl.Scanned, tl.Matched, tl.CapHit = res.Scanned, res.Matched, res.CapHit
+	templ.Handler(tPage(tTimeline(p, saved.viewDisplaySettings(p), tl))).ServeHTTP(w, r)
+}
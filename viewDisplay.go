@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/maxsupermanhd/json-log-viewer/rules"
+)
+
+// ViewDisplay is how a view shows messages. Rule sets carry one in
+// SavedStuff.RuleSetViews, so selecting a rule set brings its columns and
+// formats along and the rule set works as a complete saved view. Empty
+// fields keep what directory settings say, query parameters columns, hide,
+// msgfield and timefmt override both.
+type ViewDisplay struct {
+	Columns      []string // shown as the first columns, replaces DirSettings.PinnedFields
+	HiddenFields []string // left out of the params column
+	MessageField string
+	TimeFormat   string // Go layout the time field is shown in, as logged when empty
+}
+
+// display is the part of view parameters overriding display settings
+func (p viewParams) display() *ViewDisplay {
+	return &ViewDisplay{
+		Columns:      splitList(p.Columns),
+		HiddenFields: splitList(p.Hide),
+		MessageField: p.MessageField,
+		TimeFormat:   p.TimeFormat,
+	}
+}
+
+// splitList splits comma-separated query values, empty gives nil
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+func (ds *displaySettings) apply(v *ViewDisplay) {
+	if v == nil {
+		return
+	}
+	if v.Columns != nil {
+		ds.PinnedFields = v.Columns
+	}
+	if v.HiddenFields != nil {
+		ds.HiddenFields = v.HiddenFields
+	}
+	if v.MessageField != "" {
+		ds.MessageField = v.MessageField
+	}
+	if v.TimeFormat != "" {
+		ds.TimeFormat = v.TimeFormat
+	}
+}
+
+// viewDisplaySettings are display settings of the directory with the
+// selected rule set's bundle and query parameters applied in that order
+func (s SavedStuff) viewDisplaySettings(p viewParams) displaySettings {
+	ret := s.displaySettings(p.Dir)
+	if p.RuleSet != "" {
+		ret.apply(s.RuleSetViews[p.RuleSet])
+	}
+	ret.apply(p.display())
+	return ret
+}
+
+// timeText is the time field as shown in rows, times that don't parse are
+// shown as logged
+func (ds displaySettings) timeText(msg map[string]any) string {
+	if ds.TimeFormat != "" {
+		if t, ok := rules.ParseTime(msg["time"], ds.TimeLayouts); ok {
+			return t.Format(ds.TimeFormat)
+		}
+	}
+	return mapVstr(msg, "time")
+}